@@ -0,0 +1,233 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	// CreateShortLink unconditionally creates a new short link. Requires the
+	// `links:write` scope.
+	CreateShortLink(ctx context.Context, in *CreateShortLinkRequest, opts ...grpc.CallOption) (*ShortLink, error)
+	// GetOrCreateShortLink returns an existing short link with the same link URL,
+	// expiry, activation and coming soon URL (and ID, if given), or creates one.
+	// Requires the `links:write` scope.
+	GetOrCreateShortLink(ctx context.Context, in *CreateShortLinkRequest, opts ...grpc.CallOption) (*ShortLink, error)
+	// GetShortLinkByID fails with NOT_FOUND when there's no such short link.
+	// Requires the `links:read` scope.
+	GetShortLinkByID(ctx context.Context, in *GetShortLinkByIDRequest, opts ...grpc.CallOption) (*ShortLink, error)
+	// GetShortLinksByURL returns the short links to the link URL, compared
+	// normalised (see the link URL normalisation of the README), most recently
+	// created first. Requires the `links:read` scope.
+	GetShortLinksByURL(ctx context.Context, in *GetShortLinksByURLRequest, opts ...grpc.CallOption) (*GetShortLinksByURLResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) CreateShortLink(ctx context.Context, in *CreateShortLinkRequest, opts ...grpc.CallOption) (*ShortLink, error) {
+	out := new(ShortLink)
+	err := c.cc.Invoke(ctx, "/slink.admin.v1.AdminService/CreateShortLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetOrCreateShortLink(ctx context.Context, in *CreateShortLinkRequest, opts ...grpc.CallOption) (*ShortLink, error) {
+	out := new(ShortLink)
+	err := c.cc.Invoke(ctx, "/slink.admin.v1.AdminService/GetOrCreateShortLink", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetShortLinkByID(ctx context.Context, in *GetShortLinkByIDRequest, opts ...grpc.CallOption) (*ShortLink, error) {
+	out := new(ShortLink)
+	err := c.cc.Invoke(ctx, "/slink.admin.v1.AdminService/GetShortLinkByID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetShortLinksByURL(ctx context.Context, in *GetShortLinksByURLRequest, opts ...grpc.CallOption) (*GetShortLinksByURLResponse, error) {
+	out := new(GetShortLinksByURLResponse)
+	err := c.cc.Invoke(ctx, "/slink.admin.v1.AdminService/GetShortLinksByURL", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	// CreateShortLink unconditionally creates a new short link. Requires the
+	// `links:write` scope.
+	CreateShortLink(context.Context, *CreateShortLinkRequest) (*ShortLink, error)
+	// GetOrCreateShortLink returns an existing short link with the same link URL,
+	// expiry, activation and coming soon URL (and ID, if given), or creates one.
+	// Requires the `links:write` scope.
+	GetOrCreateShortLink(context.Context, *CreateShortLinkRequest) (*ShortLink, error)
+	// GetShortLinkByID fails with NOT_FOUND when there's no such short link.
+	// Requires the `links:read` scope.
+	GetShortLinkByID(context.Context, *GetShortLinkByIDRequest) (*ShortLink, error)
+	// GetShortLinksByURL returns the short links to the link URL, compared
+	// normalised (see the link URL normalisation of the README), most recently
+	// created first. Requires the `links:read` scope.
+	GetShortLinksByURL(context.Context, *GetShortLinksByURLRequest) (*GetShortLinksByURLResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) CreateShortLink(context.Context, *CreateShortLinkRequest) (*ShortLink, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateShortLink not implemented")
+}
+func (UnimplementedAdminServiceServer) GetOrCreateShortLink(context.Context, *CreateShortLinkRequest) (*ShortLink, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrCreateShortLink not implemented")
+}
+func (UnimplementedAdminServiceServer) GetShortLinkByID(context.Context, *GetShortLinkByIDRequest) (*ShortLink, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShortLinkByID not implemented")
+}
+func (UnimplementedAdminServiceServer) GetShortLinksByURL(context.Context, *GetShortLinksByURLRequest) (*GetShortLinksByURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShortLinksByURL not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_CreateShortLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateShortLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CreateShortLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/slink.admin.v1.AdminService/CreateShortLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CreateShortLink(ctx, req.(*CreateShortLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetOrCreateShortLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateShortLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetOrCreateShortLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/slink.admin.v1.AdminService/GetOrCreateShortLink",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetOrCreateShortLink(ctx, req.(*CreateShortLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetShortLinkByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShortLinkByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetShortLinkByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/slink.admin.v1.AdminService/GetShortLinkByID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetShortLinkByID(ctx, req.(*GetShortLinkByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetShortLinksByURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShortLinksByURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetShortLinksByURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/slink.admin.v1.AdminService/GetShortLinksByURL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetShortLinksByURL(ctx, req.(*GetShortLinksByURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "slink.admin.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateShortLink",
+			Handler:    _AdminService_CreateShortLink_Handler,
+		},
+		{
+			MethodName: "GetOrCreateShortLink",
+			Handler:    _AdminService_GetOrCreateShortLink_Handler,
+		},
+		{
+			MethodName: "GetShortLinkByID",
+			Handler:    _AdminService_GetShortLinkByID_Handler,
+		},
+		{
+			MethodName: "GetShortLinksByURL",
+			Handler:    _AdminService_GetShortLinksByURL_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "admin.proto",
+}
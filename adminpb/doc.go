@@ -0,0 +1,6 @@
+// Package adminpb is the generated gRPC client and server code of the admin
+// API, see admin.proto. Regenerate it with `go generate` (requires `protoc`,
+// `protoc-gen-go` v1.28 and `protoc-gen-go-grpc` v1.2).
+package adminpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
@@ -0,0 +1,3 @@
+// Package audit records who did what through the admin API, e.g. which auth
+// key created or viewed which short link, to a pluggable Sink.
+package audit
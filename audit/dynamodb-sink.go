@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// DynamoDBSink puts each Event as an item in a DynamoDB table, which must
+// already exist, with a string `pk` partition key and a string `sk` sort key.
+// It can be the same table as the short links (see storage.DynamoDBStorage),
+// or a separate one, e.g. for a different retention or access policy.
+//
+// The events of each auth key are in their own partition, sorted by time:
+// - pk = `audit#<KeyID>` (`audit#` for unauthenticated requests)
+// - sk = `<Time>#<RequestID>`
+//
+// With a retention (see WithDynamoDBSinkRetention), items have a `ttl`
+// attribute (in Unix epoch seconds), enable Time to Live on it to have old
+// events deleted.
+//
+// The only required permission is `dynamodb:PutItem`.
+type DynamoDBSink struct {
+	tableName string
+	retention time.Duration
+	client    DynamoDBPutter
+}
+
+var _ Sink = (*DynamoDBSink)(nil)
+
+const ddbAuditEventPKPrefix = "audit#"
+
+type ddbAuditEventItem struct {
+	*Event
+
+	Type string `dynamodbav:"_type"`
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+	TTL  int64  `dynamodbav:"ttl,omitempty"`
+}
+
+func (s *DynamoDBSink) Record(ctx context.Context, event *Event) error {
+	item := &ddbAuditEventItem{
+		Event: event,
+		Type:  "AuditEvent",
+		PK:    ddbAuditEventPKPrefix + event.KeyID,
+		SK:    event.Time + "#" + event.RequestID,
+	}
+
+	if s.retention > 0 {
+		t, err := time.Parse(time.RFC3339, event.Time)
+		if err != nil {
+			return fmt.Errorf("time.Parse Time: %w", err)
+		}
+		item.TTL = t.Add(s.retention).Unix()
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.PutItem: %w", err)
+	}
+	return nil
+}
+
+type DynamoDBPutter interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+func NewDynamoDBSink(ctx context.Context, tableName string, options ...func(*DynamoDBSink)) (*DynamoDBSink, error) {
+	if tableName == "" {
+		return nil, errors.New("missing tableName")
+	}
+
+	s := &DynamoDBSink{
+		tableName: tableName,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.client == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("awsConfig.LoadDefaultConfig: %w", err)
+		}
+		s.client = dynamodb.NewFromConfig(cfg)
+	}
+
+	return s, nil
+}
+
+func WithDynamoDBSinkClient(client DynamoDBPutter) func(*DynamoDBSink) {
+	return func(s *DynamoDBSink) {
+		s.client = client
+	}
+}
+
+// WithDynamoDBSinkRetention specifies how long events are kept, by setting
+// the `ttl` attribute of their items.
+func WithDynamoDBSinkRetention(retention time.Duration) func(*DynamoDBSink) {
+	return func(s *DynamoDBSink) {
+		s.retention = retention
+	}
+}
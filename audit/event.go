@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+)
+
+// The outcomes of Events.
+const (
+	// OutcomeSuccess is the outcome of requests with a 1xx, 2xx or 3xx
+	// response.
+	OutcomeSuccess = "success"
+	// OutcomeDenied is the outcome of requests rejected because of their auth
+	// key, e.g. a missing scope or an exceeded rate limit.
+	OutcomeDenied = "denied"
+	// OutcomeFailure is the outcome of any other requests, e.g. invalid ones.
+	OutcomeFailure = "failure"
+)
+
+// Event is an admin API request.
+type Event struct {
+	Time      string `json:"time" dynamodbav:"time"`
+	RequestID string `json:"requestId" dynamodbav:"requestId"`
+	// KeyID is the ID of the auth key of the request, empty if the request
+	// wasn't authenticated.
+	KeyID string `json:"keyId,omitempty" dynamodbav:"keyId,omitempty"`
+	// Route is the method and the path pattern, e.g. `GET /short-link/:id`.
+	Route string `json:"route" dynamodbav:"route"`
+	// ShortLinkIDs are the short links that were created, viewed or changed,
+	// if known, e.g. not for exports.
+	ShortLinkIDs []string `json:"shortLinkIds,omitempty" dynamodbav:"shortLinkIds,omitempty"`
+	// ShortLinkCount is the number of short links, which can be more than the
+	// ShortLinkIDs when there are too many to list, e.g. for bulk creation.
+	ShortLinkCount int    `json:"shortLinkCount,omitempty" dynamodbav:"shortLinkCount,omitempty"`
+	Outcome        string `json:"outcome" dynamodbav:"outcome"`
+	StatusCode     int    `json:"statusCode" dynamodbav:"statusCode"`
+	// ClientIP is the address of the peer, e.g. a proxy.
+	ClientIP string `json:"clientIp" dynamodbav:"clientIp"`
+	// ForwardedFor is the `X-Forwarded-For` request header as is, which is
+	// only as trustworthy as the proxies in front of the admin server.
+	ForwardedFor string `json:"forwardedFor,omitempty" dynamodbav:"forwardedFor,omitempty"`
+}
+
+// OutcomeOf returns the outcome of a request with the response status code.
+func OutcomeOf(statusCode int) string {
+	switch {
+	case statusCode < 400:
+		return OutcomeSuccess
+	case statusCode == 401, statusCode == 403, statusCode == 429:
+		return OutcomeDenied
+	default:
+		return OutcomeFailure
+	}
+}
+
+// Sink records Events somewhere durable.
+type Sink interface {
+	Record(ctx context.Context, event *Event) error
+}
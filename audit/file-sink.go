@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSink appends each Event as a line of JSON to a file.
+type FileSink struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink opens the file for appending, creating it if needed. A filename
+// of `-` writes to stdout instead, e.g. for a log collector.
+func NewFileSink(filename string) (*FileSink, error) {
+	if filename == "-" {
+		return &FileSink{w: os.Stdout}, nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+	return &FileSink{w: f}, nil
+}
+
+func (s *FileSink) Record(ctx context.Context, event *Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a single write, so that lines aren't interleaved with other processes
+	// appending to the same file
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	if s.w == os.Stdout {
+		return nil
+	}
+	return s.w.Close()
+}
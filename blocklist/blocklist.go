@@ -0,0 +1,229 @@
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ronny/slink/urls"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultReloadInterval is how often the blocklist files are checked for
+// changes.
+const DefaultReloadInterval = 1 * time.Minute
+
+// Blocklist holds the blocked domains and URL prefixes loaded from files, one
+// per line, ignoring blank lines and lines starting with `#`.
+//
+// A blocked domain (e.g. `example.com`) blocks URLs to it and to any of its
+// subdomains. A blocked URL prefix (e.g. `https://example.com/phishing/`)
+// blocks URLs starting with it, both being normalised first (see
+// `urls.StandardNormaliser`, without removing query parameters).
+type Blocklist struct {
+	domainsFile     string
+	urlPrefixesFile string
+	normaliser      urls.Normaliser
+	entries         atomic.Pointer[entries]
+}
+
+type entries struct {
+	domains     map[string]bool
+	urlPrefixes []string
+}
+
+func NewBlocklist(options ...func(*Blocklist)) (*Blocklist, error) {
+	b := &Blocklist{
+		normaliser: urls.NewStandardNormaliser(urls.WithStrippedQueryParams()),
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	if b.domainsFile == "" && b.urlPrefixesFile == "" {
+		return nil, errors.New("at least one of domainsFile and urlPrefixesFile is required")
+	}
+
+	err := b.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Blocked returns the blocked domain or URL prefix that linkURL matches, if
+// any.
+func (b *Blocklist) Blocked(linkURL string) (string, bool) {
+	e := b.entries.Load()
+
+	u, err := url.Parse(linkURL)
+	if err == nil && u.Host != "" && len(e.domains) > 0 {
+		host := normaliseDomain(u.Hostname())
+		if e.domains[host] {
+			return host, true
+		}
+		if urls.ParseIP(host) == nil {
+			for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+				host = host[i+1:]
+				if e.domains[host] {
+					return host, true
+				}
+			}
+		}
+	}
+
+	if len(e.urlPrefixes) > 0 {
+		normalised, err := b.normaliser.Normalise(linkURL)
+		if err != nil {
+			normalised = linkURL
+		}
+		for _, prefix := range e.urlPrefixes {
+			if strings.HasPrefix(normalised, prefix) {
+				return prefix, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Reload replaces the blocked domains and URL prefixes with the ones in the
+// files. The ones in use are kept when either file can't be read.
+func (b *Blocklist) Reload() error {
+	e := &entries{domains: map[string]bool{}}
+
+	if b.domainsFile != "" {
+		lines, err := readLines(b.domainsFile)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			e.domains[normaliseDomain(strings.TrimPrefix(line, "*."))] = true
+		}
+	}
+
+	if b.urlPrefixesFile != "" {
+		lines, err := readLines(b.urlPrefixesFile)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			prefix, err := b.normaliser.Normalise(line)
+			if err != nil {
+				log.Warn().Err(err).Str("urlPrefix", line).Msg("blocked URL prefix can't be normalised, using it as is")
+				prefix = line
+			}
+			e.urlPrefixes = append(e.urlPrefixes, prefix)
+		}
+	}
+
+	b.entries.Store(e)
+	return nil
+}
+
+// Watch reloads the blocklist when the modification time or size of either
+// file changes, checking every interval until stop is closed.
+func (b *Blocklist) Watch(interval time.Duration, stop <-chan struct{}) {
+	lastStats := b.stats()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		stats := b.stats()
+		if stats == lastStats {
+			continue
+		}
+		lastStats = stats
+
+		err := b.Reload()
+		if err != nil {
+			log.Error().Err(err).Msg("reloading the blocklist failed, keeping the current one")
+			continue
+		}
+		e := b.entries.Load()
+		log.Info().Int("domains", len(e.domains)).Int("urlPrefixes", len(e.urlPrefixes)).Msg("blocklist reloaded")
+	}
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func (b *Blocklist) stats() [2]fileStat {
+	var stats [2]fileStat
+	for i, filename := range []string{b.domainsFile, b.urlPrefixesFile} {
+		if filename == "" {
+			continue
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			// a zero fileStat, so the reload is tried again when it's back
+			continue
+		}
+		stats[i] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stats
+}
+
+func readLines(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	return lines, nil
+}
+
+// normaliseDomain normalises a domain, or an IP address, e.g. `0x7f.1` to
+// `127.0.0.1`, so that the forms of an address browsers accept match.
+func normaliseDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if ip := urls.ParseIP(domain); ip != nil {
+		return ip.String()
+	}
+	if ascii, err := urls.ASCIIHost(domain); err == nil {
+		return ascii
+	}
+	return domain
+}
+
+// WithDomainsFile specifies the file of blocked domains.
+func WithDomainsFile(domainsFile string) func(*Blocklist) {
+	return func(b *Blocklist) {
+		b.domainsFile = domainsFile
+	}
+}
+
+// WithURLPrefixesFile specifies the file of blocked URL prefixes.
+func WithURLPrefixesFile(urlPrefixesFile string) func(*Blocklist) {
+	return func(b *Blocklist) {
+		b.urlPrefixesFile = urlPrefixesFile
+	}
+}
@@ -0,0 +1,181 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+
+	err := os.WriteFile(filename, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+}
+
+// newTestBlocklist returns a Blocklist of the domains and URL prefixes, with
+// the filenames of their files.
+func newTestBlocklist(t *testing.T, domains, urlPrefixes string) (*Blocklist, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	domainsFile := filepath.Join(dir, "domains.txt")
+	urlPrefixesFile := filepath.Join(dir, "url-prefixes.txt")
+	writeFile(t, domainsFile, domains)
+	writeFile(t, urlPrefixesFile, urlPrefixes)
+
+	b, err := NewBlocklist(WithDomainsFile(domainsFile), WithURLPrefixesFile(urlPrefixesFile))
+	if err != nil {
+		t.Fatalf("NewBlocklist: %v", err)
+	}
+	return b, domainsFile, urlPrefixesFile
+}
+
+func checkBlocked(t *testing.T, b *Blocklist, linkURL, wantEntry string) {
+	t.Helper()
+
+	entry, blocked := b.Blocked(linkURL)
+	if entry != wantEntry || blocked != (wantEntry != "") {
+		t.Errorf("Blocked(%q): got %q, %t, want %q, %t", linkURL, entry, blocked, wantEntry, wantEntry != "")
+	}
+}
+
+func TestBlocklistBlocked(t *testing.T) {
+	const domains = `
+# comments and blank lines are ignored
+evil.com
+*.wild.example
+MÜNCHEN.de
+xn--bcher-kva.example.
+127.0.0.1
+`
+	const urlPrefixes = `
+# phishing pages
+HTTPS://Example.COM:443/phish
+https://münchen.example/login
+`
+	b, _, _ := newTestBlocklist(t, domains, urlPrefixes)
+
+	tests := []struct {
+		name      string
+		linkURL   string
+		wantEntry string
+	}{
+		{name: "domain", linkURL: "https://evil.com/a", wantEntry: "evil.com"},
+		{name: "domain in another case with a trailing dot and port", linkURL: "https://EVIL.com.:8443/", wantEntry: "evil.com"},
+		{name: "subdomain", linkURL: "https://a.b.evil.com/", wantEntry: "evil.com"},
+		{name: "same suffix, another domain", linkURL: "https://notevil.com/"},
+		{name: "domain as a subdomain of another", linkURL: "https://evil.com.example.org/"},
+		{name: "wildcard entry, subdomain", linkURL: "https://www.wild.example/", wantEntry: "wild.example"},
+		{name: "wildcard entry, domain", linkURL: "https://wild.example/", wantEntry: "wild.example"},
+		{name: "IDN entry, punycode URL", linkURL: "https://www.xn--mnchen-3ya.de/", wantEntry: "xn--mnchen-3ya.de"},
+		{name: "punycode entry, IDN URL", linkURL: "https://Bücher.example/", wantEntry: "xn--bcher-kva.example"},
+		{name: "IP entry", linkURL: "http://127.0.0.1/", wantEntry: "127.0.0.1"},
+		{name: "IP entry, other form of the IP", linkURL: "http://0x7f.1/", wantEntry: "127.0.0.1"},
+		{name: "IP entry, another IP", linkURL: "http://127.0.0.2/"},
+
+		{name: "URL prefix", linkURL: "https://example.com/phishing/a", wantEntry: "https://example.com/phish"},
+		{name: "URL prefix, URL to normalise", linkURL: "HTTPS://EXAMPLE.com:443/phish?b=2&a=1", wantEntry: "https://example.com/phish"},
+		{name: "URL prefix, path in another case", linkURL: "https://example.com/Phish"},
+		{name: "URL prefix, another scheme", linkURL: "http://example.com/phish"},
+		{name: "IDN URL prefix", linkURL: "https://xn--mnchen-3ya.example/login?next=/", wantEntry: "https://xn--mnchen-3ya.example/login"},
+		{name: "neither", linkURL: "https://example.org/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkBlocked(t, b, tt.linkURL, tt.wantEntry)
+		})
+	}
+}
+
+func TestNewBlocklist(t *testing.T) {
+	_, err := NewBlocklist()
+	if err == nil {
+		t.Errorf("NewBlocklist without files: got no error, want one")
+	}
+
+	_, err = NewBlocklist(WithDomainsFile(filepath.Join(t.TempDir(), "nope.txt")))
+	if err == nil {
+		t.Errorf("NewBlocklist with a missing file: got no error, want one")
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	b, domainsFile, urlPrefixesFile := newTestBlocklist(t, "evil.com\n", "https://example.com/phish\n")
+
+	writeFile(t, domainsFile, "bad.com\n")
+	err := os.Remove(urlPrefixesFile)
+	if err != nil {
+		t.Fatalf("os.Remove: %v", err)
+	}
+	err = b.Reload()
+	if err == nil {
+		t.Fatalf("Reload with an unreadable file: got no error, want one")
+	}
+	// neither file's entries are replaced
+	checkBlocked(t, b, "https://evil.com/", "evil.com")
+	checkBlocked(t, b, "https://example.com/phish", "https://example.com/phish")
+	checkBlocked(t, b, "https://bad.com/", "")
+
+	writeFile(t, urlPrefixesFile, "")
+	err = b.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	checkBlocked(t, b, "https://evil.com/", "")
+	checkBlocked(t, b, "https://example.com/phish", "")
+	checkBlocked(t, b, "https://bad.com/", "bad.com")
+}
+
+func TestBlocklistWatch(t *testing.T) {
+	b, domainsFile, _ := newTestBlocklist(t, "evil.com\n", "")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Watch(time.Millisecond, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	waitForBlocked := func(linkURL, wantEntry string) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			entry, _ := b.Blocked(linkURL)
+			if entry == wantEntry {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Blocked(%q): got %q after reloading, want %q", linkURL, entry, wantEntry)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// for Watch to get the stats of the files before they change
+	time.Sleep(10 * time.Millisecond)
+
+	// a different size, in case the modification time is the same
+	writeFile(t, domainsFile, "evil.com\nbad.com\n")
+	waitForBlocked("https://bad.com/", "bad.com")
+
+	// kept while the file is missing, and reloaded when it's back
+	err := os.Remove(domainsFile)
+	if err != nil {
+		t.Fatalf("os.Remove: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	checkBlocked(t, b, "https://bad.com/", "bad.com")
+
+	writeFile(t, domainsFile, "worse.com\n")
+	waitForBlocked("https://bad.com/", "")
+	checkBlocked(t, b, "https://worse.com/", "worse.com")
+}
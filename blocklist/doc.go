@@ -0,0 +1,3 @@
+// Package blocklist refuses link URLs to blocked domains or URL prefixes, e.g.
+// known malicious ones, loaded from files that are reloaded when they change.
+package blocklist
@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ronny/slink"
+	"github.com/ronny/slink/audit"
 	"github.com/ronny/slink/debug"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+	"github.com/ronny/slink/tlsconfig"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
 type AdminServer struct {
@@ -22,10 +31,59 @@ type AdminServer struct {
 	svc          *slink.Slink
 	slinkOptions []func(*slink.Slink)
 	authKeys     []AuthKey
+	routes       []registeredRoute
+
+	authKeysFile           string
+	authKeysReloadInterval time.Duration
+	authKeySet             atomic.Pointer[authKeySet]
+	stopWatchingFiles      chan struct{}
+
+	jwksFile    string
+	jwtIssuer   string
+	jwtAudience string
+	jwks        atomic.Pointer[jwks]
+
+	hmacReplayWindow time.Duration
+	hmacNonces       *lru.Cache
+
+	tlsCertFile          string
+	tlsKeyFile           string
+	tlsClientCAFile      string
+	tlsRequireClientCert bool
+	certReloader         *tlsconfig.CertReloader
+
+	streamingTimeout time.Duration
+	bulkConcurrency  int
+
+	idempotencyStore storage.IdempotencyStore
+	idempotencyTTL   time.Duration
+
+	auditSink audit.Sink
+
+	rateLimit        float64
+	rateLimitBurst   int
+	rateLimiters     *lru.Cache
+	dailyCreateQuota int
+	quotaStore       storage.QuotaStore
+
+	grpcListenAddr string
+	grpcServer     *grpc.Server
+
+	publicBaseURL string
 }
 
 const (
 	DefaultHandlerTimeoutDuration = 5 * time.Second
+	// DefaultStreamingTimeoutDuration is the default time limit for handlers
+	// that stream their request or response, e.g. bulk creation.
+	DefaultStreamingTimeoutDuration = 10 * time.Minute
+	DefaultBulkConcurrency          = 4
+	// DefaultIdempotencyTTL is how long the response to a request with an
+	// Idempotency-Key is kept for retries.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultAuthKeysReloadInterval is how often the auth keys file is checked
+	// for changes.
+	DefaultAuthKeysReloadInterval = 10 * time.Second
 )
 
 func NewAdminServer(ctx context.Context, options ...func(*AdminServer)) (*AdminServer, error) {
@@ -35,67 +93,192 @@ func NewAdminServer(ctx context.Context, options ...func(*AdminServer)) (*AdminS
 			ReadTimeout:  5 * time.Second,
 			IdleTimeout:  5 * time.Second,
 		},
+		streamingTimeout: DefaultStreamingTimeoutDuration,
+		bulkConcurrency:  DefaultBulkConcurrency,
+		idempotencyTTL:   DefaultIdempotencyTTL,
+
+		authKeysReloadInterval: DefaultAuthKeysReloadInterval,
+		hmacReplayWindow:       DefaultHMACReplayWindow,
 	}
 
 	for _, option := range options {
 		option(s)
 	}
 
-	if len(s.authKeys) == 0 {
-		return nil, errors.New("missing authKeys, use WithAuthKeys to set at least one")
+	if s.bulkConcurrency < 1 {
+		return nil, errors.New("bulkConcurrency must be at least 1")
 	}
 
-	var err error
-	s.svc, err = slink.NewSlink(ctx, s.slinkOptions...)
+	if s.idempotencyTTL <= 0 {
+		return nil, errors.New("idempotencyTTL must be positive")
+	}
+
+	if (s.authKeysFile != "" || s.jwksFile != "") && s.authKeysReloadInterval <= 0 {
+		return nil, errors.New("authKeysReloadInterval must be positive")
+	}
+
+	err := s.loadAuthKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.jwksFile != "" {
+		if s.jwtIssuer == "" || s.jwtAudience == "" {
+			return nil, errors.New("jwtIssuer and jwtAudience are required with jwksFile")
+		}
+		err = s.loadJWKS()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.hmacReplayWindow <= 0 {
+		return nil, errors.New("hmacReplayWindow must be positive")
+	}
+
+	s.hmacNonces, err = lru.New(DefaultHMACNonceCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("lru.New: %w", err)
+	}
+
+	if s.rateLimit < 0 || s.rateLimitBurst < 0 || s.dailyCreateQuota < 0 {
+		return nil, errors.New("rateLimit, rateLimitBurst and dailyCreateQuota can't be negative")
+	}
+
+	s.rateLimiters, err = lru.New(DefaultRateLimiterCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("lru.New: %w", err)
+	}
+
+	err = s.configureTLS()
+	if err != nil {
+		return nil, err
+	}
+
+	slinkOptions := append(s.slinkOptions[:len(s.slinkOptions):len(s.slinkOptions)], slink.WithCreateLimiter(s))
+	s.svc, err = slink.NewSlink(ctx, slinkOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("slink.NewSlink: %w", err)
 	}
 
+	var ok bool
+	s.idempotencyStore, ok = s.svc.Storage().(storage.IdempotencyStore)
+	if !ok {
+		log.Warn().Msg("the storage backend doesn't support idempotency keys, Idempotency-Key headers will be ignored")
+	}
+
+	s.quotaStore, ok = s.svc.Storage().(storage.QuotaStore)
+	if !ok {
+		log.Warn().Msg("the storage backend doesn't support quotas, daily create quotas will be ignored")
+	}
+
 	s.router = httprouter.New()
 	s.router.GET("/_live", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) { w.WriteHeader(http.StatusOK) })
 	s.router.GET("/_ready", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) { w.WriteHeader(http.StatusOK) })
-	s.apiRoute(http.MethodPost, "/get-or-create-short-link", s.handleGetOrCreateShortLink())
-	s.apiRoute(http.MethodPost, "/create-short-link", s.handleCreateShortLink())
-	s.apiRoute(http.MethodGet, "/short-link/:id", s.handleGetShortLink())
+	s.apiRoute(http.MethodPost, "/get-or-create-short-link", ScopeLinksWrite, s.idempotent(s.handleGetOrCreateShortLink()))
+	s.apiRoute(http.MethodPost, "/create-short-link", ScopeLinksWrite, s.idempotent(s.handleCreateShortLink()))
+	s.streamingAPIRoute(http.MethodPost, bulkPath, ScopeLinksWrite, s.handleBulkCreateShortLinks())
+	s.apiRoute(http.MethodGet, "/short-links", ScopeLinksRead, s.handleListShortLinks())
+	s.apiRoute(http.MethodGet, "/short-links-by-tag/:tag", ScopeLinksRead, s.handleListShortLinks())
+	s.streamingAPIRoute(http.MethodGet, "/export-short-links", ScopeLinksRead, s.handleExportShortLinks())
+	s.apiRoute(http.MethodGet, "/short-link/:id", ScopeLinksRead, s.handleGetShortLink())
+	s.apiRoute(http.MethodPatch, "/short-link/:id", ScopeLinksAdmin, s.handleUpdateShortLink())
+	s.apiRoute(http.MethodGet, "/short-link/:id/history", ScopeLinksRead, s.handleGetShortLinkHistory())
+	s.apiRoute(http.MethodGet, "/short-link/:id/qr", ScopeLinksRead, s.handleGetShortLinkQRCode())
+	s.apiRoute(http.MethodPost, "/short-link/:id/expire", ScopeLinksAdmin, s.handleExpireShortLink())
+	s.apiRoute(http.MethodPost, "/expire-short-links-by-url", ScopeLinksAdmin, s.handleExpireShortLinksByURL())
+	// after all the API routes, which it describes
+	s.router.Handler(http.MethodGet, openAPIPath, withRequestID(s.handleOpenAPI()))
+	s.router.NotFound = withRequestID(func(w http.ResponseWriter, r *http.Request) {
+		writeErrorCode(w, r, http.StatusNotFound, ErrCodeNotFound, "not found")
+	})
+	s.router.MethodNotAllowed = withRequestID(func(w http.ResponseWriter, r *http.Request) {
+		writeErrorCode(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+	})
 	s.Handler = s.router
 
+	if s.grpcListenAddr != "" {
+		s.newGRPCServer()
+	}
+
+	s.stopWatchingFiles = make(chan struct{})
+	if s.authKeysFile != "" {
+		go watchFile(s.authKeysFile, s.authKeysReloadInterval, s.stopWatchingFiles, "auth keys", s.loadAuthKeys)
+	}
+	if s.jwksFile != "" {
+		go watchFile(s.jwksFile, s.authKeysReloadInterval, s.stopWatchingFiles, "JWKS", s.loadJWKS)
+	}
+	if s.certReloader != nil {
+		go s.certReloader.Watch(tlsconfig.DefaultReloadInterval, s.stopWatchingFiles)
+	}
+
 	return s, nil
 }
 
+// configureTLS sets up the TLS configuration when a certificate is specified,
+// with client certificate verification when a client CA file is specified.
+func (s *AdminServer) configureTLS() error {
+	if s.tlsCertFile == "" && s.tlsKeyFile == "" {
+		if s.tlsClientCAFile != "" || s.tlsRequireClientCert {
+			return errors.New("tlsClientCAFile and tlsRequireClientCert require tlsCertFile and tlsKeyFile")
+		}
+		return nil
+	}
+
+	var err error
+	s.certReloader, err = tlsconfig.NewCertReloader(s.tlsCertFile, s.tlsKeyFile)
+	if err != nil {
+		return fmt.Errorf("tlsconfig.NewCertReloader: %w", err)
+	}
+	s.TLSConfig = tlsconfig.NewServerConfig(s.certReloader)
+
+	if s.tlsClientCAFile == "" {
+		if s.tlsRequireClientCert {
+			return errors.New("tlsRequireClientCert requires tlsClientCAFile")
+		}
+		return nil
+	}
+
+	s.TLSConfig.ClientCAs, err = tlsconfig.LoadCertPool(s.tlsClientCAFile)
+	if err != nil {
+		return fmt.Errorf("tlsconfig.LoadCertPool: %w", err)
+	}
+	s.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if s.tlsRequireClientCert {
+		s.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+// ListenAndServe listens with TLS when a certificate is specified, see
+// WithTLSCertFiles.
+func (s *AdminServer) ListenAndServe() error {
+	if s.TLSConfig != nil {
+		// the certificate comes from TLSConfig.GetCertificate
+		return s.Server.ListenAndServeTLS("", "")
+	}
+	return s.Server.ListenAndServe()
+}
+
 func (s *AdminServer) handleGetOrCreateShortLink() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder := json.NewDecoder(r.Body)
 		var input slink.CreateInput
 		err := decoder.Decode(&input)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(err.Error()))
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
 			return
 		}
+		input.CreatedBy = authKeyIDFromContext(r.Context())
 
 		shortLink, err := s.svc.GetOrCreateShortLink(r.Context(), &input)
 		if err != nil {
-			var inverr *slink.ErrInvalidLinkURL
-			if errors.As(err, &inverr) {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(inverr.Error()))
-				return
-			}
-
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
-			return
-		}
-
-		b, err := json.Marshal(shortLink)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+			writeError(w, r, err)
 			return
 		}
+		auditShortLinks(r.Context(), shortLink)
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(b)
+		writeJSON(w, http.StatusOK, shortLink)
 	}
 }
 
@@ -105,97 +288,324 @@ func (s *AdminServer) handleCreateShortLink() http.HandlerFunc {
 		var input slink.CreateInput
 		err := decoder.Decode(&input)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(err.Error()))
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
 			return
 		}
+		input.CreatedBy = authKeyIDFromContext(r.Context())
 
 		shortLink, err := s.svc.CreateShortLink(r.Context(), &input)
 		if err != nil {
-			var inverr *slink.ErrInvalidLinkURL
-			if errors.As(err, &inverr) {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(inverr.Error()))
+			writeError(w, r, err)
+			return
+		}
+		auditShortLinks(r.Context(), shortLink)
+
+		writeJSON(w, http.StatusOK, shortLink)
+	}
+}
+
+func (s *AdminServer) handleGetShortLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		params := httprouter.ParamsFromContext(ctx)
+
+		shortLinkID := params.ByName("id")
+
+		shortLink, err := s.svc.GetShortLinkByID(r.Context(), shortLinkID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		if shortLink == nil {
+			log.Debug().Str("shortLinkID", shortLinkID).Msg("handleGetShortLink: short link not found")
+			writeErrorCode(w, r, http.StatusNotFound, ErrCodeShortLinkNotFound, "short link not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, shortLink)
+	}
+}
+
+// handleListShortLinks lists short links filtered by the query parameters,
+// and by the tag in the path for `/short-links-by-tag/:tag`.
+func (s *AdminServer) handleListShortLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		query := &storage.ListQuery{
+			CreatedAfter:  params.Get("createdAfter"),
+			CreatedBefore: params.Get("createdBefore"),
+			Status:        storage.ListStatus(params.Get("status")),
+			LinkURL:       params.Get("linkUrl"),
+			Tag:           params.Get("tag"),
+			Cursor:        params.Get("cursor"),
+		}
+
+		if tag := httprouter.ParamsFromContext(r.Context()).ByName("tag"); tag != "" {
+			query.Tag = tag
+		}
+
+		if limit := params.Get("limit"); limit != "" {
+			var err error
+			query.Limit, err = strconv.Atoi(limit)
+			if err != nil {
+				writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidListQuery, "limit must be a number")
 				return
 			}
+		}
+
+		page, err := s.svc.ListShortLinks(r.Context(), query)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		auditShortLinks(r.Context(), page.ShortLinks...)
+
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+func (s *AdminServer) handleUpdateShortLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		params := httprouter.ParamsFromContext(ctx)
+
+		shortLinkID := params.ByName("id")
 
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+		decoder := json.NewDecoder(r.Body)
+		var input slink.UpdateInput
+		err := decoder.Decode(&input)
+		if err != nil {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
 			return
 		}
+		input.ChangedBy = authKeyIDFromContext(ctx)
 
-		b, err := json.Marshal(shortLink)
+		shortLink, err := s.svc.UpdateShortLink(ctx, shortLinkID, &input)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+			writeError(w, r, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(b)
+		log.Info().
+			Str("keyID", input.ChangedBy).
+			Str("shortLinkID", shortLinkID).
+			Msg("short link updated")
+
+		writeJSON(w, http.StatusOK, shortLink)
 	}
 }
 
-func (s *AdminServer) handleGetShortLink() http.HandlerFunc {
+func (s *AdminServer) handleGetShortLinkHistory() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		keyID := authKeyIDFromContext(ctx)
-		log.Debug().Str("keyID", keyID).Msg("auth token matching")
 		params := httprouter.ParamsFromContext(ctx)
 
 		shortLinkID := params.ByName("id")
 
-		shortLink, err := s.svc.GetShortLinkByID(r.Context(), shortLinkID)
+		history, err := s.svc.GetShortLinkHistory(ctx, shortLinkID)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeError(w, r, err)
+			return
+		}
+
+		if history == nil {
+			history = []*models.ShortLinkChange{}
+		}
+
+		writeJSON(w, http.StatusOK, history)
+	}
+}
 
-			log.Error().Err(err).Msg("svc.GetShortLinkByID error, returning 500")
+func (s *AdminServer) handleExpireShortLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		params := httprouter.ParamsFromContext(ctx)
 
+		shortLinkID := params.ByName("id")
+
+		decoder := json.NewDecoder(r.Body)
+		var input slink.ExpireInput
+		err := decoder.Decode(&input)
+		if err != nil {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
 			return
 		}
+		input.ExpiredBy = authKeyIDFromContext(ctx)
 
-		if shortLink == nil {
-			log.Debug().Str("shortLinkID", shortLinkID).Msg("handleGetShortLink: short link not found")
-			w.WriteHeader(http.StatusNotFound)
+		shortLink, err := s.svc.ExpireShortLink(ctx, shortLinkID, &input)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		b, err := json.Marshal(shortLink)
+		log.Info().
+			Str("keyID", input.ExpiredBy).
+			Str("shortLinkID", shortLinkID).
+			Str("reason", input.Reason).
+			Msg("short link expired")
+
+		writeJSON(w, http.StatusOK, shortLink)
+	}
+}
+
+type expireShortLinksByURLInput struct {
+	LinkURL string `json:"linkUrl"`
+	slink.ExpireInput
+}
+
+func (s *AdminServer) handleExpireShortLinksByURL() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		decoder := json.NewDecoder(r.Body)
+		var input expireShortLinksByURLInput
+		err := decoder.Decode(&input)
+		if err != nil {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		input.ExpiredBy = authKeyIDFromContext(ctx)
+
+		shortLinks, err := s.svc.ExpireShortLinksByURL(ctx, input.LinkURL, &input.ExpireInput)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
+			writeError(w, r, err)
 			return
 		}
+		auditShortLinks(ctx, shortLinks...)
+
+		for _, shortLink := range shortLinks {
+			log.Info().
+				Str("keyID", input.ExpiredBy).
+				Str("shortLinkID", shortLink.ID).
+				Str("reason", input.Reason).
+				Msg("short link expired")
+		}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(b)
+		writeJSON(w, http.StatusOK, shortLinks)
 	}
 }
 
-func (s *AdminServer) apiRoute(method, path string, h http.HandlerFunc) {
-	labelsWithPath := prometheus.Labels{"path": path}
+// apiRoute registers an API handler, which can only be called with an auth
+// key that has the scope, within its rate limit. Every request is audited, see
+// WithAuditSink.
+func (s *AdminServer) apiRoute(method, path, scope string, h http.HandlerFunc) {
+	s.routes = append(s.routes, registeredRoute{method: method, path: path, scope: scope})
+	s.router.Handler(
+		method,
+		path,
+		withRequestID(
+			s.withAudit(
+				method,
+				path,
+				s.requireAuthToken(
+					scope,
+					s.limitRequests(
+						path,
+						withJSONTimeout(
+							http.TimeoutHandler(
+								instrumentHandler(path, h),
+								DefaultHandlerTimeoutDuration,
+								timeoutErrorBody,
+							),
+						),
+					),
+				),
+			),
+		),
+	)
+}
 
+// streamingAPIRoute is like apiRoute, but for handlers that stream their
+// response (which `http.TimeoutHandler` doesn't support) and may run for much
+// longer, up to the streaming timeout.
+func (s *AdminServer) streamingAPIRoute(method, path, scope string, h http.HandlerFunc) {
+	s.routes = append(s.routes, registeredRoute{method: method, path: path, scope: scope})
 	s.router.Handler(
 		method,
 		path,
-		s.requireAuthToken(
-			http.TimeoutHandler(
-				promhttp.InstrumentHandlerDuration(
-					debug.IncomingRequestDurations().MustCurryWith(labelsWithPath),
-					promhttp.InstrumentHandlerCounter(
-						debug.IncomingRequests().MustCurryWith(labelsWithPath),
-						h,
+		withStreamingDeadlines(
+			withRequestID(
+				s.withAudit(
+					method,
+					path,
+					s.requireAuthToken(
+						scope,
+						s.limitRequests(
+							path,
+							withTimeout(
+								instrumentHandler(path, h),
+								s.streamingTimeout,
+							),
+						),
 					),
 				),
-				DefaultHandlerTimeoutDuration,
-				"timed out",
-			).ServeHTTP,
+			),
+			s.streamingTimeout,
+		),
+	)
+}
+
+func instrumentHandler(path string, h http.Handler) http.Handler {
+	labelsWithPath := prometheus.Labels{"path": path}
+
+	return promhttp.InstrumentHandlerDuration(
+		debug.IncomingRequestDurations().MustCurryWith(labelsWithPath),
+		promhttp.InstrumentHandlerCounter(
+			debug.IncomingRequests().MustCurryWith(labelsWithPath),
+			h,
 		),
 	)
 }
 
+// withJSONTimeout makes sure the timeout response of `http.TimeoutHandler`
+// (timeoutErrorBody) has the right Content-Type. The Content-Type of other
+// responses is taken from the wrapped handler.
+func withJSONTimeout(h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+	}
+}
+
+// withStreamingDeadlines extends the read and write deadlines of the
+// connection, which are otherwise those of the server's ReadTimeout and
+// WriteTimeout, to the timeout for the request. It's outside the
+// authentication, as HMAC-signed requests have their body read by it.
+func withStreamingDeadlines(h http.Handler, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Now().Add(timeout)
+		rc := http.NewResponseController(w)
+		err := rc.SetReadDeadline(deadline)
+		if err == nil {
+			err = rc.SetWriteDeadline(deadline)
+		}
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Warn().Err(err).Str("path", r.URL.Path).Msg("failed to extend the connection deadlines, the server timeouts apply")
+		}
+
+		h.ServeHTTP(w, r)
+	}
+}
+
+func withTimeout(h http.Handler, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancelCtx := context.WithTimeout(r.Context(), timeout)
+		defer cancelCtx()
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
 func (s *AdminServer) Shutdown(ctx context.Context) error {
 	s.SetKeepAlivesEnabled(false)
+	if s.stopWatchingFiles != nil {
+		close(s.stopWatchingFiles)
+	}
+	if s.grpcServer != nil {
+		s.shutdownGRPC(ctx)
+	}
 	return s.Server.Shutdown(ctx)
 }
 
@@ -205,6 +615,22 @@ func WithListenAddr(addr string) func(*AdminServer) {
 	}
 }
 
+// WithGRPCListenAddr enables the gRPC admin API on the address, next to the
+// HTTP admin API, see ListenAndServeGRPC.
+func WithGRPCListenAddr(addr string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.grpcListenAddr = addr
+	}
+}
+
+// WithPublicBaseURL specifies the URL of the public server that short links
+// are served under, e.g. `https://sho.rt`, for their QR codes.
+func WithPublicBaseURL(publicBaseURL string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.publicBaseURL = publicBaseURL
+	}
+}
+
 func WithSlinkOptions(slinkOptions ...func(*slink.Slink)) func(*AdminServer) {
 	return func(s *AdminServer) {
 		s.slinkOptions = slinkOptions
@@ -216,3 +642,129 @@ func WithAuthKeys(authKeys []AuthKey) func(*AdminServer) {
 		s.authKeys = authKeys
 	}
 }
+
+// WithAuthKeysFile specifies a JSON file of auth keys, used in addition to the
+// ones from WithAuthKeys, which is reloaded when it changes.
+func WithAuthKeysFile(authKeysFile string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.authKeysFile = authKeysFile
+	}
+}
+
+// WithAuthKeysReloadInterval specifies how often the auth keys file and the
+// JWKS file are checked for changes.
+func WithAuthKeysReloadInterval(authKeysReloadInterval time.Duration) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.authKeysReloadInterval = authKeysReloadInterval
+	}
+}
+
+// WithStreamingTimeout specifies the time limit for handlers that stream their
+// request or response, e.g. bulk creation.
+func WithStreamingTimeout(streamingTimeout time.Duration) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.streamingTimeout = streamingTimeout
+	}
+}
+
+// WithBulkConcurrency specifies how many batches of rows a single bulk request
+// processes concurrently.
+func WithBulkConcurrency(bulkConcurrency int) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.bulkConcurrency = bulkConcurrency
+	}
+}
+
+// WithIdempotencyTTL specifies how long the response to a request with an
+// Idempotency-Key is kept for retries.
+func WithIdempotencyTTL(idempotencyTTL time.Duration) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.idempotencyTTL = idempotencyTTL
+	}
+}
+
+// WithHMACReplayWindow specifies how far the timestamp of a request signed
+// with the HMACAuthScheme can be from the current time, either way.
+func WithHMACReplayWindow(hmacReplayWindow time.Duration) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.hmacReplayWindow = hmacReplayWindow
+	}
+}
+
+// WithJWKSFile specifies a JSON Web Key Set file, which is reloaded when it
+// changes, enabling bearer tokens that are JWTs signed by one of its keys.
+// WithJWTIssuer and WithJWTAudience are required too.
+func WithJWKSFile(jwksFile string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.jwksFile = jwksFile
+	}
+}
+
+// WithJWTIssuer specifies the `iss` claim required in JWTs.
+func WithJWTIssuer(jwtIssuer string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.jwtIssuer = jwtIssuer
+	}
+}
+
+// WithJWTAudience specifies the audience required in the `aud` claim of JWTs.
+func WithJWTAudience(jwtAudience string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.jwtAudience = jwtAudience
+	}
+}
+
+// WithTLSCertFiles specifies the PEM certificate (chain) and key files to
+// serve TLS with, which are reloaded when they change.
+func WithTLSCertFiles(certFile, keyFile string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// WithTLSClientCAFile specifies a PEM bundle of the CAs verifying client
+// certificates. A request without an `Authorization` header, but with a
+// verified client certificate, is authenticated as the auth key with the
+// `mtls` scheme whose ID is the subject common name of the certificate.
+func WithTLSClientCAFile(tlsClientCAFile string) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.tlsClientCAFile = tlsClientCAFile
+	}
+}
+
+// WithTLSRequireClientCert specifies whether a verified client certificate is
+// required to connect, even for requests authenticated with a token.
+func WithTLSRequireClientCert(tlsRequireClientCert bool) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.tlsRequireClientCert = tlsRequireClientCert
+	}
+}
+
+// WithRateLimit limits each auth key to rate requests per second on average,
+// with bursts of up to burst requests (at least 1), unless the key has its own
+// RateLimit. A zero rate doesn't limit requests.
+func WithRateLimit(rate float64, burst int) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.rateLimit = rate
+		s.rateLimitBurst = burst
+	}
+}
+
+// WithDailyCreateQuota limits the number of short links each auth key can
+// create per day (UTC), unless the key has its own DailyCreateQuota. Zero
+// doesn't limit creation.
+func WithDailyCreateQuota(dailyCreateQuota int) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.dailyCreateQuota = dailyCreateQuota
+	}
+}
+
+// WithAuditSink specifies where an audit event is recorded for every API
+// request, with the auth key, the route, the short links, the outcome and the
+// client IP.
+func WithAuditSink(auditSink audit.Sink) func(*AdminServer) {
+	return func(s *AdminServer) {
+		s.auditSink = auditSink
+	}
+}
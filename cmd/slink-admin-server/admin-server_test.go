@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+)
+
+func TestListShortLinksByTag(t *testing.T) {
+	s := newTestAdminServer(t)
+	ctx := context.Background()
+
+	for _, input := range []*slink.CreateInput{
+		{ID: "aaa", LinkURL: "https://example.com/a", Tags: []string{"campaign", "summer sale"}},
+		{ID: "bbb", LinkURL: "https://example.com/b", Tags: []string{"campaign"}},
+		{ID: "ccc", LinkURL: "https://example.com/c"},
+		{ID: "ddd", LinkURL: "https://example.com/d", Tags: []string{"Campaign"}},
+	} {
+		_, err := s.svc.CreateShortLink(ctx, input)
+		if err != nil {
+			t.Fatalf("CreateShortLink(%s): %v", input.ID, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "tag in the path", path: "/short-links-by-tag/campaign", want: []string{"aaa", "bbb"}},
+		{name: "tag in the query", path: "/short-links?tag=campaign", want: []string{"aaa", "bbb"}},
+		{name: "escaped tag in the path", path: "/short-links-by-tag/summer%20sale", want: []string{"aaa"}},
+		{name: "tag in another case", path: "/short-links-by-tag/CAMPAIGN", want: []string{}},
+		{name: "tag in both", path: "/short-links-by-tag/Campaign?tag=campaign", want: []string{"ddd"}},
+		{name: "without tag", path: "/short-links", want: []string{"aaa", "bbb", "ccc", "ddd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer test")
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s: got status %d, want %d: %s", tt.path, rec.Code, http.StatusOK, rec.Body)
+			}
+			var page storage.ListPage
+			err := json.Unmarshal(rec.Body.Bytes(), &page)
+			if err != nil {
+				t.Fatalf("json.Unmarshal: %v", err)
+			}
+
+			got := []string{}
+			for _, shortLink := range page.ShortLinks {
+				got = append(got, shortLink.ID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GET %s: got %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateShortLinkInvalidTagsAndMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode string
+	}{
+		{name: "duplicate tag", body: `{"linkUrl":"https://example.com/","tags":["a","a"]}`, wantCode: ErrCodeInvalidTag},
+		{name: "tag with a comma", body: `{"linkUrl":"https://example.com/","tags":["a,b"]}`, wantCode: ErrCodeInvalidTag},
+		{name: "empty metadata key", body: `{"linkUrl":"https://example.com/","metadata":{"":"a"}}`, wantCode: ErrCodeInvalidMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAdminServer(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/create-short-link", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test")
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, req)
+
+			var apiErr APIError
+			_ = json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if rec.Code != http.StatusBadRequest || apiErr.Code != tt.wantCode {
+				t.Errorf("got status %d, code %q, want %d, %q: %s", rec.Code, apiErr.Code, http.StatusBadRequest, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
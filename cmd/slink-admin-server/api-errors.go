@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/qr"
+	"github.com/ronny/slink/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// APIError is the JSON body of every error response of the admin API. Clients
+// should branch on Code, which is stable, rather than on Message.
+type APIError struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"requestId,omitempty"`
+}
+
+// The error codes of APIError, and the HTTP status they're returned with.
+const (
+	ErrCodeInvalidRequest           = "invalid_request"             // 400, e.g. malformed JSON
+	ErrCodeInvalidLinkURL           = "invalid_link_url"            // 400, why in details.reason
+	ErrCodeInvalidShortLinkID       = "invalid_short_link_id"       // 400, including invalid custom aliases
+	ErrCodeInvalidTag               = "invalid_tag"                 // 400
+	ErrCodeInvalidMetadata          = "invalid_metadata"            // 400
+	ErrCodeInvalidActivatesAt       = "invalid_activates_at"        // 400
+	ErrCodeInvalidListQuery         = "invalid_list_query"          // 400
+	ErrCodeInvalidCursor            = "invalid_cursor"              // 400
+	ErrCodeInvalidQROptions         = "invalid_qr_options"          // 400
+	ErrCodeRedirectLoop             = "redirect_loop"               // 400, the link URL redirects through the short links in details.chain
+	ErrCodeUnauthorized             = "unauthorized"                // 401
+	ErrCodeForbidden                = "forbidden"                   // 403, the auth key is missing the scope in details.missingScope
+	ErrCodeNotFound                 = "not_found"                   // 404, unknown route
+	ErrCodeShortLinkNotFound        = "short_link_not_found"        // 404
+	ErrCodeMethodNotAllowed         = "method_not_allowed"          // 405
+	ErrCodeShortLinkAlreadyExists   = "short_link_already_exists"   // 409
+	ErrCodeShortLinkModified        = "short_link_modified"         // 409, retry after re-reading the short link
+	ErrCodeIdempotencyKeyInProgress = "idempotency_key_in_progress" // 409, can be retried
+	ErrCodeUnsupportedMediaType     = "unsupported_media_type"      // 415
+	ErrCodeIdempotencyKeyMismatch   = "idempotency_key_mismatch"    // 422
+	ErrCodeRateLimited              = "rate_limited"                // 429, retry after the Retry-After header
+	ErrCodeQuotaExceeded            = "quota_exceeded"              // 429, the daily create quota, retry after the Retry-After header
+	ErrCodeInternal                 = "internal_error"              // 500
+	ErrCodeCreateAttemptsExhausted  = "create_attempts_exhausted"   // 503, can be retried
+	ErrCodeTimeout                  = "timeout"                     // 503, can be retried
+)
+
+// newAPIError maps err to an APIError and its HTTP status. Errors that aren't
+// known to be caused by the client are logged and reported as internal errors
+// without any details.
+func newAPIError(ctx context.Context, err error) (int, *APIError) {
+	apiErr := &APIError{
+		Message:   err.Error(),
+		RequestID: requestIDFromContext(ctx),
+	}
+
+	var (
+		inverr  *slink.ErrInvalidLinkURL
+		iderr   *slink.ErrInvalidShortLinkID
+		tagerr  *slink.ErrInvalidTag
+		mderr   *slink.ErrInvalidMetadata
+		acterr  *slink.ErrInvalidActivatesAt
+		lqerr   *slink.ErrInvalidListQuery
+		curerr  *storage.ErrInvalidCursor
+		nferr   *slink.ErrShortLinkNotFound
+		exerr   *storage.ErrShortLinkAlreadyExists
+		moderr  *storage.ErrShortLinkModified
+		exhterr *slink.ErrCreateAttemptsExhausted
+		quoterr *storage.ErrQuotaExceeded
+		qrerr   *qr.ErrInvalidOptions
+		looperr *slink.ErrRedirectLoop
+	)
+
+	switch {
+	case errors.As(err, &inverr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidLinkURL, inverr.Error()
+		if inverr.Reason != "" {
+			apiErr.Details = map[string]any{"reason": inverr.Reason}
+		}
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &iderr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidShortLinkID, iderr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &tagerr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidTag, tagerr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &mderr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidMetadata, mderr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &acterr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidActivatesAt, acterr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &lqerr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidListQuery, lqerr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &curerr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidCursor, curerr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &looperr):
+		apiErr.Code, apiErr.Message = ErrCodeRedirectLoop, looperr.Error()
+		apiErr.Details = map[string]any{"chain": looperr.Chain}
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &qrerr):
+		apiErr.Code, apiErr.Message = ErrCodeInvalidQROptions, qrerr.Error()
+		return http.StatusBadRequest, apiErr
+	case errors.As(err, &nferr):
+		apiErr.Code, apiErr.Message = ErrCodeShortLinkNotFound, nferr.Error()
+		return http.StatusNotFound, apiErr
+	case errors.As(err, &exerr):
+		apiErr.Code, apiErr.Message = ErrCodeShortLinkAlreadyExists, exerr.Error()
+		apiErr.Details = map[string]any{"shortLinkId": exerr.ShortLinkID}
+		return http.StatusConflict, apiErr
+	case errors.As(err, &moderr):
+		apiErr.Code, apiErr.Message = ErrCodeShortLinkModified, moderr.Error()
+		apiErr.Details = map[string]any{"shortLinkId": moderr.ShortLinkID}
+		return http.StatusConflict, apiErr
+	case errors.As(err, &exhterr):
+		apiErr.Code, apiErr.Message = ErrCodeCreateAttemptsExhausted, exhterr.Error()
+		return http.StatusServiceUnavailable, apiErr
+	case errors.As(err, &quoterr):
+		apiErr.Code, apiErr.Message = ErrCodeQuotaExceeded, fmt.Sprintf("the auth key has used up its daily quota of %d short links", quoterr.Limit)
+		apiErr.Details = map[string]any{"limit": quoterr.Limit}
+		return http.StatusTooManyRequests, apiErr
+	}
+
+	log.Error().Err(err).Str("requestID", apiErr.RequestID).Msg("internal error, returning 500")
+
+	apiErr.Code, apiErr.Message = ErrCodeInternal, "internal error"
+	return http.StatusInternalServerError, apiErr
+}
+
+// writeError writes the APIError that err maps to, see newAPIError.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var qerr *storage.ErrQuotaExceeded
+	if errors.As(err, &qerr) {
+		countQuotaExceeded(r.Context())
+		setQuotaExceededHeaders(w, qerr, time.Now())
+	}
+	status, apiErr := newAPIError(r.Context(), err)
+	writeJSON(w, status, apiErr)
+}
+
+// writeErrorCode writes an APIError that isn't mapped from an error value,
+// e.g. for invalid request bodies.
+func writeErrorCode(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, status, &APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: requestIDFromContext(r.Context()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("json.Marshal response failed, returning 500")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// timeoutErrorBody is the body written by `http.TimeoutHandler`, which doesn't
+// have access to the request ID.
+var timeoutErrorBody = func() string {
+	b, _ := json.Marshal(&APIError{Code: ErrCodeTimeout, Message: "timed out"})
+	return string(b)
+}()
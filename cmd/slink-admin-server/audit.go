@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ronny/slink/audit"
+	"github.com/ronny/slink/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// the maximum number of short link IDs in an audit event, e.g. of a bulk
+	// creation, the rest are only counted
+	auditMaxShortLinkIDs = 1000
+	// the time limit for recording an audit event, which happens after the
+	// request has been handled (or has timed out)
+	auditRecordTimeout = 5 * time.Second
+)
+
+// auditedRequest is the audit event of a request while it's handled, which
+// can be added to by the handler, possibly after the request has timed out.
+type auditedRequest struct {
+	mu    sync.Mutex
+	event audit.Event
+}
+
+type auditCtxKeyType struct{}
+
+var auditCtxKey = auditCtxKeyType{}
+
+// withAudit records an audit event for every request to the route with the
+// audit sink, once the request has been handled, see WithAuditSink.
+//
+// The event has the auth key ID (set by requireAuthToken), and the short link
+// ID in the path or the ones added by the handler with auditShortLinks.
+func (s *AdminServer) withAudit(method, path string, h http.HandlerFunc) http.HandlerFunc {
+	if s.auditSink == nil {
+		return h
+	}
+	route := method + " " + path
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ar := newAuditedRequest(ctx, route, clientIPOf(r), r.Header.Get("X-Forwarded-For"), httprouter.ParamsFromContext(ctx).ByName("id"))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		// also records the requests whose handler panics, e.g. an export
+		// aborted with `http.ErrAbortHandler`, as failed whatever their status
+		defer func() {
+			v := recover()
+			statusCode := rec.statusCode
+			if v != nil {
+				statusCode = http.StatusInternalServerError
+			} else if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+			s.recordAudit(ar, statusCode)
+			if v != nil {
+				panic(v)
+			}
+		}()
+
+		h(rec, r.WithContext(context.WithValue(ctx, auditCtxKey, ar)))
+	}
+}
+
+// newAuditedRequest starts the audit event of a request, with the short link
+// ID of the request if any.
+func newAuditedRequest(reqCtx context.Context, route, clientIP, forwardedFor, shortLinkID string) *auditedRequest {
+	ar := &auditedRequest{
+		event: audit.Event{
+			Time:         time.Now().UTC().Format(time.RFC3339),
+			RequestID:    requestIDFromContext(reqCtx),
+			Route:        route,
+			ClientIP:     clientIP,
+			ForwardedFor: forwardedFor,
+		},
+	}
+	if shortLinkID != "" {
+		ar.event.ShortLinkIDs = []string{shortLinkID}
+		ar.event.ShortLinkCount = 1
+	}
+	return ar
+}
+
+// recordAudit records the audit event of a request once it has been handled,
+// with the (HTTP) status code of the response.
+func (s *AdminServer) recordAudit(ar *auditedRequest, statusCode int) {
+	ar.mu.Lock()
+	event := ar.event
+	event.ShortLinkIDs = append([]string(nil), ar.event.ShortLinkIDs...)
+	ar.mu.Unlock()
+
+	event.StatusCode = statusCode
+	event.Outcome = audit.OutcomeOf(statusCode)
+
+	recordCtx, cancelRecordCtx := context.WithTimeout(context.Background(), auditRecordTimeout)
+	defer cancelRecordCtx()
+
+	err := s.auditSink.Record(recordCtx, &event)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("requestID", event.RequestID).
+			Str("keyID", event.KeyID).
+			Str("route", event.Route).
+			Strs("shortLinkIDs", event.ShortLinkIDs).
+			Str("outcome", event.Outcome).
+			Msg("auditSink.Record failed")
+	}
+}
+
+func auditedRequestFromContext(reqCtx context.Context) *auditedRequest {
+	ar, _ := reqCtx.Value(auditCtxKey).(*auditedRequest)
+	return ar
+}
+
+// auditKeyID records the ID of the auth key of the request in its audit event,
+// if it's audited.
+func auditKeyID(reqCtx context.Context, keyID string) {
+	if ar := auditedRequestFromContext(reqCtx); ar != nil {
+		ar.mu.Lock()
+		ar.event.KeyID = keyID
+		ar.mu.Unlock()
+	}
+}
+
+// auditShortLinks records the short links created, viewed or changed by the
+// request in its audit event, if it's audited.
+func auditShortLinks(reqCtx context.Context, shortLinks ...*models.ShortLink) {
+	ar := auditedRequestFromContext(reqCtx)
+	if ar == nil {
+		return
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	for _, shortLink := range shortLinks {
+		ar.event.ShortLinkCount++
+		if len(ar.event.ShortLinkIDs) < auditMaxShortLinkIDs {
+			ar.event.ShortLinkIDs = append(ar.event.ShortLinkIDs, shortLink.ID)
+		}
+	}
+}
+
+// clientIPOf returns the IP address of the peer of the request.
+func clientIPOf(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder passes a response through, keeping its status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// loadAuthKeysFile reads a JSON array of AuthKeys, in the same format as the
+// `-auth-keys` flag.
+func loadAuthKeysFile(filename string) ([]AuthKey, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var authKeys []AuthKey
+	err = json.Unmarshal(b, &authKeys)
+	if err != nil {
+		// not wrapped, as the error could include parts of the file
+		return nil, errors.New("invalid auth keys JSON, must be an array like [{\"id\": \"foo\", \"tokenHash\": \"sha256:...\"}]")
+	}
+	return authKeys, nil
+}
+
+// loadAuthKeys replaces the auth keys in use with the ones from WithAuthKeys
+// and from the auth keys file. The auth keys in use are kept when the new ones
+// are invalid.
+func (s *AdminServer) loadAuthKeys() error {
+	authKeys := append([]AuthKey(nil), s.authKeys...)
+
+	if s.authKeysFile != "" {
+		fileAuthKeys, err := loadAuthKeysFile(s.authKeysFile)
+		if err != nil {
+			return fmt.Errorf("loadAuthKeysFile %s: %w", s.authKeysFile, err)
+		}
+		authKeys = append(authKeys, fileAuthKeys...)
+	}
+
+	if len(authKeys) == 0 && s.jwksFile == "" {
+		return errors.New("missing authKeys, use WithAuthKeys or WithAuthKeysFile to set at least one, or WithJWKSFile")
+	}
+
+	ks, err := newAuthKeySet(authKeys)
+	if err != nil {
+		return err
+	}
+
+	s.authKeySet.Store(ks)
+	return nil
+}
+
+// watchFile calls reload when the modification time or size of the file
+// changes, checking every interval until stop is closed. The file is
+// described by what in logs.
+func watchFile(filename string, interval time.Duration, stop <-chan struct{}, what string, reload func() error) {
+	var lastModTime time.Time
+	var lastSize int64
+	if fi, err := os.Stat(filename); err == nil {
+		lastModTime, lastSize = fi.ModTime(), fi.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil {
+			log.Error().Err(err).Str("filename", filename).Msgf("os.Stat %s failed, keeping the current one", what)
+			continue
+		}
+		if fi.ModTime().Equal(lastModTime) && fi.Size() == lastSize {
+			continue
+		}
+		lastModTime, lastSize = fi.ModTime(), fi.Size()
+
+		err = reload()
+		if err != nil {
+			log.Error().Err(err).Str("filename", filename).Msgf("reloading %s failed, keeping the current one", what)
+			continue
+		}
+		log.Info().Str("filename", filename).Msgf("%s reloaded", what)
+	}
+}
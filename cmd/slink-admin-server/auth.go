@@ -2,45 +2,391 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthKey struct {
-	ID    string `json:"id"`
-	Token string `json:"token"`
+	ID string `json:"id"`
+	// Scheme is how requests are authenticated with the key, either `bearer`
+	// (the default) with the token, `hmac` by signing requests with the
+	// Secret, see HMACAuthScheme, or `mtls` with a client certificate whose
+	// subject common name is the ID, see WithTLSClientCAFile.
+	Scheme string `json:"scheme,omitempty"`
+	// Secret is the shared secret of a key with the `hmac` scheme, at least 32
+	// characters long.
+	Secret string `json:"secret,omitempty"`
+	// TokenHash is the hash of the token, either `sha256:<hex digest>` or a
+	// bcrypt hash (`$2a$...`, slow, only for tokens that aren't random). The
+	// token of a key with a bcrypt hash is sent prefixed by the ID and a dot,
+	// e.g. `ops.<token>`, so that only its hash is checked.
+	TokenHash string `json:"tokenHash,omitempty"`
+	// Token is the plaintext token, only for backward compatibility, use
+	// TokenHash instead.
+	Token string `json:"token,omitempty"`
+	// Scopes limits the routes the key can call, see the Scope constants. A
+	// key without scopes can call every route, like before scopes existed.
+	Scopes []string `json:"scopes,omitempty"`
+	// The key is only valid from NotBefore and until ExpiresAt (both RFC3339,
+	// optional), so that keys can be rotated by overlapping them.
+	NotBefore string `json:"notBefore,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// RateLimit (requests per second) and RateLimitBurst override the rate
+	// limit of the server for the key, see WithRateLimit.
+	RateLimit      float64 `json:"rateLimit,omitempty"`
+	RateLimitBurst int     `json:"rateLimitBurst,omitempty"`
+	// DailyCreateQuota overrides the daily create quota of the server for the
+	// key, see WithDailyCreateQuota.
+	DailyCreateQuota int `json:"dailyCreateQuota,omitempty"`
+}
+
+// The scopes required by the API routes.
+const (
+	// ScopeLinksRead allows getting, listing and exporting short links.
+	ScopeLinksRead = "links:read"
+	// ScopeLinksWrite allows creating short links.
+	ScopeLinksWrite = "links:write"
+	// ScopeLinksAdmin allows changing existing short links (updating and
+	// expiring), and implies every other scope.
+	ScopeLinksAdmin = "links:admin"
+)
+
+var knownScopes = map[string]bool{
+	ScopeLinksRead:  true,
+	ScopeLinksWrite: true,
+	ScopeLinksAdmin: true,
 }
 
-func (s *AdminServer) requireAuthToken(h http.HandlerFunc) http.HandlerFunc {
-	idsByToken := make(map[string]string)
-	for _, authKey := range s.authKeys {
-		idsByToken[authKey.Token] = authKey.ID
+// The schemes of AuthKeys.
+const (
+	AuthSchemeBearer = "bearer"
+	AuthSchemeHMAC   = "hmac"
+	AuthSchemeMTLS   = "mtls"
+)
+
+const sha256TokenHashPrefix = "sha256:"
+
+// HasScope returns true if the key is allowed to call routes requiring scope.
+func (k *AuthKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		// only configured keys have every scope by default
+		return k.Scheme != AuthSchemeJWT
+	}
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeLinksAdmin {
+			return true
+		}
 	}
+	return false
+}
+
+// authKeySet is an immutable set of auth keys, replaced as a whole when the
+// auth keys file is reloaded.
+type authKeySet struct {
+	// the keys with the bearer scheme and a SHA-256 hash
+	entries []*authKeyEntry
+	// the keys with the bearer scheme and a bcrypt hash, which have unique IDs
+	bcryptEntriesByID map[string]*authKeyEntry
+	// the keys with the hmac scheme, there can be more than one per ID while
+	// rotating them
+	hmacEntriesByID map[string][]*authKeyEntry
+	// the keys with the mtls scheme, by the common name of the client
+	// certificates
+	mtlsEntriesByID map[string][]*authKeyEntry
 
+	// the SHA-256 digests of tokens that matched a bcrypt hash, so that bcrypt
+	// only runs once per valid token
+	bcryptMatchesMu sync.Mutex
+	bcryptMatches   map[[sha256.Size]byte]*authKeyEntry
+}
+
+type authKeyEntry struct {
+	key       *AuthKey
+	digest    []byte // the SHA-256 digest of the token, or nil for bcrypt
+	bcrypt    []byte
+	secret    []byte // for the hmac scheme
+	mtls      bool
+	notBefore time.Time
+	expiresAt time.Time
+}
+
+func newAuthKeySet(authKeys []AuthKey) (*authKeySet, error) {
+	ks := &authKeySet{
+		bcryptEntriesByID: make(map[string]*authKeyEntry),
+		hmacEntriesByID:   make(map[string][]*authKeyEntry),
+		mtlsEntriesByID:   make(map[string][]*authKeyEntry),
+		bcryptMatches:     make(map[[sha256.Size]byte]*authKeyEntry),
+	}
+
+	for i := range authKeys {
+		entry, err := newAuthKeyEntry(&authKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		if entry.secret != nil {
+			ks.hmacEntriesByID[entry.key.ID] = append(ks.hmacEntriesByID[entry.key.ID], entry)
+			continue
+		}
+		if entry.mtls {
+			ks.mtlsEntriesByID[entry.key.ID] = append(ks.mtlsEntriesByID[entry.key.ID], entry)
+			continue
+		}
+		if entry.bcrypt != nil {
+			// rotated by replacing the key with one with another ID, so that
+			// a token is only ever checked against one bcrypt hash
+			if _, ok := ks.bcryptEntriesByID[entry.key.ID]; ok {
+				return nil, fmt.Errorf("auth key %q has a bcrypt tokenHash, and another auth key with the same id", entry.key.ID)
+			}
+			ks.bcryptEntriesByID[entry.key.ID] = entry
+			continue
+		}
+		ks.entries = append(ks.entries, entry)
+	}
+	return ks, nil
+}
+
+func newAuthKeyEntry(authKey *AuthKey) (*authKeyEntry, error) {
+	if authKey.ID == "" {
+		return nil, errors.New("auth key must have an id")
+	}
+
+	entry := &authKeyEntry{key: authKey}
+
+	switch authKey.Scheme {
+	case "", AuthSchemeBearer:
+		if authKey.Secret != "" {
+			return nil, fmt.Errorf("auth key %q has a secret, which is only for the %s scheme", authKey.ID, AuthSchemeHMAC)
+		}
+	case AuthSchemeHMAC:
+		if authKey.Token != "" || authKey.TokenHash != "" {
+			return nil, fmt.Errorf("auth key %q with the %s scheme must have a secret, not a token", authKey.ID, AuthSchemeHMAC)
+		}
+		if len(authKey.Secret) < hmacMinSecretLength {
+			return nil, fmt.Errorf("auth key %q must have a secret of at least %d characters", authKey.ID, hmacMinSecretLength)
+		}
+		entry.secret = []byte(authKey.Secret)
+	case AuthSchemeMTLS:
+		if authKey.Token != "" || authKey.TokenHash != "" || authKey.Secret != "" {
+			return nil, fmt.Errorf("auth key %q with the %s scheme is authenticated by a client certificate, it can't have a token or a secret", authKey.ID, AuthSchemeMTLS)
+		}
+		entry.mtls = true
+	default:
+		// including AuthSchemeJWT, which can't be configured
+		return nil, fmt.Errorf("auth key %q has unknown scheme %q", authKey.ID, authKey.Scheme)
+	}
+
+	switch {
+	case entry.secret != nil, entry.mtls:
+	case authKey.Token != "" && authKey.TokenHash != "":
+		return nil, fmt.Errorf("auth key %q must have either a token or a tokenHash, not both", authKey.ID)
+	case authKey.Token != "":
+		log.Warn().Str("keyID", authKey.ID).Msg("auth key has a plaintext token, use tokenHash instead")
+		digest := sha256.Sum256([]byte(authKey.Token))
+		entry.digest = digest[:]
+	case strings.HasPrefix(authKey.TokenHash, sha256TokenHashPrefix):
+		digest, err := hex.DecodeString(strings.TrimPrefix(authKey.TokenHash, sha256TokenHashPrefix))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("auth key %q has an invalid sha256 tokenHash, must be %s followed by 64 hex digits", authKey.ID, sha256TokenHashPrefix)
+		}
+		entry.digest = digest
+	case strings.HasPrefix(authKey.TokenHash, "$2"):
+		_, err := bcrypt.Cost([]byte(authKey.TokenHash))
+		if err != nil {
+			return nil, fmt.Errorf("auth key %q has an invalid bcrypt tokenHash: %w", authKey.ID, err)
+		}
+		if strings.Contains(authKey.ID, ".") {
+			return nil, fmt.Errorf("auth key %q has a bcrypt tokenHash, so its id can't contain dots", authKey.ID)
+		}
+		entry.bcrypt = []byte(authKey.TokenHash)
+	default:
+		return nil, fmt.Errorf("auth key %q must have a tokenHash, either %s<hex digest> or a bcrypt hash", authKey.ID, sha256TokenHashPrefix)
+	}
+
+	for _, scope := range authKey.Scopes {
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("auth key %q has unknown scope %q", authKey.ID, scope)
+		}
+	}
+
+	if authKey.RateLimit < 0 || authKey.RateLimitBurst < 0 || authKey.DailyCreateQuota < 0 {
+		return nil, fmt.Errorf("auth key %q can't have a negative rateLimit, rateLimitBurst or dailyCreateQuota", authKey.ID)
+	}
+
+	var err error
+	if authKey.NotBefore != "" {
+		entry.notBefore, err = time.Parse(time.RFC3339, authKey.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("auth key %q has an invalid notBefore: %w", authKey.ID, err)
+		}
+	}
+	if authKey.ExpiresAt != "" {
+		entry.expiresAt, err = time.Parse(time.RFC3339, authKey.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("auth key %q has an invalid expiresAt: %w", authKey.ID, err)
+		}
+	}
+
+	return entry, nil
+}
+
+func (e *authKeyEntry) validAt(now time.Time) bool {
+	if !e.notBefore.IsZero() && now.Before(e.notBefore) {
+		return false
+	}
+	if !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+		return false
+	}
+	return true
+}
+
+// authenticate returns the auth key with the token that's valid at now, or nil.
+//
+// The digest of the token is compared with every SHA-256 hash in constant
+// time, so that the time taken doesn't reveal how close the token is to any of
+// them. When none of those match, the token is checked against the bcrypt hash
+// of the key with the ID it's prefixed by, if any, so that an invalid token
+// costs at most one (slow) bcrypt comparison.
+func (ks *authKeySet) authenticate(token string, now time.Time) *AuthKey {
+	if token == "" {
+		return nil
+	}
+	digest := sha256.Sum256([]byte(token))
+
+	var matches []*authKeyEntry
+	for _, entry := range ks.entries {
+		if entry.digest != nil && subtle.ConstantTimeCompare(digest[:], entry.digest) == 1 {
+			matches = append(matches, entry)
+		}
+	}
+
+	if len(matches) == 0 {
+		matches = ks.bcryptMatch(digest, token)
+	}
+
+	// more than one key can have the same token, e.g. with different validity
+	for _, entry := range matches {
+		if entry.validAt(now) {
+			return entry.key
+		}
+	}
+
+	if len(matches) > 0 {
+		log.Debug().Str("keyID", matches[0].key.ID).Msg("auth key is not yet valid or has expired")
+	}
+	return nil
+}
+
+// bcryptMatch returns the key with a bcrypt hash matching a token in the
+// `<id>.<token>` format, if any.
+func (ks *authKeySet) bcryptMatch(digest [sha256.Size]byte, token string) []*authKeyEntry {
+	ks.bcryptMatchesMu.Lock()
+	entry, ok := ks.bcryptMatches[digest]
+	ks.bcryptMatchesMu.Unlock()
+	if ok {
+		return []*authKeyEntry{entry}
+	}
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil
+	}
+	entry, ok = ks.bcryptEntriesByID[id]
+	if !ok {
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword(entry.bcrypt, []byte(secret)) != nil {
+		return nil
+	}
+
+	ks.bcryptMatchesMu.Lock()
+	ks.bcryptMatches[digest] = entry
+	ks.bcryptMatchesMu.Unlock()
+	return []*authKeyEntry{entry}
+}
+
+// requireAuthToken only lets requests through with the token of a currently
+// valid auth key that has the scope, signed by one (see HMACAuthScheme), or
+// without an `Authorization` header but with the client certificate of one.
+func (s *AdminServer) requireAuthToken(scope string, h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeaderVal := r.Header.Get("Authorization")
-		token := bearerPrefixRe.ReplaceAllString(authHeaderVal, "")
-		id := idsByToken[token]
 
-		if id == "" {
-			http.Error(w, "insert coin", http.StatusUnauthorized)
+		var authKey *AuthKey
+		errMsg := "missing or invalid auth token"
+		if authHeaderVal == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			authKey, errMsg = s.authenticateClientCert(r.TLS.VerifiedChains[0][0], time.Now())
+		} else if params, ok := cutAuthScheme(authHeaderVal, HMACAuthScheme); ok {
+			authKey, errMsg = s.authenticateHMAC(w, r, params, time.Now())
+		} else {
+			authKey, errMsg = s.authenticateBearer(authHeaderVal, time.Now())
+		}
+
+		if authKey == nil {
+			writeErrorCode(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, errMsg)
 			return
 		}
+		auditKeyID(r.Context(), authKey.ID)
 
-		h(w, r.WithContext(context.WithValue(r.Context(), ctxKey, id)))
+		if !authKey.HasScope(scope) {
+			writeJSON(w, http.StatusForbidden, &APIError{
+				Code:      ErrCodeForbidden,
+				Message:   fmt.Sprintf("the auth key is missing the %s scope", scope),
+				Details:   map[string]any{"missingScope": scope},
+				RequestID: requestIDFromContext(r.Context()),
+			})
+			return
+		}
+
+		h(w, r.WithContext(context.WithValue(r.Context(), ctxKey, authKey)))
 	}
 }
 
 var bearerPrefixRe = regexp.MustCompile(`(?i)^Bearer\s+`)
 
-type keyIDCtxKey struct{}
+// authenticateBearer returns the AuthKey of a bearer token (with or without
+// the `Bearer` prefix), which is either a JWT or the token of an auth key, or
+// an error message for the client.
+func (s *AdminServer) authenticateBearer(authHeaderVal string, now time.Time) (*AuthKey, string) {
+	token := bearerPrefixRe.ReplaceAllString(authHeaderVal, "")
+	if s.jwksFile != "" && looksLikeJWT(token) {
+		return s.authenticateJWT(token, now)
+	}
+	return s.authKeySet.Load().authenticate(token, now), "missing or invalid auth token"
+}
 
-var ctxKey = keyIDCtxKey{}
+// cutAuthScheme returns the parameters of an `Authorization` header value if
+// it has the scheme.
+func cutAuthScheme(authHeaderVal, scheme string) (string, bool) {
+	if len(authHeaderVal) <= len(scheme) || !strings.EqualFold(authHeaderVal[:len(scheme)], scheme) || authHeaderVal[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(authHeaderVal[len(scheme):]), true
+}
+
+type authKeyCtxKey struct{}
+
+var ctxKey = authKeyCtxKey{}
+
+// authKeyFromContext returns the auth key of the request, or nil.
+func authKeyFromContext(reqCtx context.Context) *AuthKey {
+	authKey, _ := reqCtx.Value(ctxKey).(*AuthKey)
+	return authKey
+}
 
 func authKeyIDFromContext(reqCtx context.Context) string {
-	val := reqCtx.Value(ctxKey)
-	if id, ok := val.(string); ok {
-		return id
+	if authKey := authKeyFromContext(reqCtx); authKey != nil {
+		return authKey.ID
 	}
 	return ""
 }
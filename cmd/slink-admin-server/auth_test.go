@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func sha256TokenHash(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return sha256TokenHashPrefix + hex.EncodeToString(digest[:])
+}
+
+func bcryptTokenHash(t *testing.T, token string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword: %v", err)
+	}
+	return string(hash)
+}
+
+func TestAuthKeySetAuthenticate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ks, err := newAuthKeySet([]AuthKey{
+		{ID: "ci", TokenHash: sha256TokenHash("ci-token")},
+		{ID: "legacy", Token: "legacy-token"},
+		{ID: "ops", TokenHash: bcryptTokenHash(t, "correct horse")},
+		{ID: "expired", TokenHash: sha256TokenHash("expired-token"), ExpiresAt: "2024-05-01T12:00:00Z"},
+		{ID: "future", TokenHash: sha256TokenHash("future-token"), NotBefore: "2024-05-01T12:00:01Z"},
+		// rotating: the old and new keys have the same ID
+		{ID: "dashboard", TokenHash: sha256TokenHash("old-token"), ExpiresAt: "2024-05-02T00:00:00Z"},
+		{ID: "dashboard", TokenHash: sha256TokenHash("new-token"), NotBefore: "2024-04-30T00:00:00Z"},
+	})
+	if err != nil {
+		t.Fatalf("newAuthKeySet: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantID string
+	}{
+		{"sha256 hash", "ci-token", "ci"},
+		{"sha256 hash, wrong token", "ci-token2", ""},
+		{"plaintext token", "legacy-token", "legacy"},
+		{"bcrypt hash", "ops.correct horse", "ops"},
+		{"bcrypt hash, without the ID", "correct horse", ""},
+		{"bcrypt hash, wrong token", "ops.correct horse battery", ""},
+		{"bcrypt hash, unknown ID", "nobody.correct horse", ""},
+		{"bcrypt hash, ID of a sha256 key", "ci.correct horse", ""},
+		{"expired", "expired-token", ""},
+		{"not yet valid", "future-token", ""},
+		{"rotating, old token", "old-token", "dashboard"},
+		{"rotating, new token", "new-token", "dashboard"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// twice, the second time with the bcrypt match cached
+			for i := 0; i < 2; i++ {
+				var gotID string
+				if authKey := ks.authenticate(tt.token, now); authKey != nil {
+					gotID = authKey.ID
+				}
+				if gotID != tt.wantID {
+					t.Errorf("authenticate(%q): got key %q, want %q", tt.token, gotID, tt.wantID)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAuthKeySetRejectsInvalidKeys(t *testing.T) {
+	bcryptHash := bcryptTokenHash(t, "correct horse")
+
+	tests := []struct {
+		name    string
+		keys    []AuthKey
+		wantErr string
+	}{
+		{"missing id", []AuthKey{{TokenHash: sha256TokenHash("t")}}, "must have an id"},
+		{"token and tokenHash", []AuthKey{{ID: "a", Token: "t", TokenHash: sha256TokenHash("t")}}, "not both"},
+		{"invalid sha256 hash", []AuthKey{{ID: "a", TokenHash: "sha256:abc"}}, "invalid sha256 tokenHash"},
+		{"invalid bcrypt hash", []AuthKey{{ID: "a", TokenHash: "$2b$nope"}}, "invalid bcrypt tokenHash"},
+		{"unknown hash", []AuthKey{{ID: "a", TokenHash: "md5:abc"}}, "must have a tokenHash"},
+		{"bcrypt id with a dot", []AuthKey{{ID: "a.b", TokenHash: bcryptHash}}, "can't contain dots"},
+		{"bcrypt id not unique", []AuthKey{{ID: "a", TokenHash: bcryptHash}, {ID: "a", TokenHash: bcryptHash}}, "same id"},
+		{"unknown scope", []AuthKey{{ID: "a", TokenHash: sha256TokenHash("t"), Scopes: []string{"links:delete"}}}, "unknown scope"},
+		{"invalid expiresAt", []AuthKey{{ID: "a", TokenHash: sha256TokenHash("t"), ExpiresAt: "tomorrow"}}, "invalid expiresAt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAuthKeySet(tt.keys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newAuthKeySet: got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthKeysFileReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "auth-keys.json")
+	writeFile := func(content string) {
+		t.Helper()
+		err := os.WriteFile(filename, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+	}
+	writeFile(`[{"id": "old", "tokenHash": "` + sha256TokenHash("old-token") + `"}]`)
+
+	s, err := NewAdminServer(context.Background(),
+		WithAuthKeysFile(filename),
+		WithAuthKeysReloadInterval(time.Hour),
+		WithSlinkOptions(slink.WithStorage(storage.NewMemoryStorage())),
+	)
+	if err != nil {
+		t.Fatalf("NewAdminServer: %v", err)
+	}
+	defer s.Shutdown(context.Background())
+
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/short-links", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := status("old-token"); got != http.StatusOK {
+		t.Fatalf("old token before reload: got status %d, want %d", got, http.StatusOK)
+	}
+
+	writeFile(`[{"id": "new", "tokenHash": "` + sha256TokenHash("new-token") + `"}]`)
+	err = s.loadAuthKeys()
+	if err != nil {
+		t.Fatalf("loadAuthKeys: %v", err)
+	}
+	if got := status("old-token"); got != http.StatusUnauthorized {
+		t.Errorf("old token after reload: got status %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := status("new-token"); got != http.StatusOK {
+		t.Errorf("new token after reload: got status %d, want %d", got, http.StatusOK)
+	}
+
+	// invalid auth keys are not loaded, the current ones are kept
+	writeFile(`[{"id": "broken", "tokenHash": "sha256:nope"}]`)
+	err = s.loadAuthKeys()
+	if err == nil {
+		t.Errorf("loadAuthKeys with an invalid file: got no error")
+	}
+	if got := status("new-token"); got != http.StatusOK {
+		t.Errorf("new token after a failed reload: got status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestAuthKeyHasScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   AuthKey
+		scope string
+		want  bool
+	}{
+		{"no scopes", AuthKey{}, ScopeLinksAdmin, true},
+		{"no scopes, JWT", AuthKey{Scheme: AuthSchemeJWT}, ScopeLinksRead, false},
+		{"read, read", AuthKey{Scopes: []string{ScopeLinksRead}}, ScopeLinksRead, true},
+		{"read, write", AuthKey{Scopes: []string{ScopeLinksRead}}, ScopeLinksWrite, false},
+		{"read, admin", AuthKey{Scopes: []string{ScopeLinksRead}}, ScopeLinksAdmin, false},
+		{"write, read", AuthKey{Scopes: []string{ScopeLinksWrite}}, ScopeLinksRead, false},
+		{"read and write, write", AuthKey{Scopes: []string{ScopeLinksRead, ScopeLinksWrite}}, ScopeLinksWrite, true},
+		{"admin, read", AuthKey{Scopes: []string{ScopeLinksAdmin}}, ScopeLinksRead, true},
+		{"admin, write", AuthKey{Scopes: []string{ScopeLinksAdmin}}, ScopeLinksWrite, true},
+		{"admin, JWT", AuthKey{Scheme: AuthSchemeJWT, Scopes: []string{ScopeLinksAdmin}}, ScopeLinksWrite, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q) with scopes %v: got %v, want %v", tt.scope, tt.key.Scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAuthTokenChecksScopes(t *testing.T) {
+	s, err := NewAdminServer(context.Background(),
+		WithAuthKeys([]AuthKey{
+			{ID: "reader", TokenHash: sha256TokenHash("reader-token"), Scopes: []string{ScopeLinksRead}},
+			{ID: "writer", TokenHash: sha256TokenHash("writer-token"), Scopes: []string{ScopeLinksWrite}},
+			{ID: "admin", TokenHash: sha256TokenHash("admin-token"), Scopes: []string{ScopeLinksAdmin}},
+		}),
+		WithSlinkOptions(slink.WithStorage(storage.NewMemoryStorage())),
+	)
+	if err != nil {
+		t.Fatalf("NewAdminServer: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		token            string
+		method, path     string
+		body             string
+		wantStatus       int
+		wantMissingScope string
+	}{
+		{"reader lists", "reader-token", http.MethodGet, "/short-links", "", http.StatusOK, ""},
+		{"reader creates", "reader-token", http.MethodPost, "/create-short-link", `{"linkUrl":"https://example.com/"}`, http.StatusForbidden, ScopeLinksWrite},
+		{"reader expires", "reader-token", http.MethodPost, "/short-link/abc/expire", `{}`, http.StatusForbidden, ScopeLinksAdmin},
+		{"writer lists", "writer-token", http.MethodGet, "/short-links", "", http.StatusForbidden, ScopeLinksRead},
+		{"writer creates", "writer-token", http.MethodPost, "/create-short-link", `{"linkUrl":"https://example.com/"}`, http.StatusOK, ""},
+		{"writer updates", "writer-token", http.MethodPatch, "/short-link/abc", `{"linkUrl":"https://example.com/"}`, http.StatusForbidden, ScopeLinksAdmin},
+		{"admin lists", "admin-token", http.MethodGet, "/short-links", "", http.StatusOK, ""},
+		{"admin creates", "admin-token", http.MethodPost, "/create-short-link", `{"linkUrl":"https://example.com/admin"}`, http.StatusOK, ""},
+		{"unknown token", "nope", http.MethodGet, "/short-links", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s %s: got status %d, want %d: %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantMissingScope == "" {
+				return
+			}
+
+			var apiErr APIError
+			err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			if err != nil {
+				t.Fatalf("json.Unmarshal: %v", err)
+			}
+			if apiErr.Code != ErrCodeForbidden || apiErr.Details["missingScope"] != tt.wantMissingScope {
+				t.Errorf("got error %s with details %v, want %s with missingScope %s", apiErr.Code, apiErr.Details, ErrCodeForbidden, tt.wantMissingScope)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	bulkPath = "/bulk-create-short-links"
+	// the maximum size of a bulk request body
+	bulkMaxBodyBytes = 32 << 20
+	// the number of rows created together with `slink.CreateShortLinks`
+	bulkBatchSize = 25
+)
+
+type bulkRow struct {
+	row   int
+	input *slink.CreateInput
+	err   error
+}
+
+type bulkResult struct {
+	Row       int               `json:"row"`
+	ShortLink *models.ShortLink `json:"shortLink,omitempty"`
+	Error     *APIError         `json:"error,omitempty"`
+}
+
+// handleBulkCreateShortLinks reads rows of `slink.CreateInput` as NDJSON
+// (`application/x-ndjson`) or CSV (`text/csv`, with a header row naming the
+// columns after the JSON fields, in the same format as the CSV export), and
+// streams back a result for each row as NDJSON, in no particular order.
+//
+// The whole body is read before the response starts, as the HTTP/1.x server
+// discards the unread part of the body once the response headers are sent.
+//
+// The `mode` query parameter selects whether each row is created
+// unconditionally (`create`, the default) or with `get-or-create`.
+func (s *AdminServer) handleBulkCreateShortLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var getOrCreate bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "create":
+		case "get-or-create":
+			getOrCreate = true
+		default:
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("unknown mode %q, must be either create or get-or-create", mode))
+			return
+		}
+
+		body := http.MaxBytesReader(w, r.Body, bulkMaxBodyBytes)
+
+		var readRow func() (*bulkRow, error)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-ndjson", "application/jsonl", "application/json":
+			readRow = ndjsonRowReader(body)
+		case "text/csv":
+			var err error
+			readRow, err = csvRowReader(body)
+			if err != nil {
+				writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+				return
+			}
+		default:
+			writeErrorCode(w, r, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Content-Type must be either application/x-ndjson or text/csv")
+			return
+		}
+
+		batchSize := bulkBatchSize
+		if getOrCreate {
+			batchSize = 1
+		}
+
+		var rows []*bulkRow
+		for {
+			row, err := readRow()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// the rest of the body is unreadable
+				rows = append(rows, &bulkRow{row: row.row, err: err})
+				break
+			}
+			rows = append(rows, row)
+		}
+
+		batches := make(chan []*bulkRow)
+		results := make(chan *bulkResult)
+
+		go func() {
+			defer close(batches)
+
+			batch := make([]*bulkRow, 0, batchSize)
+			for _, row := range rows {
+				batch = append(batch, row)
+				if len(batch) == batchSize {
+					select {
+					case batches <- batch:
+					case <-ctx.Done():
+						return
+					}
+					batch = make([]*bulkRow, 0, batchSize)
+				}
+			}
+
+			if len(batch) > 0 {
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < s.bulkConcurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := range batches {
+					s.processBulkBatch(ctx, batch, getOrCreate, results)
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+		var failed int
+		var quotaExceeded bool
+		for result := range results {
+			if result.Error != nil {
+				failed++
+				quotaExceeded = quotaExceeded || result.Error.Code == ErrCodeQuotaExceeded
+			} else {
+				auditShortLinks(ctx, result.ShortLink)
+			}
+
+			err := encoder.Encode(result)
+			if err != nil {
+				// keep draining results so that the workers can finish
+				log.Debug().Err(err).Int("row", result.Row).Msg("handleBulkCreateShortLinks: failed to write result")
+				continue
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if quotaExceeded {
+			// counted once, as for the other requests
+			countQuotaExceeded(ctx)
+		}
+
+		log.Info().
+			Str("keyID", authKeyIDFromContext(ctx)).
+			Bool("getOrCreate", getOrCreate).
+			Int("failed", failed).
+			Msg("bulk create finished")
+	}
+}
+
+func (s *AdminServer) processBulkBatch(ctx context.Context, batch []*bulkRow, getOrCreate bool, results chan<- *bulkResult) {
+	inputs := make([]*slink.CreateInput, 0, len(batch))
+	rows := make([]int, 0, len(batch))
+
+	for _, row := range batch {
+		if row.err != nil {
+			results <- &bulkResult{
+				Row: row.row,
+				Error: &APIError{
+					Code:    ErrCodeInvalidRequest,
+					Message: row.err.Error(),
+				},
+			}
+			continue
+		}
+		row.input.CreatedBy = authKeyIDFromContext(ctx)
+
+		if getOrCreate {
+			shortLink, err := s.svc.GetOrCreateShortLink(ctx, row.input)
+			results <- newBulkResult(ctx, row.row, shortLink, err)
+			continue
+		}
+
+		inputs = append(inputs, row.input)
+		rows = append(rows, row.row)
+	}
+
+	if len(inputs) == 0 {
+		return
+	}
+
+	for i, result := range s.svc.CreateShortLinks(ctx, inputs) {
+		results <- newBulkResult(ctx, rows[i], result.ShortLink, result.Err)
+	}
+}
+
+func newBulkResult(ctx context.Context, row int, shortLink *models.ShortLink, err error) *bulkResult {
+	if err != nil {
+		_, apiErr := newAPIError(ctx, err)
+		// the request ID is the same for every row
+		apiErr.RequestID = ""
+		return &bulkResult{Row: row, Error: apiErr}
+	}
+	return &bulkResult{Row: row, ShortLink: shortLink}
+}
+
+// ndjsonRowReader returns a function that reads a `slink.CreateInput` from
+// each non-empty line.
+func ndjsonRowReader(body io.Reader) func() (*bulkRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var row int
+	return func() (*bulkRow, error) {
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			row++
+			var input slink.CreateInput
+			err := json.Unmarshal(line, &input)
+			if err != nil {
+				return &bulkRow{row: row, err: err}, nil
+			}
+			return &bulkRow{row: row, input: &input}, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return &bulkRow{row: row + 1}, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// csvRowReader reads the header row, then returns a function that reads a
+// `slink.CreateInput` from each following row.
+func csvRowReader(body io.Reader) (func() (*bulkRow, error), error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header row: %w", err)
+	}
+
+	for _, column := range header {
+		switch column {
+		case "linkUrl", "expiresAt", "activatesAt", "comingSoonUrl", "id", "tags", "metadata":
+		case "createdAt", "createdBy":
+			// ignored, so that a CSV export can be imported as is
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+
+	var row int
+	return func() (*bulkRow, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+
+		row++
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				// the reader can carry on with the next row
+				return &bulkRow{row: row, err: err}, nil
+			}
+			return &bulkRow{row: row}, err
+		}
+
+		if len(record) != len(header) {
+			return &bulkRow{row: row, err: fmt.Errorf("expected %d columns, got %d", len(header), len(record))}, nil
+		}
+
+		var input slink.CreateInput
+		for i, column := range header {
+			switch column {
+			case "linkUrl":
+				input.LinkURL = record[i]
+			case "expiresAt":
+				input.ExpiresAt = record[i]
+			case "activatesAt":
+				input.ActivatesAt = record[i]
+			case "comingSoonUrl":
+				input.ComingSoonURL = record[i]
+			case "id":
+				input.ID = record[i]
+			case "tags":
+				if record[i] != "" {
+					input.Tags = strings.Split(record[i], ",")
+				}
+			case "metadata":
+				if record[i] != "" {
+					err := json.Unmarshal([]byte(record[i]), &input.Metadata)
+					if err != nil {
+						return &bulkRow{row: row, err: fmt.Errorf("metadata must be a JSON object of strings: %w", err)}, nil
+					}
+				}
+			}
+		}
+		return &bulkRow{row: row, input: &input}, nil
+	}, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBulkCreateReadsTheWholeBody(t *testing.T) {
+	// the HTTP/1.x server discards up to 256KB of unread body once the
+	// response headers are sent, and closes the connection after bigger ones
+	tests := []struct {
+		name string
+		rows int
+	}{
+		{"about 100KB", 2000},
+		{"about 250KB", 4800},
+		{"about 600KB", 11000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAdminServer(t)
+			server := httptest.NewServer(s.Handler)
+			defer server.Close()
+
+			var body strings.Builder
+			for i := 0; i < tt.rows; i++ {
+				fmt.Fprintf(&body, `{"linkUrl":"https://example.com/bulk/%d?page=%d"}`+"\n", i, i)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+bulkPath, strings.NewReader(body.String()))
+			if err != nil {
+				t.Fatalf("http.NewRequest: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer test")
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("POST %s: %v", bulkPath, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST %s: got status %d, want %d", bulkPath, resp.StatusCode, http.StatusOK)
+			}
+
+			seen := make(map[int]bool, tt.rows)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				var result bulkResult
+				err := json.Unmarshal(scanner.Bytes(), &result)
+				if err != nil {
+					t.Fatalf("json.Unmarshal: %v", err)
+				}
+				if result.Error != nil {
+					t.Errorf("row %d: unexpected error %s: %s", result.Row, result.Error.Code, result.Error.Message)
+				}
+				if seen[result.Row] {
+					t.Errorf("row %d: more than one result", result.Row)
+				}
+				seen[result.Row] = true
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("reading results: %v", err)
+			}
+
+			if len(seen) != tt.rows {
+				t.Errorf("got results for %d rows, want %d", len(seen), tt.rows)
+			}
+			for row := 1; row <= tt.rows; row++ {
+				if !seen[row] {
+					t.Errorf("row %d: no result", row)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestStreamingRoutesOutliveTheServerTimeouts(t *testing.T) {
+	tests := []struct {
+		path    string
+		rows    int
+		wantErr bool
+	}{
+		{path: bulkPath, rows: 8},
+		// the other routes keep the server timeouts
+		{path: "/create-short-link", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			s := newTestAdminServer(t)
+			server := httptest.NewUnstartedServer(s.Handler)
+			server.Config.ReadTimeout = 100 * time.Millisecond
+			server.Config.WriteTimeout = 100 * time.Millisecond
+			server.Start()
+			defer server.Close()
+
+			// sends the body over about 400ms
+			body, bodyWriter := io.Pipe()
+			go func() {
+				for i := 0; i < 8; i++ {
+					time.Sleep(50 * time.Millisecond)
+					if tt.rows > 0 {
+						fmt.Fprintf(bodyWriter, `{"linkUrl":"https://example.com/slow/%d"}`+"\n", i)
+					} else if i == 0 {
+						fmt.Fprint(bodyWriter, `{"linkUrl":`)
+					}
+				}
+				if tt.rows == 0 {
+					fmt.Fprint(bodyWriter, `"https://example.com/slow"}`)
+				}
+				bodyWriter.Close()
+			}()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+tt.path, body)
+			if err != nil {
+				t.Fatalf("http.NewRequest: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer test")
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			resp, err := server.Client().Do(req)
+			if err == nil {
+				defer resp.Body.Close()
+			}
+			if tt.wantErr {
+				if err == nil && resp.StatusCode == http.StatusOK {
+					t.Fatalf("POST %s: got status %d, want it cut off by the server timeouts", tt.path, resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("POST %s: %v", tt.path, err)
+			}
+
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading results: %v", err)
+			}
+			results := strings.Count(string(b), "\n")
+			if resp.StatusCode != http.StatusOK || results != tt.rows {
+				t.Errorf("POST %s: got status %d with %d results, want %d with %d", tt.path, resp.StatusCode, results, http.StatusOK, tt.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// authenticateClientCert returns the auth key with the `mtls` scheme whose ID
+// is the subject common name of the verified client certificate, or an error
+// message for the client.
+func (s *AdminServer) authenticateClientCert(cert *x509.Certificate, now time.Time) (*AuthKey, string) {
+	commonName := cert.Subject.CommonName
+	if commonName == "" {
+		return nil, "the client certificate has no subject common name"
+	}
+
+	for _, entry := range s.authKeySet.Load().mtlsEntriesByID[commonName] {
+		if entry.validAt(now) {
+			return entry.key, ""
+		}
+	}
+	return nil, "no valid auth key for the client certificate"
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ronny/slink/models"
+	"github.com/rs/zerolog/log"
+)
+
+// the number of exported rows between flushes of the response
+const exportFlushInterval = 100
+
+var shortLinkCSVHeader = []string{"id", "linkUrl", "createdAt", "expiresAt", "activatesAt", "comingSoonUrl", "tags", "metadata", "createdBy"}
+
+// shortLinkCSVRecord returns the CSV columns of a ShortLink, with the tags
+// comma separated (tags can't contain commas), and the metadata as a JSON
+// object.
+func shortLinkCSVRecord(shortLink *models.ShortLink) []string {
+	var metadata string
+	if len(shortLink.Metadata) > 0 {
+		b, err := json.Marshal(shortLink.Metadata)
+		if err == nil {
+			metadata = string(b)
+		}
+	}
+
+	return []string{
+		shortLink.ID,
+		shortLink.LinkURL,
+		shortLink.CreatedAt,
+		shortLink.ExpiresAt,
+		shortLink.ActivatesAt,
+		shortLink.ComingSoonURL,
+		strings.Join(shortLink.Tags, ","),
+		metadata,
+		shortLink.CreatedBy,
+	}
+}
+
+// handleExportShortLinks streams every ShortLink as NDJSON (the default) or
+// CSV, selected by the `format` query parameter.
+//
+// Errors after the response has started can only be signalled by cutting the
+// response short, so the handler is aborted with `http.ErrAbortHandler`, and
+// the connection is closed without ending the chunked response. Clients then
+// get a read error (e.g. an unexpected EOF) rather than an export that looks
+// complete.
+func (s *AdminServer) handleExportShortLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "", "ndjson":
+			format = "ndjson"
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+		default:
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("unknown format %q, must be either ndjson or csv", format))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		var (
+			write func(*models.ShortLink) error
+			flush func() error
+		)
+		if format == "csv" {
+			writer := csv.NewWriter(w)
+			write = func(shortLink *models.ShortLink) error {
+				return writer.Write(shortLinkCSVRecord(shortLink))
+			}
+			flush = func() error {
+				writer.Flush()
+				return writer.Error()
+			}
+			writer.Write(shortLinkCSVHeader)
+		} else {
+			encoder := json.NewEncoder(w)
+			write = func(shortLink *models.ShortLink) error {
+				return encoder.Encode(shortLink)
+			}
+			flush = func() error { return nil }
+		}
+
+		flusher, _ := w.(http.Flusher)
+		var count int
+		err := s.svc.ExportShortLinks(ctx, func(shortLink *models.ShortLink) error {
+			err := write(shortLink)
+			if err != nil {
+				return err
+			}
+
+			count++
+			if count%exportFlushInterval == 0 {
+				err = flush()
+				if err != nil {
+					return err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			log.Error().Err(err).Int("count", count).Msg("svc.ExportShortLinks error, aborting the incomplete export")
+			panic(http.ErrAbortHandler)
+		}
+
+		log.Info().
+			Str("keyID", authKeyIDFromContext(ctx)).
+			Int("count", count).
+			Msg("export finished")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/audit"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+)
+
+// failingExportStorage fails ForEach after failAfter ShortLinks.
+type failingExportStorage struct {
+	*storage.MemoryStorage
+	failAfter int
+}
+
+func (s *failingExportStorage) ForEach(ctx context.Context, fn func(*models.ShortLink) error) error {
+	var count int
+	return s.MemoryStorage.ForEach(ctx, func(shortLink *models.ShortLink) error {
+		if count == s.failAfter {
+			return errors.New("storage unavailable")
+		}
+		count++
+		return fn(shortLink)
+	})
+}
+
+// testAuditSink keeps the recorded events.
+type testAuditSink struct {
+	mu     sync.Mutex
+	events []*audit.Event
+}
+
+func (sink *testAuditSink) Record(ctx context.Context, event *audit.Event) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+func TestExportShortLinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		failAfter int
+		wantLines int
+		wantErr   bool
+	}{
+		{name: "ndjson", format: "ndjson", failAfter: -1, wantLines: 250},
+		{name: "csv", format: "csv", failAfter: -1, wantLines: 251},
+		{name: "ndjson, failing before the first flush", format: "ndjson", failAfter: 10, wantErr: true},
+		{name: "ndjson, failing after flushes", format: "ndjson", failAfter: 220, wantErr: true},
+		{name: "csv, failing after flushes", format: "csv", failAfter: 220, wantErr: true},
+	}
+	checkAudit := func(t *testing.T, sink *testAuditSink, wantOutcome string) {
+		t.Helper()
+
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		if len(sink.events) != 1 || sink.events[0].Outcome != wantOutcome {
+			t.Errorf("got audit events %+v, want one with outcome %s", sink.events, wantOutcome)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &failingExportStorage{MemoryStorage: storage.NewMemoryStorage(), failAfter: tt.failAfter}
+			auditSink := &testAuditSink{}
+			s, err := NewAdminServer(context.Background(),
+				WithAuthKeys([]AuthKey{{ID: "test", Token: "test"}}),
+				WithAuditSink(auditSink),
+				WithSlinkOptions(slink.WithStorage(store)),
+			)
+			if err != nil {
+				t.Fatalf("NewAdminServer: %v", err)
+			}
+			for i := 0; i < 250; i++ {
+				_, err := s.svc.CreateShortLink(context.Background(), &slink.CreateInput{LinkURL: fmt.Sprintf("https://example.com/%d", i)})
+				if err != nil {
+					t.Fatalf("CreateShortLink: %v", err)
+				}
+			}
+
+			server := httptest.NewServer(s.Handler)
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/export-short-links?format="+tt.format, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer test")
+
+			// when it fails before the first flush, there's no response at all
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("GET /export-short-links: %v", err)
+				}
+				checkAudit(t, auditSink, audit.OutcomeFailure)
+				return
+			}
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got a complete export of %d lines, want a read error", strings.Count(string(b), "\n"))
+				}
+				checkAudit(t, auditSink, audit.OutcomeFailure)
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading the export: %v", err)
+			}
+			if lines := strings.Count(string(b), "\n"); lines != tt.wantLines {
+				t.Errorf("got %d lines, want %d", lines, tt.wantLines)
+			}
+			checkAudit(t, auditSink, audit.OutcomeSuccess)
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/adminpb"
+	"github.com/ronny/slink/debug"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// grpcErrorDomain is the domain of the ErrorInfo details of gRPC errors, whose
+// reason is the code of the equivalent APIError.
+const grpcErrorDomain = "slink"
+
+// grpcMethodScopes are the scopes required by the methods of the gRPC admin
+// API, like the ones of the equivalent HTTP routes.
+var grpcMethodScopes = map[string]string{
+	"/slink.admin.v1.AdminService/CreateShortLink":      ScopeLinksWrite,
+	"/slink.admin.v1.AdminService/GetOrCreateShortLink": ScopeLinksWrite,
+	"/slink.admin.v1.AdminService/GetShortLinkByID":     ScopeLinksRead,
+	"/slink.admin.v1.AdminService/GetShortLinksByURL":   ScopeLinksRead,
+}
+
+// grpcAdminService implements the gRPC admin API with the same Slink as the
+// HTTP admin API. Requests are authenticated by interceptGRPC.
+type grpcAdminService struct {
+	adminpb.UnimplementedAdminServiceServer
+	s *AdminServer
+}
+
+func (g *grpcAdminService) CreateShortLink(ctx context.Context, req *adminpb.CreateShortLinkRequest) (*adminpb.ShortLink, error) {
+	input := createInputFromProto(req)
+	input.CreatedBy = authKeyIDFromContext(ctx)
+
+	shortLink, err := g.s.svc.CreateShortLink(ctx, input)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	auditShortLinks(ctx, shortLink)
+
+	return shortLinkToProto(shortLink), nil
+}
+
+func (g *grpcAdminService) GetOrCreateShortLink(ctx context.Context, req *adminpb.CreateShortLinkRequest) (*adminpb.ShortLink, error) {
+	input := createInputFromProto(req)
+	input.CreatedBy = authKeyIDFromContext(ctx)
+
+	shortLink, err := g.s.svc.GetOrCreateShortLink(ctx, input)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	auditShortLinks(ctx, shortLink)
+
+	return shortLinkToProto(shortLink), nil
+}
+
+func (g *grpcAdminService) GetShortLinkByID(ctx context.Context, req *adminpb.GetShortLinkByIDRequest) (*adminpb.ShortLink, error) {
+	shortLink, err := g.s.svc.GetShortLinkByID(ctx, req.Id)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	if shortLink == nil {
+		return nil, newGRPCError(codes.NotFound, &APIError{
+			Code:      ErrCodeShortLinkNotFound,
+			Message:   "short link not found",
+			RequestID: requestIDFromContext(ctx),
+		}, 0)
+	}
+
+	return shortLinkToProto(shortLink), nil
+}
+
+func (g *grpcAdminService) GetShortLinksByURL(ctx context.Context, req *adminpb.GetShortLinksByURLRequest) (*adminpb.GetShortLinksByURLResponse, error) {
+	shortLinks, err := g.s.svc.GetShortLinksByURL(ctx, req.LinkUrl)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	auditShortLinks(ctx, shortLinks...)
+
+	resp := &adminpb.GetShortLinksByURLResponse{
+		ShortLinks: make([]*adminpb.ShortLink, 0, len(shortLinks)),
+	}
+	for _, shortLink := range shortLinks {
+		resp.ShortLinks = append(resp.ShortLinks, shortLinkToProto(shortLink))
+	}
+	return resp, nil
+}
+
+func createInputFromProto(req *adminpb.CreateShortLinkRequest) *slink.CreateInput {
+	return &slink.CreateInput{
+		LinkURL:       req.LinkUrl,
+		ExpiresAt:     req.ExpiresAt,
+		ActivatesAt:   req.ActivatesAt,
+		ComingSoonURL: req.ComingSoonUrl,
+		ID:            req.Id,
+		Tags:          req.Tags,
+		Metadata:      req.Metadata,
+	}
+}
+
+func shortLinkToProto(shortLink *models.ShortLink) *adminpb.ShortLink {
+	return &adminpb.ShortLink{
+		Id:            shortLink.ID,
+		LinkUrl:       shortLink.LinkURL,
+		CreatedAt:     shortLink.CreatedAt,
+		ExpiresAt:     shortLink.ExpiresAt,
+		ActivatesAt:   shortLink.ActivatesAt,
+		ComingSoonUrl: shortLink.ComingSoonURL,
+		Tags:          shortLink.Tags,
+		Metadata:      shortLink.Metadata,
+		CreatedBy:     shortLink.CreatedBy,
+	}
+}
+
+// interceptGRPC does for every gRPC request what the middlewares of apiRoute do
+// for HTTP requests: it identifies, audits, authenticates, limits, times out
+// and instruments the request.
+func (s *AdminServer) interceptGRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := firstMetadataValue(md, requestIDHeader)
+	if !requestIDRe.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	ctx = context.WithValue(ctx, requestIDCtxKey, requestID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+
+	var ar *auditedRequest
+	if s.auditSink != nil {
+		var shortLinkID string
+		if r, ok := req.(*adminpb.GetShortLinkByIDRequest); ok {
+			shortLinkID = r.Id
+		}
+		ar = newAuditedRequest(ctx, "gRPC "+info.FullMethod, grpcClientIPOf(ctx), firstMetadataValue(md, "X-Forwarded-For"), shortLinkID)
+		ctx = context.WithValue(ctx, auditCtxKey, ar)
+	}
+
+	resp, err := s.authorizeGRPC(ctx, md, req, info, handler)
+
+	statusCode := httpStatusOfGRPCCode(status.Code(err))
+	debug.IncomingRequests().WithLabelValues(strconv.Itoa(statusCode), info.FullMethod).Inc()
+	debug.IncomingRequestDurations().WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	if ar != nil {
+		s.recordAudit(ar, statusCode)
+	}
+
+	return resp, err
+}
+
+// authorizeGRPC calls the handler if the request is authenticated with an auth
+// key that has the scope of the method, and is within its limits.
+func (s *AdminServer) authorizeGRPC(ctx context.Context, md metadata.MD, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	now := time.Now()
+
+	scope, ok := grpcMethodScopes[info.FullMethod]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", info.FullMethod)
+	}
+
+	authKey, errMsg := s.authenticateGRPC(ctx, md, now)
+	if authKey == nil {
+		return nil, newGRPCError(codes.Unauthenticated, &APIError{
+			Code:      ErrCodeUnauthorized,
+			Message:   errMsg,
+			RequestID: requestIDFromContext(ctx),
+		}, 0)
+	}
+	auditKeyID(ctx, authKey.ID)
+
+	if !authKey.HasScope(scope) {
+		return nil, newGRPCError(codes.PermissionDenied, &APIError{
+			Code:      ErrCodeForbidden,
+			Message:   fmt.Sprintf("the auth key is missing the %s scope", scope),
+			Details:   map[string]any{"missingScope": scope},
+			RequestID: requestIDFromContext(ctx),
+		}, 0)
+	}
+	ctx = context.WithValue(ctx, ctxKey, authKey)
+	ctx = context.WithValue(ctx, routePathCtxKey{}, info.FullMethod)
+
+	err := s.limitGRPCRequest(ctx, info.FullMethod, authKey, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancelCtx := context.WithTimeout(ctx, DefaultHandlerTimeoutDuration)
+	defer cancelCtx()
+
+	return handler(ctx, req)
+}
+
+// authenticateGRPC returns the AuthKey of the bearer token in the
+// `authorization` metadata, or of the client certificate without one, or an
+// error message for the client. Signed (HMAC) requests aren't supported, as
+// the signature covers the HTTP request.
+func (s *AdminServer) authenticateGRPC(ctx context.Context, md metadata.MD, now time.Time) (*AuthKey, string) {
+	authHeaderVal := firstMetadataValue(md, "Authorization")
+	if authHeaderVal == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+				return s.authenticateClientCert(tlsInfo.State.VerifiedChains[0][0], now)
+			}
+		}
+		return nil, "missing or invalid auth token"
+	}
+
+	if _, ok := cutAuthScheme(authHeaderVal, HMACAuthScheme); ok {
+		return nil, fmt.Sprintf("%s is not supported by the gRPC API, use a bearer token or a client certificate", HMACAuthScheme)
+	}
+	return s.authenticateBearer(authHeaderVal, now)
+}
+
+// limitGRPCRequest is like limitRequests, failing with RESOURCE_EXHAUSTED and
+// a RetryInfo detail.
+func (s *AdminServer) limitGRPCRequest(ctx context.Context, method string, authKey *AuthKey, now time.Time) error {
+	if rate, burst := s.rateLimitOf(authKey); rate > 0 {
+		ok, _, wait := s.tokenBucketOf(authKey.ID, rate, burst, now).take(now)
+		if !ok {
+			debug.ThrottledRequests().WithLabelValues(method, "rate").Inc()
+			return newGRPCError(codes.ResourceExhausted, &APIError{
+				Code:      ErrCodeRateLimited,
+				Message:   fmt.Sprintf("the auth key is limited to %g requests per second", rate),
+				Details:   map[string]any{"retryAfterSeconds": ceilSeconds(wait)},
+				RequestID: requestIDFromContext(ctx),
+			}, wait)
+		}
+	}
+
+	return nil
+}
+
+// grpcError maps err to a gRPC status error, like newAPIError does for HTTP
+// responses.
+func grpcError(ctx context.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newGRPCError(codes.DeadlineExceeded, &APIError{
+			Code:      ErrCodeTimeout,
+			Message:   "timed out",
+			RequestID: requestIDFromContext(ctx),
+		}, 0)
+	}
+
+	var retryDelay time.Duration
+	var qerr *storage.ErrQuotaExceeded
+	if errors.As(err, &qerr) {
+		countQuotaExceeded(ctx)
+		now := time.Now()
+		retryDelay = startOfNextDay(now).Sub(now)
+	}
+
+	statusCode, apiErr := newAPIError(ctx, err)
+	return newGRPCError(grpcCodeOfAPIError(statusCode, apiErr.Code), apiErr, retryDelay)
+}
+
+// newGRPCError returns a gRPC status error with the message of the APIError,
+// and an ErrorInfo detail with its code as the reason and its details (and
+// request ID) as the metadata. With retryAfter, it has a RetryInfo detail too.
+func newGRPCError(code codes.Code, apiErr *APIError, retryAfter time.Duration) error {
+	info := &errdetails.ErrorInfo{
+		Reason:   apiErr.Code,
+		Domain:   grpcErrorDomain,
+		Metadata: map[string]string{},
+	}
+	for key, value := range apiErr.Details {
+		info.Metadata[key] = fmt.Sprint(value)
+	}
+	if apiErr.RequestID != "" {
+		info.Metadata["requestId"] = apiErr.RequestID
+	}
+
+	st := status.New(code, apiErr.Message)
+	withDetails, err := st.WithDetails(info)
+	if err != nil {
+		return st.Err()
+	}
+	if retryAfter > 0 {
+		withRetry, err := withDetails.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+		if err == nil {
+			withDetails = withRetry
+		}
+	}
+	return withDetails.Err()
+}
+
+// grpcCodeOfAPIError returns the gRPC code of an APIError with the HTTP
+// status.
+func grpcCodeOfAPIError(statusCode int, apiErrCode string) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		if apiErrCode == ErrCodeShortLinkAlreadyExists {
+			return codes.AlreadyExists
+		}
+		return codes.Aborted
+	case http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// httpStatusOfGRPCCode returns the HTTP status equivalent to a gRPC code, for
+// metrics and audit events.
+func httpStatusOfGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound, codes.Unimplemented:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusUnprocessableEntity
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func firstMetadataValue(md metadata.MD, key string) string {
+	// metadata.MD.Get lowercases the key
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcClientIPOf returns the IP address of the peer of the request.
+func grpcClientIPOf(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// newGRPCServer sets up the gRPC admin API, with the same TLS configuration as
+// the HTTP admin API (including client certificates).
+func (s *AdminServer) newGRPCServer() {
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.interceptGRPC),
+	}
+	if s.TLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.TLSConfig)))
+	}
+
+	s.grpcServer = grpc.NewServer(options...)
+	adminpb.RegisterAdminServiceServer(s.grpcServer, &grpcAdminService{s: s})
+}
+
+// ListenAndServeGRPC serves the gRPC admin API on the gRPC listen address,
+// see WithGRPCListenAddr. It returns nil after Shutdown.
+func (s *AdminServer) ListenAndServeGRPC() error {
+	if s.grpcServer == nil {
+		return errors.New("the gRPC admin API isn't enabled, see WithGRPCListenAddr")
+	}
+
+	lis, err := net.Listen("tcp", s.grpcListenAddr)
+	if err != nil {
+		return err
+	}
+	return s.grpcServer.Serve(lis)
+}
+
+// shutdownGRPC stops the gRPC server gracefully, or abruptly when ctx is done
+// first.
+func (s *AdminServer) shutdownGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+}
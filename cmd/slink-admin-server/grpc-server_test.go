@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     codes.Code
+		wantReason   string
+		wantMetadata map[string]string
+		wantRetry    bool
+	}{
+		{name: "invalid link URL", err: &slink.ErrInvalidLinkURL{Reason: slink.LinkURLReasonBlocked}, wantCode: codes.InvalidArgument, wantReason: ErrCodeInvalidLinkURL, wantMetadata: map[string]string{"reason": slink.LinkURLReasonBlocked}},
+		{name: "invalid tag", err: &slink.ErrInvalidTag{}, wantCode: codes.InvalidArgument, wantReason: ErrCodeInvalidTag},
+		{name: "redirect loop", err: &slink.ErrRedirectLoop{Chain: []string{"aaa", "aaa"}}, wantCode: codes.InvalidArgument, wantReason: ErrCodeRedirectLoop, wantMetadata: map[string]string{"chain": "[aaa aaa]"}},
+		{name: "not found", err: &slink.ErrShortLinkNotFound{}, wantCode: codes.NotFound, wantReason: ErrCodeShortLinkNotFound},
+		{name: "already exists", err: fmt.Errorf("storage.Create: %w", &storage.ErrShortLinkAlreadyExists{ShortLinkID: "abc"}), wantCode: codes.AlreadyExists, wantReason: ErrCodeShortLinkAlreadyExists, wantMetadata: map[string]string{"shortLinkId": "abc"}},
+		{name: "modified", err: &storage.ErrShortLinkModified{ShortLinkID: "abc"}, wantCode: codes.Aborted, wantReason: ErrCodeShortLinkModified, wantMetadata: map[string]string{"shortLinkId": "abc"}},
+		{name: "quota exceeded", err: &storage.ErrQuotaExceeded{Key: "create:test", Limit: 5}, wantCode: codes.ResourceExhausted, wantReason: ErrCodeQuotaExceeded, wantMetadata: map[string]string{"limit": "5"}, wantRetry: true},
+		{name: "create attempts exhausted", err: &slink.ErrCreateAttemptsExhausted{}, wantCode: codes.Unavailable, wantReason: ErrCodeCreateAttemptsExhausted},
+		{name: "deadline exceeded", err: fmt.Errorf("storage.Get: %w", context.DeadlineExceeded), wantCode: codes.DeadlineExceeded, wantReason: ErrCodeTimeout},
+		{name: "other", err: errors.New("boom"), wantCode: codes.Internal, wantReason: ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), requestIDCtxKey, "req-1")
+			st := status.Convert(grpcError(ctx, tt.err))
+
+			var info *errdetails.ErrorInfo
+			var retry *errdetails.RetryInfo
+			for _, detail := range st.Details() {
+				switch detail := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = detail
+				case *errdetails.RetryInfo:
+					retry = detail
+				}
+			}
+			if st.Code() != tt.wantCode || info == nil || info.Reason != tt.wantReason || info.Domain != grpcErrorDomain {
+				t.Fatalf("got code %s, error info %v, want %s, reason %q", st.Code(), info, tt.wantCode, tt.wantReason)
+			}
+			if info.Metadata["requestId"] != "req-1" {
+				t.Errorf("got request ID %q, want %q", info.Metadata["requestId"], "req-1")
+			}
+			for key, want := range tt.wantMetadata {
+				if info.Metadata[key] != want {
+					t.Errorf("got metadata %s %q, want %q", key, info.Metadata[key], want)
+				}
+			}
+			if (retry != nil) != tt.wantRetry || (retry != nil && retry.RetryDelay.AsDuration() <= 0) {
+				t.Errorf("got retry info %v, want one: %t", retry, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestGRPCCodeOfAPIError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		apiErrCode string
+		want       codes.Code
+	}{
+		{http.StatusBadRequest, ErrCodeInvalidRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, ErrCodeUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, ErrCodeForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, ErrCodeShortLinkNotFound, codes.NotFound},
+		{http.StatusConflict, ErrCodeShortLinkAlreadyExists, codes.AlreadyExists},
+		{http.StatusConflict, ErrCodeIdempotencyKeyInProgress, codes.Aborted},
+		{http.StatusUnprocessableEntity, ErrCodeIdempotencyKeyMismatch, codes.FailedPrecondition},
+		{http.StatusTooManyRequests, ErrCodeRateLimited, codes.ResourceExhausted},
+		{http.StatusServiceUnavailable, ErrCodeTimeout, codes.Unavailable},
+		{http.StatusInternalServerError, ErrCodeInternal, codes.Internal},
+		{http.StatusTeapot, "", codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.statusCode, tt.apiErrCode), func(t *testing.T) {
+			got := grpcCodeOfAPIError(tt.statusCode, tt.apiErrCode)
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
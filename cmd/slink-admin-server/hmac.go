@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HMACAuthScheme is the `Authorization` header scheme of requests signed with
+// the secret of an AuthKey with the `hmac` scheme:
+//
+//	Authorization: SLINK-HMAC-SHA256 keyId=<id>, timestamp=<unix seconds>, nonce=<nonce>, signature=<hex>
+//
+// The signature is the hex HMAC-SHA256, with the secret, of the
+// newline-separated scheme, method, request URI (path and query), timestamp,
+// nonce, and hex SHA-256 digest of the body (of an empty body if none):
+//
+//	SLINK-HMAC-SHA256\nPOST\n/create-short-link\n1700000000\n5f0c6e1b9a7d\n<hex digest>
+const HMACAuthScheme = "SLINK-HMAC-SHA256"
+
+const (
+	// DefaultHMACReplayWindow is how far the timestamp of a signed request
+	// can be from the current time, either way.
+	DefaultHMACReplayWindow = 5 * time.Minute
+	// DefaultHMACNonceCacheSize is the number of recent nonces remembered to
+	// reject replayed requests, which should be well above the number of
+	// signed requests expected within the replay window.
+	DefaultHMACNonceCacheSize = 100000
+	// the minimum length of the secret of an AuthKey with the `hmac` scheme
+	hmacMinSecretLength = 32
+	// the maximum size of a signed request body, which has to be read in full
+	// to be verified, like bulkMaxBodyBytes
+	hmacMaxBodyBytes   = 32 << 20
+	hmacMaxNonceLength = 128
+)
+
+// hmacAuth holds the parameters of a HMACAuthScheme `Authorization` header.
+type hmacAuth struct {
+	keyID     string
+	timestamp string
+	nonce     string
+	signature []byte
+}
+
+func parseHMACAuth(params string) (*hmacAuth, error) {
+	auth := &hmacAuth{}
+	for _, param := range strings.Split(params, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s parameter %q", HMACAuthScheme, param)
+		}
+		switch name {
+		case "keyId":
+			auth.keyID = value
+		case "timestamp":
+			auth.timestamp = value
+		case "nonce":
+			auth.nonce = value
+		case "signature":
+			var err error
+			auth.signature, err = hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s signature must be hex", HMACAuthScheme)
+			}
+		}
+	}
+
+	if auth.keyID == "" || auth.timestamp == "" || auth.nonce == "" || len(auth.signature) == 0 {
+		return nil, fmt.Errorf("%s requires keyId, timestamp, nonce and signature", HMACAuthScheme)
+	}
+	if len(auth.nonce) > hmacMaxNonceLength {
+		return nil, fmt.Errorf("%s nonce must be at most %d characters", HMACAuthScheme, hmacMaxNonceLength)
+	}
+	return auth, nil
+}
+
+// hmacStringToSign returns what's signed for a request, see HMACAuthScheme.
+func hmacStringToSign(method, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyDigest := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		HMACAuthScheme,
+		method,
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyDigest[:]),
+	}, "\n"))
+}
+
+func hmacSignature(secret, stringToSign []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(stringToSign)
+	return mac.Sum(nil)
+}
+
+// authenticateHMAC returns the auth key that signed the request, or an error
+// message for the client. The request body is read in full, and replaced so
+// that the handler can read it again.
+func (s *AdminServer) authenticateHMAC(w http.ResponseWriter, r *http.Request, params string, now time.Time) (*AuthKey, string) {
+	auth, err := parseHMACAuth(params)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	entries := s.authKeySet.Load().hmacEntriesByID[auth.keyID]
+	if len(entries) == 0 {
+		return nil, "missing or invalid auth token"
+	}
+
+	unix, err := strconv.ParseInt(auth.timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Sprintf("%s timestamp must be in Unix seconds", HMACAuthScheme)
+	}
+	timestamp := time.Unix(unix, 0)
+	if timestamp.Before(now.Add(-s.hmacReplayWindow)) || timestamp.After(now.Add(s.hmacReplayWindow)) {
+		return nil, fmt.Sprintf("%s timestamp must be within %s of the server time", HMACAuthScheme, s.hmacReplayWindow)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, hmacMaxBodyBytes))
+	if err != nil {
+		return nil, "failed to read the request body"
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	stringToSign := hmacStringToSign(r.Method, r.URL.RequestURI(), auth.timestamp, auth.nonce, body)
+
+	var authKey *AuthKey
+	for _, entry := range entries {
+		if entry.validAt(now) && hmac.Equal(auth.signature, hmacSignature(entry.secret, stringToSign)) {
+			authKey = entry.key
+			break
+		}
+	}
+	if authKey == nil {
+		return nil, "invalid signature"
+	}
+
+	// only checked after the signature, so that unsigned requests can't use up
+	// nonces
+	nonceKey := auth.keyID + "\n" + auth.nonce
+	if seen, ok, _ := s.hmacNonces.PeekOrAdd(nonceKey, now); ok {
+		// a request signed before the nonce was first seen is out of the
+		// replay window after twice the window
+		if now.Sub(seen.(time.Time)) <= 2*s.hmacReplayWindow {
+			return nil, "the nonce has already been used"
+		}
+		s.hmacNonces.Add(nonceKey, now)
+	}
+
+	return authKey, ""
+}
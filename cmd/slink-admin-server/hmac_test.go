@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+)
+
+const (
+	testHMACSecret      = "0123456789abcdef0123456789abcdef"
+	testHMACOtherSecret = "fedcba9876543210fedcba9876543210"
+)
+
+type testSignedRequest struct {
+	method, requestURI, body string
+	keyID, secret            string
+	timestamp                time.Time
+	nonce                    string
+}
+
+func (sr testSignedRequest) authorization() string {
+	ts := strconv.FormatInt(sr.timestamp.Unix(), 10)
+	signature := hmacSignature([]byte(sr.secret), hmacStringToSign(sr.method, sr.requestURI, ts, sr.nonce, []byte(sr.body)))
+	return fmt.Sprintf("%s keyId=%s, timestamp=%s, nonce=%s, signature=%s", HMACAuthScheme, sr.keyID, ts, sr.nonce, hex.EncodeToString(signature))
+}
+
+func newTestHMACServer(t *testing.T) *AdminServer {
+	t.Helper()
+
+	s, err := NewAdminServer(context.Background(),
+		WithAuthKeys([]AuthKey{
+			{ID: "signer", Scheme: AuthSchemeHMAC, Secret: testHMACSecret},
+			{ID: "other", Scheme: AuthSchemeHMAC, Secret: testHMACOtherSecret},
+			{ID: "expired", Scheme: AuthSchemeHMAC, Secret: testHMACSecret, ExpiresAt: "2024-01-01T00:00:00Z"},
+		}),
+		WithSlinkOptions(slink.WithStorage(storage.NewMemoryStorage())),
+	)
+	if err != nil {
+		t.Fatalf("NewAdminServer: %v", err)
+	}
+	return s
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	signed := func(change func(*testSignedRequest)) testSignedRequest {
+		sr := testSignedRequest{
+			method:     http.MethodPost,
+			requestURI: "/create-short-link?x=1",
+			body:       `{"linkUrl":"https://example.com/"}`,
+			keyID:      "signer",
+			secret:     testHMACSecret,
+			timestamp:  now,
+			nonce:      "nonce-1",
+		}
+		if change != nil {
+			change(&sr)
+		}
+		return sr
+	}
+
+	tests := []struct {
+		name string
+		// the request that's signed, and the one that's sent if different
+		signed testSignedRequest
+		sent   *testSignedRequest
+		// the Authorization header instead of the signed one
+		authorization string
+		wantID        string
+		wantErr       string
+	}{
+		{name: "valid", signed: signed(nil), wantID: "signer"},
+		{name: "valid, empty body", signed: signed(func(sr *testSignedRequest) { sr.method, sr.body = http.MethodGet, "" }), wantID: "signer"},
+		{name: "wrong secret", signed: signed(func(sr *testSignedRequest) { sr.secret = testHMACOtherSecret }), wantErr: "invalid signature"},
+		{name: "secret of another key", signed: signed(func(sr *testSignedRequest) { sr.keyID = "other" }), wantErr: "invalid signature"},
+		{name: "tampered body", signed: signed(nil), sent: &testSignedRequest{method: http.MethodPost, requestURI: "/create-short-link?x=1", body: `{"linkUrl":"https://evil.example.com/"}`}, wantErr: "invalid signature"},
+		{name: "tampered query", signed: signed(nil), sent: &testSignedRequest{method: http.MethodPost, requestURI: "/create-short-link?x=2", body: `{"linkUrl":"https://example.com/"}`}, wantErr: "invalid signature"},
+		{name: "tampered method", signed: signed(nil), sent: &testSignedRequest{method: http.MethodPut, requestURI: "/create-short-link?x=1", body: `{"linkUrl":"https://example.com/"}`}, wantErr: "invalid signature"},
+		{name: "unknown key", signed: signed(func(sr *testSignedRequest) { sr.keyID = "nobody" }), wantErr: "missing or invalid auth token"},
+		{name: "expired key", signed: signed(func(sr *testSignedRequest) { sr.keyID = "expired" }), wantErr: "invalid signature"},
+		{name: "timestamp too old", signed: signed(func(sr *testSignedRequest) { sr.timestamp = now.Add(-DefaultHMACReplayWindow - time.Second) }), wantErr: "timestamp must be within"},
+		{name: "timestamp too far ahead", signed: signed(func(sr *testSignedRequest) { sr.timestamp = now.Add(DefaultHMACReplayWindow + time.Second) }), wantErr: "timestamp must be within"},
+		{name: "clock skew, behind", signed: signed(func(sr *testSignedRequest) { sr.timestamp = now.Add(-DefaultHMACReplayWindow) }), wantID: "signer"},
+		{name: "clock skew, ahead", signed: signed(func(sr *testSignedRequest) { sr.timestamp = now.Add(DefaultHMACReplayWindow) }), wantID: "signer"},
+		{name: "missing nonce", signed: signed(nil), authorization: HMACAuthScheme + " keyId=signer, timestamp=1714564800, signature=00", wantErr: "requires keyId, timestamp, nonce and signature"},
+		{name: "nonce too long", signed: signed(func(sr *testSignedRequest) { sr.nonce = strings.Repeat("n", hmacMaxNonceLength+1) }), wantErr: "nonce must be at most"},
+		{name: "signature not hex", signed: signed(nil), authorization: HMACAuthScheme + " keyId=signer, timestamp=1714564800, nonce=n, signature=zz", wantErr: "signature must be hex"},
+		{name: "timestamp not unix", signed: signed(nil), authorization: HMACAuthScheme + " keyId=signer, timestamp=2024-05-01T12:00:00Z, nonce=n, signature=00", wantErr: "timestamp must be in Unix seconds"},
+		{name: "malformed parameter", signed: signed(nil), authorization: HMACAuthScheme + " keyId=signer, timestamp", wantErr: "invalid " + HMACAuthScheme + " parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestHMACServer(t)
+
+			sent := tt.signed
+			if tt.sent != nil {
+				sent = *tt.sent
+			}
+			authorization := tt.authorization
+			if authorization == "" {
+				authorization = tt.signed.authorization()
+			}
+			params, ok := cutAuthScheme(authorization, HMACAuthScheme)
+			if !ok {
+				t.Fatalf("cutAuthScheme(%q): not %s", authorization, HMACAuthScheme)
+			}
+
+			r := httptest.NewRequest(sent.method, sent.requestURI, strings.NewReader(sent.body))
+			authKey, errMsg := s.authenticateHMAC(httptest.NewRecorder(), r, params, now)
+			if tt.wantID == "" {
+				if authKey != nil {
+					t.Errorf("got auth key %q, want none", authKey.ID)
+				}
+				if !strings.Contains(errMsg, tt.wantErr) {
+					t.Errorf("got error %q, want one containing %q", errMsg, tt.wantErr)
+				}
+				return
+			}
+
+			if authKey == nil || authKey.ID != tt.wantID {
+				t.Fatalf("got auth key %v (%s), want %q", authKey, errMsg, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateHMACRejectsReplayedNonces(t *testing.T) {
+	s := newTestHMACServer(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	authenticate := func(sr testSignedRequest, at time.Time) string {
+		t.Helper()
+		params, _ := cutAuthScheme(sr.authorization(), HMACAuthScheme)
+		r := httptest.NewRequest(sr.method, sr.requestURI, strings.NewReader(sr.body))
+		authKey, errMsg := s.authenticateHMAC(httptest.NewRecorder(), r, params, at)
+		if authKey == nil {
+			return errMsg
+		}
+		return ""
+	}
+
+	first := testSignedRequest{method: http.MethodGet, requestURI: "/short-links", keyID: "signer", secret: testHMACSecret, timestamp: now, nonce: "abc"}
+	steps := []struct {
+		name    string
+		request testSignedRequest
+		at      time.Time
+		wantErr string
+	}{
+		{"first use", first, now, ""},
+		{"replayed", first, now, "the nonce has already been used"},
+		{"replayed later in the window", first, now.Add(DefaultHMACReplayWindow), "the nonce has already been used"},
+		{"same nonce, re-signed with a new timestamp", func() testSignedRequest { sr := first; sr.timestamp = now.Add(time.Minute); return sr }(), now.Add(time.Minute), "the nonce has already been used"},
+		{"same nonce, another key", func() testSignedRequest { sr := first; sr.keyID, sr.secret = "other", testHMACOtherSecret; return sr }(), now, ""},
+		{"another nonce", func() testSignedRequest { sr := first; sr.nonce = "def"; return sr }(), now, ""},
+		// the original request is then out of the replay window, so the nonce
+		// is forgotten
+		{"reused after twice the window", func() testSignedRequest { sr := first; sr.timestamp = now.Add(3 * DefaultHMACReplayWindow); return sr }(), now.Add(3 * DefaultHMACReplayWindow), ""},
+		{"unsigned reuse doesn't use up the nonce", func() testSignedRequest { sr := first; sr.nonce, sr.secret = "ghi", testHMACOtherSecret; return sr }(), now, "invalid signature"},
+		{"nonce of an unsigned request", func() testSignedRequest { sr := first; sr.nonce = "ghi"; return sr }(), now, ""},
+	}
+	for _, step := range steps {
+		errMsg := authenticate(step.request, step.at)
+		if errMsg != step.wantErr {
+			t.Errorf("%s: got error %q, want %q", step.name, errMsg, step.wantErr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// the maximum size of a request body with an idempotency key, which has to
+	// be read in full to be hashed
+	idempotencyMaxBodyBytes = 1 << 20
+	// the time limit for recording the response, which may happen after the
+	// request has timed out
+	idempotencyRecordTimeout = 5 * time.Second
+	// how long a request is considered in progress, well over the time limits
+	// of the request and of recording its response, after which a retry can
+	// take over, e.g. when the process handling it crashed
+	idempotencyLockDuration = 2 * time.Minute
+)
+
+var idempotencyKeyRe = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+// idempotent makes retries of a request with the same `Idempotency-Key` header
+// (per auth key) get the response to the first request, instead of being
+// handled again, for as long as the idempotency TTL. A retry with a different
+// request body is rejected, as is a retry while the first request is still in
+// progress, unless it was abandoned (see idempotencyLockDuration).
+//
+// Responses with a 5xx status, and 429 ones for rate limits and quotas, are
+// not kept, so that the request can be retried. Requests without the header
+// are handled as usual, as are all requests when the storage backend doesn't
+// implement `storage.IdempotencyStore`.
+func (s *AdminServer) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+		if idempotencyKey == "" || s.idempotencyStore == nil {
+			h(w, r)
+			return
+		}
+
+		if !idempotencyKeyRe.MatchString(idempotencyKey) {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+			return
+		}
+
+		ctx := r.Context()
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxBodyBytes))
+		if err != nil {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now().UTC()
+		record := &models.IdempotencyRecord{
+			Key:         authKeyIDFromContext(ctx) + ":" + idempotencyKey,
+			RequestHash: idempotencyRequestHash(r.Method, r.URL.Path, body),
+			CreatedAt:   now.Format(time.RFC3339),
+			ExpiresAt:   now.Add(s.idempotencyTTL).Format(time.RFC3339),
+			LockedUntil: now.Add(idempotencyLockDuration).Format(time.RFC3339),
+		}
+
+		err = s.idempotencyStore.CreateIdempotencyRecord(ctx, record)
+		var exerr *storage.ErrIdempotencyRecordExists
+		if errors.As(err, &exerr) {
+			s.replayIdempotentResponse(w, r, record)
+			return
+		}
+		if err != nil {
+			writeError(w, r, fmt.Errorf("idempotencyStore.CreateIdempotencyRecord: %w", err))
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		h(rec, r)
+
+		// the request context may be done by now, e.g. timed out, but the
+		// response should still be recorded for the retries
+		recordCtx, cancelRecordCtx := context.WithTimeout(context.Background(), idempotencyRecordTimeout)
+		defer cancelRecordCtx()
+
+		if rec.statusCode == 0 || rec.statusCode == http.StatusTooManyRequests || rec.statusCode >= http.StatusInternalServerError {
+			err = s.idempotencyStore.DeleteIdempotencyRecord(recordCtx, record.Key)
+			if err != nil {
+				log.Error().Err(err).Str("key", record.Key).Msg("idempotencyStore.DeleteIdempotencyRecord failed, retries will be rejected until it's abandoned")
+			}
+			return
+		}
+
+		record.StatusCode = rec.statusCode
+		record.ResponseBody = rec.body.Bytes()
+		record.LockedUntil = ""
+		err = s.idempotencyStore.PutIdempotencyRecord(recordCtx, record)
+		if err != nil {
+			log.Error().Err(err).Str("key", record.Key).Msg("idempotencyStore.PutIdempotencyRecord failed, retries will be rejected until it's abandoned")
+		}
+	}
+}
+
+// idempotencyRequestHash identifies a request by its method, path and body.
+func idempotencyRequestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", method, path)
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *AdminServer) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, record *models.IdempotencyRecord) {
+	existing, err := s.idempotencyStore.GetIdempotencyRecord(r.Context(), record.Key)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("idempotencyStore.GetIdempotencyRecord: %w", err))
+		return
+	}
+
+	switch {
+	case existing == nil:
+		// deleted after a failure, or expired, just now
+		writeErrorCode(w, r, http.StatusConflict, ErrCodeIdempotencyKeyInProgress, "a request with the same Idempotency-Key has just finished, retry the request")
+	case existing.RequestHash != record.RequestHash:
+		writeErrorCode(w, r, http.StatusUnprocessableEntity, ErrCodeIdempotencyKeyMismatch, "the Idempotency-Key has already been used for a different request")
+	case !existing.Completed():
+		writeErrorCode(w, r, http.StatusConflict, ErrCodeIdempotencyKeyInProgress, "a request with the same Idempotency-Key is still in progress, retry the request later")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		w.WriteHeader(existing.StatusCode)
+		w.Write(existing.ResponseBody)
+
+		var shortLink models.ShortLink
+		if json.Unmarshal(existing.ResponseBody, &shortLink) == nil && shortLink.ID != "" {
+			auditShortLinks(r.Context(), &shortLink)
+		}
+	}
+}
+
+// responseRecorder passes a response through, keeping a copy of its status code
+// and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
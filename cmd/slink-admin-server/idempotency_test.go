@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink/models"
+)
+
+func TestIdempotentCreateShortLink(t *testing.T) {
+	const (
+		createPath = "/create-short-link"
+		bodyA      = `{"linkUrl":"https://example.com/a"}`
+		bodyB      = `{"linkUrl":"https://example.com/b"}`
+	)
+	type request struct {
+		path, body, key string
+		wantStatus      int
+		wantCode        string
+		wantReplayed    bool
+	}
+	now := time.Now().UTC()
+	// an existing record of a request with bodyA
+	existing := func(change func(*models.IdempotencyRecord)) *models.IdempotencyRecord {
+		record := &models.IdempotencyRecord{
+			Key:         "test:k1",
+			RequestHash: idempotencyRequestHash(http.MethodPost, createPath, []byte(bodyA)),
+			CreatedAt:   now.Format(time.RFC3339),
+			ExpiresAt:   now.Add(time.Hour).Format(time.RFC3339),
+			LockedUntil: now.Add(time.Minute).Format(time.RFC3339),
+		}
+		change(record)
+		return record
+	}
+
+	tests := []struct {
+		name      string
+		existing  *models.IdempotencyRecord
+		requests  []request
+		wantLinks int
+	}{
+		{
+			name: "same key and body replayed",
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK},
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK, wantReplayed: true},
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK, wantReplayed: true},
+			},
+			wantLinks: 1,
+		},
+		{
+			name: "same key, different body",
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK},
+				{path: createPath, body: bodyB, key: "k1", wantStatus: http.StatusUnprocessableEntity, wantCode: ErrCodeIdempotencyKeyMismatch},
+			},
+			wantLinks: 1,
+		},
+		{
+			name: "same key and body, different path",
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK},
+				{path: "/get-or-create-short-link", body: bodyA, key: "k1", wantStatus: http.StatusUnprocessableEntity, wantCode: ErrCodeIdempotencyKeyMismatch},
+			},
+			wantLinks: 1,
+		},
+		{
+			name: "different keys",
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK},
+				{path: createPath, body: bodyA, key: "k2", wantStatus: http.StatusOK},
+			},
+			wantLinks: 2,
+		},
+		{
+			name: "without key",
+			requests: []request{
+				{path: createPath, body: bodyA, wantStatus: http.StatusOK},
+				{path: createPath, body: bodyA, wantStatus: http.StatusOK},
+			},
+			wantLinks: 2,
+		},
+		{
+			name: "invalid request replayed",
+			requests: []request{
+				{path: createPath, body: `{"linkUrl":"nope"}`, key: "k1", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidLinkURL},
+				{path: createPath, body: `{"linkUrl":"nope"}`, key: "k1", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidLinkURL, wantReplayed: true},
+			},
+		},
+		{
+			name: "invalid key",
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k 1", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidRequest},
+			},
+		},
+		{
+			name:     "locked by a request in progress",
+			existing: existing(func(r *models.IdempotencyRecord) {}),
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusConflict, wantCode: ErrCodeIdempotencyKeyInProgress},
+			},
+		},
+		{
+			name:     "locked by a request in progress, different body",
+			existing: existing(func(r *models.IdempotencyRecord) {}),
+			requests: []request{
+				{path: createPath, body: bodyB, key: "k1", wantStatus: http.StatusUnprocessableEntity, wantCode: ErrCodeIdempotencyKeyMismatch},
+			},
+		},
+		{
+			name:     "lock of an abandoned request taken over",
+			existing: existing(func(r *models.IdempotencyRecord) { r.LockedUntil = now.Add(-time.Second).Format(time.RFC3339) }),
+			requests: []request{
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK},
+				{path: createPath, body: bodyA, key: "k1", wantStatus: http.StatusOK, wantReplayed: true},
+			},
+			wantLinks: 1,
+		},
+		{
+			name:     "lock of an abandoned request, different body",
+			existing: existing(func(r *models.IdempotencyRecord) { r.LockedUntil = now.Add(-time.Second).Format(time.RFC3339) }),
+			requests: []request{
+				{path: createPath, body: bodyB, key: "k1", wantStatus: http.StatusUnprocessableEntity, wantCode: ErrCodeIdempotencyKeyMismatch},
+			},
+		},
+		{
+			name: "expired record",
+			existing: existing(func(r *models.IdempotencyRecord) {
+				r.ExpiresAt = now.Add(-time.Second).Format(time.RFC3339)
+				r.LockedUntil = ""
+				r.StatusCode = http.StatusOK
+				r.ResponseBody = []byte(`{"id":"old"}`)
+			}),
+			requests: []request{
+				{path: createPath, body: bodyB, key: "k1", wantStatus: http.StatusOK},
+			},
+			wantLinks: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAdminServer(t)
+			ctx := context.Background()
+			if tt.existing != nil {
+				err := s.idempotencyStore.CreateIdempotencyRecord(ctx, tt.existing)
+				if err != nil {
+					t.Fatalf("CreateIdempotencyRecord: %v", err)
+				}
+			}
+
+			var firstBody string
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, req.path, strings.NewReader(req.body))
+				r.Header.Set("Authorization", "Bearer test")
+				r.Header.Set("Content-Type", "application/json")
+				if req.key != "" {
+					r.Header.Set(idempotencyKeyHeader, req.key)
+				}
+				rec := httptest.NewRecorder()
+				s.Handler.ServeHTTP(rec, r)
+
+				var apiErr APIError
+				_ = json.Unmarshal(rec.Body.Bytes(), &apiErr)
+				replayed := rec.Header().Get(idempotencyReplayedHeader) == "true"
+				if rec.Code != req.wantStatus || apiErr.Code != req.wantCode || replayed != req.wantReplayed {
+					t.Fatalf("request %d: got status %d, code %q, replayed %t, want %d, %q, %t: %s", i+1, rec.Code, apiErr.Code, replayed, req.wantStatus, req.wantCode, req.wantReplayed, rec.Body)
+				}
+
+				if i == 0 {
+					firstBody = rec.Body.String()
+				} else if req.wantReplayed && rec.Body.String() != firstBody {
+					// the request IDs of errors are those of the first request too
+					t.Errorf("request %d: got replayed body %s, want %s", i+1, rec.Body, firstBody)
+				}
+			}
+
+			var links int
+			err := s.svc.ExportShortLinks(ctx, func(*models.ShortLink) error {
+				links++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ExportShortLinks: %v", err)
+			}
+			if links != tt.wantLinks {
+				t.Errorf("got %d short links, want %d", links, tt.wantLinks)
+			}
+		})
+	}
+}
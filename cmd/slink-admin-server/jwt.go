@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// AuthSchemeJWT is the Scheme of the AuthKeys of requests authenticated with a
+// JWT, which are never configured, only derived from the JWT claims.
+const AuthSchemeJWT = "jwt"
+
+// jwtLeeway allows for clock skew between the issuer and the admin server when
+// checking `exp` and `nbf`.
+const jwtLeeway = 1 * time.Minute
+
+// jwks is a parsed JSON Web Key Set (RFC 7517), with only the public keys that
+// can verify JWT signatures.
+type jwks struct {
+	keys []*jwk
+}
+
+type jwk struct {
+	kid string
+	alg string // optional, restricts the key to this algorithm
+	key crypto.PublicKey
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS replaces the JWKS in use with the one in the JWKS file. The JWKS in
+// use is kept when the file is invalid.
+func (s *AdminServer) loadJWKS() error {
+	b, err := os.ReadFile(s.jwksFile)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile %s: %w", s.jwksFile, err)
+	}
+
+	set, err := parseJWKS(b)
+	if err != nil {
+		return fmt.Errorf("parseJWKS %s: %w", s.jwksFile, err)
+	}
+
+	s.jwks.Store(set)
+	return nil
+}
+
+func parseJWKS(b []byte) (*jwks, error) {
+	var doc struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &jwks{}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key := &jwk{kid: k.Kid, alg: k.Alg}
+		switch k.Kty {
+		case "RSA":
+			key.key, err = parseRSAJWK(&k)
+		case "EC":
+			key.key, err = parseECJWK(&k)
+		default:
+			// e.g. symmetric keys, which aren't supported
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, k.Kid, err)
+		}
+		set.keys = append(set.keys, key)
+	}
+
+	if len(set.keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys")
+	}
+	return set, nil
+}
+
+func parseRSAJWK(k *jwkJSON) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid n")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid e")
+	}
+
+	pub := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if pub.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	return pub, nil
+}
+
+func parseECJWK(k *jwkJSON) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, errors.New("invalid x")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, errors.New("invalid y")
+	}
+
+	pub := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("the point is not on the curve")
+	}
+	return pub, nil
+}
+
+// looksLikeJWT returns true if the bearer token has the shape of a JWT, rather
+// than of a static auth key token.
+func looksLikeJWT(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	ClientID  string          `json:"client_id"`
+	Audience  json.RawMessage `json:"aud"`
+	Expiry    *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	// space separated, like OAuth 2.0 scopes
+	Scope string `json:"scope"`
+	// a list of scopes, as used by some issuers instead of scope
+	Scp []string `json:"scp"`
+}
+
+// authenticateJWT returns an AuthKey derived from the claims of a valid JWT,
+// or an error message for the client.
+//
+// The JWT must be signed by a key in the JWKS, have the configured issuer and
+// audience, and not be expired. The key ID is the `sub` claim, or the
+// `client_id` claim without `sub`. The scopes are the ones of the admin API in
+// the `scope` (or `scp`) claim, a JWT without any can't call any route.
+func (s *AdminServer) authenticateJWT(token string, now time.Time) (*AuthKey, string) {
+	set := s.jwks.Load()
+	if set == nil {
+		return nil, "missing or invalid auth token"
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "invalid JWT"
+	}
+
+	var header jwtHeader
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return nil, "invalid JWT header"
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "invalid JWT signature"
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	if !set.verify(header, signingInput, signature) {
+		return nil, "invalid JWT signature"
+	}
+
+	var claims jwtClaims
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return nil, "invalid JWT claims"
+	}
+
+	if claims.Issuer != s.jwtIssuer {
+		return nil, "the JWT has the wrong issuer"
+	}
+	if !jwtAudienceContains(claims.Audience, s.jwtAudience) {
+		return nil, "the JWT has the wrong audience"
+	}
+
+	if claims.Expiry == nil {
+		return nil, "the JWT has no expiry"
+	}
+	exp, err := claims.Expiry.Float64()
+	if err != nil || now.Add(-jwtLeeway).After(time.Unix(int64(exp), 0)) {
+		return nil, "the JWT has expired"
+	}
+	if claims.NotBefore != nil {
+		nbf, err := claims.NotBefore.Float64()
+		if err != nil || now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+			return nil, "the JWT is not valid yet"
+		}
+	}
+
+	keyID := claims.Subject
+	if keyID == "" {
+		keyID = claims.ClientID
+	}
+	if keyID == "" {
+		return nil, "the JWT has neither a sub nor a client_id"
+	}
+
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+
+	authKey := &AuthKey{ID: keyID, Scheme: AuthSchemeJWT}
+	for _, scope := range scopes {
+		if knownScopes[scope] {
+			authKey.Scopes = append(authKey.Scopes, scope)
+		}
+	}
+	return authKey, ""
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jwtAudienceContains returns true if the `aud` claim, either a string or a
+// list of strings, contains audience.
+func jwtAudienceContains(aud json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(aud, &single) == nil {
+		return single == audience
+	}
+
+	var list []string
+	if json.Unmarshal(aud, &list) == nil {
+		for _, a := range list {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verify returns true if signature is valid for signingInput with one of the
+// keys matching the header. Without a `kid`, every key is tried.
+func (set *jwks) verify(header jwtHeader, signingInput, signature []byte) bool {
+	hash, ok := jwtAlgHashes[header.Alg]
+	if !ok {
+		// including `none` and the HMAC algorithms
+		return false
+	}
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	for _, key := range set.keys {
+		if header.Kid != "" && key.kid != header.Kid {
+			continue
+		}
+		if key.alg != "" && key.alg != header.Alg {
+			continue
+		}
+
+		switch pub := key.key.(type) {
+		case *rsa.PublicKey:
+			switch header.Alg[:2] {
+			case "RS":
+				if rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil {
+					return true
+				}
+			case "PS":
+				opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+				if rsa.VerifyPSS(pub, hash, digest, signature, opts) == nil {
+					return true
+				}
+			}
+		case *ecdsa.PublicKey:
+			if jwtAlgCurves[header.Alg] != pub.Curve.Params().Name {
+				continue
+			}
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(pub, digest, r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var jwtAlgHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+var jwtAlgCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
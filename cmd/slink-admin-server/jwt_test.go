@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+)
+
+const (
+	testJWTIssuer   = "https://sso.example.com"
+	testJWTAudience = "slink-admin"
+)
+
+type testJWTSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func (signer *testJWTSigner) sign(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+
+	if header == nil {
+		header = map[string]any{"alg": signer.alg, "kid": signer.kid}
+	}
+	signingInput := encodeTestJWTPart(t, header) + "." + encodeTestJWTPart(t, claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch key := signer.key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (signer *testJWTSigner) jwk() map[string]string {
+	switch pub := signer.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "use": "sig", "kid": signer.kid, "alg": signer.alg,
+			"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC", "use": "sig", "kid": signer.kid, "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return nil
+}
+
+func encodeTestJWTPart(t *testing.T, v any) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	rsaSigner := &testJWTSigner{kid: "rsa", alg: "RS256", key: rsaKey}
+	ecSigner := &testJWTSigner{kid: "ec", alg: "ES256", key: ecKey}
+	// not in the JWKS, with the kid of a key that is
+	unknownSigner := &testJWTSigner{kid: "ec", alg: "ES256", key: otherKey}
+
+	b, err := json.Marshal(map[string]any{"keys": []any{rsaSigner.jwk(), ecSigner.jwk()}})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(jwksFile, b, 0o600)
+	if err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	s, err := NewAdminServer(context.Background(),
+		WithJWKSFile(jwksFile),
+		WithJWTIssuer(testJWTIssuer),
+		WithJWTAudience(testJWTAudience),
+		WithSlinkOptions(slink.WithStorage(storage.NewMemoryStorage())),
+	)
+	if err != nil {
+		t.Fatalf("NewAdminServer: %v", err)
+	}
+	defer s.Shutdown(context.Background())
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   testJWTIssuer,
+			"aud":   testJWTAudience,
+			"sub":   "alice",
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "links:read links:write openid",
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	tamper := func(token string) string {
+		// replaces the claims, keeping the signature of the original ones
+		parts := strings.Split(token, ".")
+		return parts[0] + "." + encodeTestJWTPart(t, claims(map[string]any{"scope": "links:admin"})) + "." + parts[2]
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantID     string
+		wantScopes []string
+	}{
+		{"RS256", rsaSigner.sign(t, nil, claims(nil)), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"ES256", ecSigner.sign(t, nil, claims(nil)), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"without kid", ecSigner.sign(t, map[string]any{"alg": "ES256"}, claims(nil)), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"audience list", rsaSigner.sign(t, nil, claims(map[string]any{"aud": []string{"other", testJWTAudience}})), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"scp claim", rsaSigner.sign(t, nil, claims(map[string]any{"scope": nil, "scp": []string{"links:admin"}})), "alice", []string{ScopeLinksAdmin}},
+		{"client_id without sub", rsaSigner.sign(t, nil, claims(map[string]any{"sub": nil, "client_id": "ci"})), "ci", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"no scopes", rsaSigner.sign(t, nil, claims(map[string]any{"scope": nil})), "alice", nil},
+		{"tampered claims", tamper(rsaSigner.sign(t, nil, claims(nil))), "", nil},
+		{"unknown key", unknownSigner.sign(t, nil, claims(nil)), "", nil},
+		{"wrong kid", ecSigner.sign(t, map[string]any{"alg": "ES256", "kid": "rsa"}, claims(nil)), "", nil},
+		{"wrong alg for the key", rsaSigner.sign(t, map[string]any{"alg": "PS256", "kid": "rsa"}, claims(nil)), "", nil},
+		{"alg none", encodeTestJWTPart(t, map[string]any{"alg": "none"}) + "." + encodeTestJWTPart(t, claims(nil)) + ".", "", nil},
+		{"wrong issuer", rsaSigner.sign(t, nil, claims(map[string]any{"iss": "https://evil.example.com"})), "", nil},
+		{"wrong audience", rsaSigner.sign(t, nil, claims(map[string]any{"aud": "other"})), "", nil},
+		{"wrong audience list", rsaSigner.sign(t, nil, claims(map[string]any{"aud": []string{"other"}})), "", nil},
+		{"no audience", rsaSigner.sign(t, nil, claims(map[string]any{"aud": nil})), "", nil},
+		{"no expiry", rsaSigner.sign(t, nil, claims(map[string]any{"exp": nil})), "", nil},
+		{"expired", rsaSigner.sign(t, nil, claims(map[string]any{"exp": now.Add(-jwtLeeway - time.Second).Unix()})), "", nil},
+		{"expired within the clock skew leeway", rsaSigner.sign(t, nil, claims(map[string]any{"exp": now.Add(-jwtLeeway + time.Second).Unix()})), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"not valid yet", rsaSigner.sign(t, nil, claims(map[string]any{"nbf": now.Add(jwtLeeway + time.Second).Unix()})), "", nil},
+		{"not valid yet within the clock skew leeway", rsaSigner.sign(t, nil, claims(map[string]any{"nbf": now.Add(jwtLeeway - time.Second).Unix()})), "alice", []string{ScopeLinksRead, ScopeLinksWrite}},
+		{"neither sub nor client_id", rsaSigner.sign(t, nil, claims(map[string]any{"sub": nil})), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authKey, errMsg := s.authenticateJWT(tt.token, now)
+			if tt.wantID == "" {
+				if authKey != nil {
+					t.Errorf("got auth key %q, want none", authKey.ID)
+				}
+				if errMsg == "" {
+					t.Errorf("got no error message")
+				}
+				return
+			}
+
+			if authKey == nil {
+				t.Fatalf("got no auth key: %s", errMsg)
+			}
+			if authKey.ID != tt.wantID || authKey.Scheme != AuthSchemeJWT {
+				t.Errorf("got auth key %q with scheme %q, want %q with scheme %q", authKey.ID, authKey.Scheme, tt.wantID, AuthSchemeJWT)
+			}
+			if !reflect.DeepEqual(authKey.Scopes, tt.wantScopes) {
+				t.Errorf("got scopes %v, want %v", authKey.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
@@ -5,19 +5,27 @@ import (
 	"encoding/json"
 	"flag"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/ronny/slink"
+	"github.com/ronny/slink/audit"
+	"github.com/ronny/slink/blocklist"
 	"github.com/ronny/slink/debug"
 	"github.com/ronny/slink/ids"
+	"github.com/ronny/slink/qr"
 	"github.com/ronny/slink/storage"
+	"github.com/ronny/slink/urls"
+	"github.com/ronny/slink/webhooks"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/automaxprocs/maxprocs"
@@ -30,9 +38,10 @@ func main() {
 
 	var (
 		listenAddr          = fs.String("listen-addr", ":9090", "the host:port address where the ddmin server should listen to")
+		grpcListenAddr      = fs.String("grpc-listen-addr", "", "the host:port address where the gRPC admin API should listen to (optional, only launched when specified)")
 		length              = fs.Int("length", 10, "the length of the ID to generate, see https://zelark.github.io/nano-id-cc/")
 		chars               = fs.String("chars", ids.NanoIDDefaultCharacters, "the allowed characters used for generating IDs")
-		denylistFilename    = fs.String("denylist", "", "custom denylist.txt file to use for checking generated IDs (optional)")
+		denylistFilename    = fs.String("denylist", "", "custom denylist.txt file to use for checking generated IDs and custom aliases (optional)")
 		denylistMaxAttempts = fs.Int("denylist-max-attempts", 10, "max number of attempts generating an ID and comparing against denylist before giving up")
 		dynamodbTableName   = fs.String("dynamodb-tablename", storage.DynamoDBDefaultTableName, "the dynamodb table name")
 		dynamodbRegion      = fs.String("dynamodb-region", storage.DynamoDBDefaultRegion, "the dynamodb region")
@@ -41,8 +50,45 @@ func main() {
 		debugListenAddr     = fs.String("debug-listen-addr", "", "the host:port address where the debug server should listen to (optional, only launched when specified)")
 		prettyLog           = fs.Bool("pretty-log", false, "whether to enable logs pretty-printing (inefficient), otherwise json")
 		logLevel            = fs.String("log-level", "info", "set the minimum log level")
+		aliasChars          = fs.String("alias-chars", slink.DefaultAliasCharacters, "the allowed characters in custom aliases (the optional id when creating a short link)")
+		aliasMinLength      = fs.Int("alias-min-length", slink.DefaultAliasMinLength, "the minimum length of custom aliases")
+		aliasMaxLength      = fs.Int("alias-max-length", slink.DefaultAliasMaxLength, "the maximum length of custom aliases")
 		maxCreateAttempts   = fs.Int("max-create-attempts", slink.DefaultMaxCreateAttempts, "the maximum number of attempts for creating a short link with a newly generated ID (in case of collisions) (must be >= 1)")
-		authKeysJSON        = fs.String("auth-keys", "", "a list of {id, token} pairs used to authenticate client requests (in JSON format)")
+		streamingTimeout    = fs.Duration("streaming-timeout", DefaultStreamingTimeoutDuration, "the time limit for requests that stream their request or response, e.g. bulk creation")
+		bulkConcurrency     = fs.Int("bulk-concurrency", DefaultBulkConcurrency, "how many batches of rows a single bulk creation request processes concurrently")
+		idempotencyTTL      = fs.Duration("idempotency-ttl", DefaultIdempotencyTTL, "how long the response to a request with an Idempotency-Key header is kept for retries")
+		authKeysJSON        = fs.String("auth-keys", "", "a list of {id, tokenHash or scheme and secret, scopes, notBefore, expiresAt} objects used to authenticate client requests (in JSON format), see README")
+		authKeysFile        = fs.String("auth-keys-file", "", "a JSON file with a list of auth keys like -auth-keys, used in addition to them, reloaded when it changes (optional)")
+		authKeysReload      = fs.Duration("auth-keys-reload-interval", DefaultAuthKeysReloadInterval, "how often the auth keys file and the JWKS file are checked for changes")
+		jwksFile            = fs.String("jwks-file", "", "a JSON Web Key Set file with the public keys of the JWT issuer, reloaded when it changes, enables JWT bearer tokens (optional)")
+		jwtIssuer           = fs.String("jwt-issuer", "", "the iss claim required in JWTs (required with -jwks-file)")
+		jwtAudience         = fs.String("jwt-audience", "", "the audience required in the aud claim of JWTs (required with -jwks-file)")
+		hmacReplayWindow    = fs.Duration("hmac-replay-window", DefaultHMACReplayWindow, "how far the timestamp of a request signed with an hmac auth key can be from the server time, either way")
+		tlsCertFile         = fs.String("tls-cert-file", "", "the PEM certificate (chain) file to serve TLS with, reloaded when it changes (optional, requires -tls-key-file)")
+		tlsKeyFile          = fs.String("tls-key-file", "", "the PEM private key file of -tls-cert-file, reloaded when it changes")
+		tlsClientCAFile     = fs.String("tls-client-ca-file", "", "a PEM bundle of the CAs verifying client certificates, which authenticate requests as the auth keys with the mtls scheme (optional)")
+		tlsRequireClient    = fs.Bool("tls-require-client-cert", false, "whether a client certificate verified by -tls-client-ca-file is required to connect, even with an auth token")
+		rateLimit           = fs.Float64("rate-limit", 0, "the requests per second each auth key can make on average, unless the key has its own rateLimit (0 doesn't limit requests)")
+		rateLimitBurst      = fs.Int("rate-limit-burst", 0, "the requests each auth key can make in a burst above -rate-limit, unless the key has its own rateLimitBurst (defaults to the rate limit rounded up)")
+		dailyCreateQuota    = fs.Int("daily-create-quota", 0, "the short links each auth key can create per day (UTC), unless the key has its own dailyCreateQuota (0 doesn't limit creation)")
+		auditFile           = fs.String("audit-file", "", "a file where an audit event is appended as a JSON line for every admin API request, or - for stdout (optional)")
+		auditTableName      = fs.String("audit-dynamodb-tablename", "", "a dynamodb table (with pk and sk string keys) where an audit event is put for every admin API request, can be the same as -dynamodb-tablename (optional)")
+		auditRetention      = fs.Duration("audit-retention", 0, "how long audit events are kept in -audit-dynamodb-tablename, by setting their ttl attribute (0 keeps them forever)")
+		webhooksJSON        = fs.String("webhooks", "", "a list of {id, url, secret, events} webhook endpoints notified when short links are created, updated or expired (in JSON format), see README (optional)")
+		webhookMaxAttempts  = fs.Int("webhook-max-attempts", webhooks.DefaultMaxAttempts, "how many times a webhook delivery is attempted before it's recorded as a dead letter")
+		webhookTimeout      = fs.Duration("webhook-timeout", webhooks.DefaultTimeout, "the time limit of each webhook delivery attempt")
+		allowedSchemes      = fs.String("allowed-schemes", "http,https", "the schemes link URLs can have (comma separated, empty allowing any)")
+		maxLinkURLLength    = fs.Int("max-link-url-length", slink.DefaultMaxLinkURLLength, "the maximum length of link URLs (0 allowing any length)")
+		allowedDomains      = fs.String("allowed-domains", "", "the only domains link URLs can point to (comma separated, `*.example.com` matching any subdomain of example.com) (optional, any domain is allowed without it)")
+		deniedDomains       = fs.String("denied-domains", "", "the domains link URLs can't point to (comma separated, `*.example.com` matching any subdomain of example.com) (optional)")
+		allowPrivateIPs     = fs.Bool("allow-private-ips", false, "whether link URLs can point to private, loopback or link-local IP addresses")
+		blockedDomainsFile  = fs.String("blocklist-domains-file", "", "a file of blocked domains (one per line, also blocking their subdomains), link URLs to them are rejected, reloaded when it changes (optional)")
+		blockedPrefixesFile = fs.String("blocklist-url-prefixes-file", "", "a file of blocked URL prefixes (one per line), link URLs starting with them are rejected, reloaded when it changes (optional)")
+		blocklistReload     = fs.Duration("blocklist-reload-interval", blocklist.DefaultReloadInterval, "how often the blocklist files are checked for changes")
+		strippedQueryParams = fs.String("stripped-query-params", strings.Join(urls.DefaultStrippedQueryParams, ","), "the tracking query parameters (comma separated, a trailing * matching any suffix) removed from normalised link URLs, so that link URLs only differing by them are looked up as the same URL")
+		ownHosts            = fs.String("own-hosts", "", "the hosts short links are served on (comma separated, e.g. `sho.rt,www.sho.rt`), in addition to the host of -public-base-url, so that link URLs redirecting in a loop through them are rejected (optional)")
+		maxRedirectDepth    = fs.Int("max-redirect-depth", slink.DefaultMaxRedirectDepth, "how many short links on the own hosts a link URL can redirect through (0 rejects link URLs to them)")
+		publicBaseURL       = fs.String("public-base-url", "", "the URL of the public server that short links are served under, e.g. `https://sho.rt`, encoded in their QR codes (optional, otherwise QR code requests need a baseUrl)")
 		_                   = fs.String("config", "", "config file (optional)")
 	)
 
@@ -74,6 +120,9 @@ func main() {
 
 	slinkOptions := make([]func(*slink.Slink), 0)
 
+	var ddbCfg aws.Config
+	var ddbStorage *storage.DynamoDBStorage
+
 	// Storage / DynamoDB
 	{
 		awsConfigOpts := []func(*config.LoadOptions) error{
@@ -100,12 +149,12 @@ func main() {
 				},
 			))
 		}
-		ddbCfg, err := config.LoadDefaultConfig(ctx, awsConfigOpts...)
+		ddbCfg, err = config.LoadDefaultConfig(ctx, awsConfigOpts...)
 		if err != nil {
 			log.Fatal().Err(err).Msg("(aws)config.LoadDefaultConfig")
 		}
 
-		ddblocal, err := storage.NewDynamoDBStorage(ctx,
+		ddbStorage, err = storage.NewDynamoDBStorage(ctx,
 			storage.WithDynamoDBConfig(ddbCfg),
 			storage.WithDynamoDBTableName(*dynamodbTableName),
 		)
@@ -113,7 +162,7 @@ func main() {
 			log.Fatal().Err(err).Msg("storage.NewDynamoDBStorage")
 		}
 		slinkOptions = append(slinkOptions,
-			slink.WithStorage(ddblocal),
+			slink.WithStorage(ddbStorage),
 			slink.WithMaxCreateAttempts(*maxCreateAttempts),
 		)
 	}
@@ -132,6 +181,7 @@ func main() {
 				log.Fatal().Err(err).Str("denylistFilename", *denylistFilename).Msg("LoadDenylist")
 			}
 			nanoidOpts = append(nanoidOpts, ids.WithNanoIDDenylist(denylist))
+			slinkOptions = append(slinkOptions, slink.WithAliasDenylist(denylist))
 		}
 
 		nanoidGenerator, err := ids.NewNanoIDGenerator(nanoidOpts...)
@@ -141,29 +191,142 @@ func main() {
 		slinkOptions = append(slinkOptions, slink.WithIDGenerator(nanoidGenerator))
 	}
 
+	// Custom aliases
+	slinkOptions = append(slinkOptions,
+		slink.WithAliasCharacters(*aliasChars),
+		slink.WithAliasLength(*aliasMinLength, *aliasMaxLength),
+	)
+
+	// Link URL validation and normalisation
+	urlPolicy := slink.DefaultURLPolicy()
+	urlPolicy.AllowedSchemes = splitList(*allowedSchemes)
+	urlPolicy.MaxLength = *maxLinkURLLength
+	urlPolicy.AllowedDomains = splitList(*allowedDomains)
+	urlPolicy.DeniedDomains = splitList(*deniedDomains)
+	urlPolicy.AllowPrivateIPs = *allowPrivateIPs
+	slinkOptions = append(slinkOptions,
+		slink.WithURLPolicy(urlPolicy),
+		slink.WithURLNormaliser(urls.NewStandardNormaliser(urls.WithStrippedQueryParams(splitList(*strippedQueryParams)...))),
+	)
+
+	// Blocklist
+	if *blockedDomainsFile != "" || *blockedPrefixesFile != "" {
+		bl, err := blocklist.NewBlocklist(
+			blocklist.WithDomainsFile(*blockedDomainsFile),
+			blocklist.WithURLPrefixesFile(*blockedPrefixesFile),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("blocklist.NewBlocklist")
+		}
+		stopWatchingBlocklist := make(chan struct{})
+		defer close(stopWatchingBlocklist)
+		go bl.Watch(*blocklistReload, stopWatchingBlocklist)
+		slinkOptions = append(slinkOptions, slink.WithBlocklist(bl))
+	}
+
+	// Redirect loops, through short links on the own hosts, including the
+	// host of the public base URL
+	hosts := splitList(*ownHosts)
+	if *publicBaseURL != "" {
+		_, err = qr.ShortLinkURL(*publicBaseURL, "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid -public-base-url")
+		}
+		u, _ := url.Parse(*publicBaseURL)
+		hosts = append(hosts, u.Host)
+	}
+	slinkOptions = append(slinkOptions,
+		slink.WithOwnHosts(hosts...),
+		slink.WithMaxRedirectDepth(*maxRedirectDepth),
+	)
+
 	log.Info().
 		Str("dynamodbEndpoint", *dynamodbEndpoint).
-		Str("awsAccessKeyID", *awsAccessKeyID).
 		Int("length", *length).
 		Str("chars", *chars).
 		Int("denylistMaxAttempts", *denylistMaxAttempts).
 		Str("denylistFilename", *denylistFilename).
 		Str("debugListenAddr", *debugListenAddr).
+		Str("tlsCertFile", *tlsCertFile).
+		Str("tlsClientCAFile", *tlsClientCAFile).
+		Str("auditFile", *auditFile).
+		Str("auditTableName", *auditTableName).
+		Str("ownHosts", *ownHosts).
 		Msg("slink-admin-server flags")
 
+	// Audit
+	var auditSink audit.Sink
+	switch {
+	case *auditFile != "" && *auditTableName != "":
+		log.Fatal().Msg("only one of -audit-file and -audit-dynamodb-tablename can be specified")
+	case *auditFile != "":
+		fileSink, err := audit.NewFileSink(*auditFile)
+		if err != nil {
+			log.Fatal().Err(err).Str("auditFile", *auditFile).Msg("audit.NewFileSink")
+		}
+		defer fileSink.Close()
+		auditSink = fileSink
+	case *auditTableName != "":
+		auditSink, err = audit.NewDynamoDBSink(ctx, *auditTableName,
+			audit.WithDynamoDBSinkClient(dynamodb.NewFromConfig(ddbCfg)),
+			audit.WithDynamoDBSinkRetention(*auditRetention),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("audit.NewDynamoDBSink")
+		}
+	}
+
+	// Webhooks
+	var webhookDispatcher *webhooks.Dispatcher
+	if *webhooksJSON != "" {
+		var endpoints []webhooks.Endpoint
+		// the error isn't logged as it could include the secrets
+		err = json.Unmarshal([]byte(*webhooksJSON), &endpoints)
+		if err != nil {
+			log.Fatal().Msg(`invalid webhooks JSON, must be an array like '[{"id": "cms", "url": "https://...", "secret": "..."}]'`)
+		}
+
+		webhookDispatcher, err = webhooks.NewDispatcher(endpoints,
+			webhooks.WithDispatcherMaxAttempts(*webhookMaxAttempts),
+			webhooks.WithDispatcherTimeout(*webhookTimeout),
+			webhooks.WithDispatcherDeadLetterStore(ddbStorage),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("webhooks.NewDispatcher")
+		}
+		slinkOptions = append(slinkOptions, slink.WithEventListener(webhookDispatcher))
+	}
+
 	var authKeys []AuthKey
-	err = json.Unmarshal([]byte(*authKeysJSON), &authKeys)
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Str("authKeysJSON", *authKeysJSON).
-			Msg(`invalid auth keys JSON, must be an array like '[{"id": "foo", "token": "bar"}]'`)
+	if *authKeysJSON != "" {
+		// the error isn't logged as it could include parts of the auth keys
+		err = json.Unmarshal([]byte(*authKeysJSON), &authKeys)
+		if err != nil {
+			log.Fatal().Msg(`invalid auth keys JSON, must be an array like '[{"id": "foo", "tokenHash": "sha256:..."}]'`)
+		}
 	}
 
 	adminServer, err := NewAdminServer(ctx,
 		WithListenAddr(*listenAddr),
+		WithGRPCListenAddr(*grpcListenAddr),
 		WithSlinkOptions(slinkOptions...),
+		WithPublicBaseURL(*publicBaseURL),
 		WithAuthKeys(authKeys),
+		WithAuthKeysFile(*authKeysFile),
+		WithAuthKeysReloadInterval(*authKeysReload),
+		WithHMACReplayWindow(*hmacReplayWindow),
+		WithJWKSFile(*jwksFile),
+		WithJWTIssuer(*jwtIssuer),
+		WithJWTAudience(*jwtAudience),
+		WithStreamingTimeout(*streamingTimeout),
+		WithBulkConcurrency(*bulkConcurrency),
+		WithIdempotencyTTL(*idempotencyTTL),
+		WithRateLimit(*rateLimit, *rateLimitBurst),
+		WithDailyCreateQuota(*dailyCreateQuota),
+		WithAuditSink(auditSink),
+		WithTLSCertFiles(*tlsCertFile, *tlsKeyFile),
+		WithTLSClientCAFile(*tlsClientCAFile),
+		WithTLSRequireClientCert(*tlsRequireClient),
 	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("NewAdminServer")
@@ -182,6 +345,17 @@ func main() {
 	}()
 	log.Info().Str("addr", *listenAddr).Msg("admin server started")
 
+	if *grpcListenAddr != "" {
+		go func() {
+			err := adminServer.ListenAndServeGRPC()
+			if err != nil {
+				log.Fatal().Err(err).Msg("admin server ListenAndServeGRPC returned an unexpected error")
+			}
+			log.Info().Msg("gRPC admin server closed")
+		}()
+		log.Info().Str("addr", *grpcListenAddr).Msg("gRPC admin server started")
+	}
+
 	var debugServer *debug.DebugServer
 	if *debugListenAddr != "" {
 		debugServer, err := debug.NewDebugServer(*debugListenAddr)
@@ -213,5 +387,23 @@ func main() {
 		log.Fatal().Err(err).Msg("admin server shutdown failed")
 	}
 
+	if webhookDispatcher != nil {
+		err = webhookDispatcher.Close(gracefulShutdownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("webhook dispatcher close failed")
+		}
+	}
+
 	log.Info().Msg("admin server gracefully shut down, bye")
 }
+
+// splitList splits a comma separated flag value, ignoring empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/qr"
+	"github.com/ronny/slink/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// openAPIPath is where the OpenAPI document of the admin API is served,
+// without authentication.
+const openAPIPath = "/openapi.json"
+
+// registeredRoute is a route registered with apiRoute or streamingAPIRoute,
+// which should be described in apiOperations.
+type registeredRoute struct {
+	method string
+	path   string
+	scope  string
+}
+
+// apiOperation describes a route of the admin API for the OpenAPI document.
+// Request and response bodies are described by example values, whose types
+// are turned into JSON schemas by their `json` struct tags.
+type apiOperation struct {
+	summary     string
+	description string
+	params      []apiParam
+	// the request body, if any, is JSON unless requestContentTypes is set
+	requestBody         any
+	requestContentTypes []string
+	// the response body is JSON unless responseContentTypes is set, in which
+	// case it's the schema of each line (NDJSON) or row (CSV), or binary (e.g.
+	// an image) if response is nil
+	response             any
+	responseContentTypes []string
+	idempotent           bool
+}
+
+type apiParam struct {
+	name        string
+	in          string // "query" (the default) or "path"
+	description string
+	schemaType  string // "string" (the default) or "integer"
+	enum        []string
+}
+
+var listQueryParams = []apiParam{
+	{name: "createdAfter", description: "only short links created at or after this time (RFC3339)"},
+	{name: "createdBefore", description: "only short links created at or before this time (RFC3339)"},
+	{name: "status", description: "only active or only expired short links", enum: []string{"active", "expired"}},
+	{name: "linkUrl", description: "only short links to this link URL, or an equivalent one (compared normalised)"},
+	{name: "limit", description: "the maximum number of short links in the page", schemaType: "integer"},
+	{name: "cursor", description: "the nextCursor of the previous page"},
+}
+
+var shortLinkIDParam = apiParam{name: "id", in: "path", description: "the short link ID"}
+
+// apiOperations describes every API route, keyed by method and path as
+// registered with the router.
+var apiOperations = map[string]*apiOperation{
+	"POST /get-or-create-short-link": {
+		summary:     "Get the most recent active short link to a URL, or create one",
+		requestBody: slink.CreateInput{},
+		response:    models.ShortLink{},
+		idempotent:  true,
+	},
+	"POST /create-short-link": {
+		summary:     "Create a short link",
+		description: "A new short link is created even if there are others to the same URL.",
+		requestBody: slink.CreateInput{},
+		response:    models.ShortLink{},
+		idempotent:  true,
+	},
+	"POST /bulk-create-short-links": {
+		summary: "Create many short links",
+		description: "Each NDJSON line or CSV row (after the header row) is a CreateInput. " +
+			"A result is streamed back as an NDJSON line for each row, in no particular order.",
+		params: []apiParam{
+			{name: "mode", description: "whether each row is created, or got or created", enum: []string{"create", "get-or-create"}},
+		},
+		requestBody:          slink.CreateInput{},
+		requestContentTypes:  []string{"application/x-ndjson", "text/csv"},
+		response:             bulkResult{},
+		responseContentTypes: []string{"application/x-ndjson"},
+	},
+	"GET /short-links": {
+		summary: "List short links, newest first",
+		params: append([]apiParam{
+			{name: "tag", description: "only short links with this tag"},
+		}, listQueryParams...),
+		response: storage.ListPage{},
+	},
+	"GET /short-links-by-tag/:tag": {
+		summary: "List short links with a tag, newest first",
+		params: append([]apiParam{
+			{name: "tag", in: "path", description: "the tag"},
+		}, listQueryParams...),
+		response: storage.ListPage{},
+	},
+	"GET /export-short-links": {
+		summary:     "Export every short link",
+		description: "Short links are streamed in no particular order. A failed export closes the connection before the end of the response, so that reading it fails rather than it looking complete.",
+		params: []apiParam{
+			{name: "format", enum: []string{"ndjson", "csv"}},
+		},
+		response:             models.ShortLink{},
+		responseContentTypes: []string{"application/x-ndjson", "text/csv"},
+	},
+	"GET /short-link/:id": {
+		summary:  "Get a short link",
+		params:   []apiParam{shortLinkIDParam},
+		response: models.ShortLink{},
+	},
+	"PATCH /short-link/:id": {
+		summary:     "Update the link URL of a short link",
+		params:      []apiParam{shortLinkIDParam},
+		requestBody: slink.UpdateInput{},
+		response:    models.ShortLink{},
+	},
+	"GET /short-link/:id/history": {
+		summary:  "Get the changes made to a short link, oldest first",
+		params:   []apiParam{shortLinkIDParam},
+		response: []models.ShortLinkChange{},
+	},
+	"GET /short-link/:id/qr": {
+		summary: "Get the QR code of a short link",
+		description: "The QR code encodes the short link URL under baseUrl, or the public base URL the admin server is " +
+			"configured with, as a PNG or SVG image.",
+		params: []apiParam{
+			shortLinkIDParam,
+			{name: "baseUrl", description: "the URL of the public server, e.g. https://sho.rt"},
+			{name: "format", enum: []string{qr.FormatPNG, qr.FormatSVG}},
+			{name: "size", description: "the width and height in pixels, 256 by default", schemaType: "integer"},
+			{name: "level", description: "the error correction level, M by default", enum: []string{"L", "M", "Q", "H"}},
+			{name: "margin", description: "the width of the blank border in modules, 4 by default", schemaType: "integer"},
+		},
+		responseContentTypes: []string{"image/png", "image/svg+xml"},
+	},
+	"POST /short-link/:id/expire": {
+		summary:     "Expire a short link now",
+		params:      []apiParam{shortLinkIDParam},
+		requestBody: slink.ExpireInput{},
+		response:    models.ShortLink{},
+	},
+	"POST /expire-short-links-by-url": {
+		summary:     "Expire every active short link to a URL now",
+		requestBody: expireShortLinksByURLInput{},
+		response:    []models.ShortLink{},
+	},
+}
+
+func (s *AdminServer) handleOpenAPI() http.HandlerFunc {
+	spec, err := json.Marshal(s.openAPIDocument())
+	if err != nil {
+		panic("BUG: json.Marshal OpenAPI document: " + err.Error())
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// openAPIDocument returns the OpenAPI 3 document describing the registered
+// routes.
+func (s *AdminServer) openAPIDocument() map[string]any {
+	schemas := &openAPISchemas{components: map[string]any{}}
+	errorResponse := map[string]any{
+		"description": "error, see the code",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemas.schemaOf(reflect.TypeOf(APIError{}))},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, route := range s.routes {
+		op, ok := apiOperations[route.method+" "+route.path]
+		if !ok {
+			log.Warn().Str("method", route.method).Str("path", route.path).Msg("route missing from apiOperations, leaving it out of the OpenAPI document")
+			continue
+		}
+
+		operation := map[string]any{
+			"summary":          op.summary,
+			"x-required-scope": route.scope,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "success",
+					"content":     schemas.content(op.response, op.responseContentTypes),
+				},
+				"default": errorResponse,
+			},
+		}
+		operation["description"] = strings.TrimSpace(op.description + " Requires the `" + route.scope + "` scope.")
+
+		var params []any
+		for _, param := range op.params {
+			params = append(params, param.openAPI())
+		}
+		if op.idempotent {
+			params = append(params, map[string]any{
+				"name":        idempotencyKeyHeader,
+				"in":          "header",
+				"description": "retries with the same key get the response to the first request",
+				"schema":      map[string]any{"type": "string", "maxLength": 255},
+			})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if op.requestBody != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  schemas.content(op.requestBody, op.requestContentTypes),
+			}
+		}
+
+		path := openAPIPathOf(route.path)
+		pathItem, _ := paths[path].(map[string]any)
+		if pathItem == nil {
+			pathItem = map[string]any{}
+			paths[path] = pathItem
+		}
+		pathItem[strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "slink admin API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+				"hmacAuth": map[string]any{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "a " + HMACAuthScheme + " signature of the request, see the README",
+				},
+			},
+		},
+		"security": []any{
+			map[string]any{"bearerAuth": []string{}},
+			map[string]any{"hmacAuth": []string{}},
+		},
+	}
+}
+
+// openAPIPathOf converts httprouter path parameters (`:id`) to OpenAPI ones
+// (`{id}`).
+func openAPIPathOf(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (p apiParam) openAPI() map[string]any {
+	in := p.in
+	if in == "" {
+		in = "query"
+	}
+	schemaType := p.schemaType
+	if schemaType == "" {
+		schemaType = "string"
+	}
+
+	schema := map[string]any{"type": schemaType}
+	if len(p.enum) > 0 {
+		schema["enum"] = p.enum
+	}
+
+	param := map[string]any{
+		"name":     p.name,
+		"in":       in,
+		"required": in == "path",
+		"schema":   schema,
+	}
+	if p.description != "" {
+		param["description"] = p.description
+	}
+	return param
+}
+
+// openAPISchemas collects the schemas of named types as components, so that
+// they're only described once.
+type openAPISchemas struct {
+	components map[string]any
+}
+
+func (c *openAPISchemas) content(v any, contentTypes []string) map[string]any {
+	if len(contentTypes) == 0 {
+		contentTypes = []string{"application/json"}
+	}
+
+	schema := map[string]any{"type": "string", "format": "binary"}
+	if v != nil {
+		schema = c.schemaOf(reflect.TypeOf(v))
+	}
+	content := map[string]any{}
+	for _, contentType := range contentTypes {
+		content[contentType] = map[string]any{"schema": schema}
+	}
+	return content
+}
+
+func (c *openAPISchemas) schemaOf(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return c.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": c.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": c.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := c.components[name]; ok {
+			return ref
+		}
+		// reserved first, in case the type refers to itself
+		c.components[name] = nil
+
+		schema := map[string]any{"type": "object"}
+		properties := map[string]any{}
+		var required []string
+		c.addProperties(t, properties, &required)
+		if len(properties) > 0 {
+			schema["properties"] = properties
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		c.components[name] = schema
+		return ref
+	}
+
+	// e.g. `any`
+	return map[string]any{}
+}
+
+// addProperties adds the JSON fields of struct t, including the ones promoted
+// from embedded structs, like `encoding/json` does.
+func (c *openAPISchemas) addProperties(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				c.addProperties(embedded, properties, required)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := c.schemaOf(field.Type)
+		if field.Type.Kind() == reflect.String && strings.HasSuffix(field.Name, "At") {
+			schema["format"] = "date-time"
+		}
+		properties[name] = schema
+
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+)
+
+func newTestAdminServer(t *testing.T) *AdminServer {
+	t.Helper()
+
+	s, err := NewAdminServer(context.Background(),
+		WithAuthKeys([]AuthKey{{ID: "test", Token: "test"}}),
+		WithSlinkOptions(slink.WithStorage(storage.NewMemoryStorage())),
+	)
+	if err != nil {
+		t.Fatalf("NewAdminServer: %v", err)
+	}
+	return s
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	s := newTestAdminServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, openAPIPath, nil)
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: got status %d, want %d", openAPIPath, rec.Code, http.StatusOK)
+	}
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	if len(s.routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+
+	registered := make(map[string]bool, len(s.routes))
+	for _, route := range s.routes {
+		key := route.method + " " + route.path
+		registered[key] = true
+
+		if _, ok := doc.Paths[openAPIPathOf(route.path)][strings.ToLower(route.method)]; !ok {
+			t.Errorf("%s is not described in the OpenAPI document, add it to apiOperations", key)
+		}
+	}
+
+	for key := range apiOperations {
+		if !registered[key] {
+			t.Errorf("%s is described in apiOperations but not registered", key)
+		}
+	}
+}
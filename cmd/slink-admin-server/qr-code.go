@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ronny/slink/qr"
+	"github.com/rs/zerolog/log"
+)
+
+// handleGetShortLinkQRCode renders the QR code of a short link's public URL,
+// under the `baseUrl` query parameter or else WithPublicBaseURL, as described
+// by the qr.OptionsFromQuery query parameters.
+func (s *AdminServer) handleGetShortLinkQRCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		params := httprouter.ParamsFromContext(ctx)
+		query := r.URL.Query()
+
+		shortLinkID := params.ByName("id")
+
+		opts, err := qr.OptionsFromQuery(query)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		baseURL := query.Get("baseUrl")
+		if baseURL == "" {
+			baseURL = s.publicBaseURL
+		}
+		if baseURL == "" {
+			writeErrorCode(w, r, http.StatusBadRequest, ErrCodeInvalidQROptions, "baseUrl is required, as the admin server has no public base URL configured")
+			return
+		}
+		shortLinkURL, err := qr.ShortLinkURL(baseURL, shortLinkID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		shortLink, err := s.svc.GetShortLinkByID(ctx, shortLinkID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if shortLink == nil {
+			log.Debug().Str("shortLinkID", shortLinkID).Msg("handleGetShortLinkQRCode: short link not found")
+			writeErrorCode(w, r, http.StatusNotFound, ErrCodeShortLinkNotFound, "short link not found")
+			return
+		}
+
+		img, err := qr.Encode(shortLinkURL, opts)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", opts.ContentType())
+		w.WriteHeader(http.StatusOK)
+		w.Write(img)
+	}
+}
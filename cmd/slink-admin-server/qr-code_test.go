@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ronny/slink"
+)
+
+func TestGetShortLinkQRCode(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantContentType string
+		wantCode        string
+	}{
+		{name: "PNG by default", path: "/short-link/abc/qr", wantStatus: http.StatusOK, wantContentType: "image/png"},
+		{name: "SVG", path: "/short-link/abc/qr?format=svg&size=64", wantStatus: http.StatusOK, wantContentType: "image/svg+xml"},
+		{name: "size too large", path: "/short-link/abc/qr?size=4096", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidQROptions},
+		{name: "invalid base URL", path: "/short-link/abc/qr?baseUrl=sho.rt", wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidQROptions},
+		{name: "short link not found", path: "/short-link/nope/qr", wantStatus: http.StatusNotFound, wantCode: ErrCodeShortLinkNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestAdminServer(t)
+			s.publicBaseURL = "https://sho.rt"
+			_, err := s.svc.CreateShortLink(context.Background(), &slink.CreateInput{ID: "abc", LinkURL: "https://example.com/"})
+			if err != nil {
+				t.Fatalf("CreateShortLink: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer test")
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, req)
+
+			var apiErr APIError
+			if tt.wantCode != "" {
+				_ = json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			}
+			if rec.Code != tt.wantStatus || apiErr.Code != tt.wantCode {
+				t.Fatalf("GET %s: got status %d, code %q, want %d, %q: %.200s", tt.path, rec.Code, apiErr.Code, tt.wantStatus, tt.wantCode, rec.Body)
+			}
+			if tt.wantContentType != "" && rec.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("got content type %q, want %q", rec.Header().Get("Content-Type"), tt.wantContentType)
+			}
+		})
+	}
+}
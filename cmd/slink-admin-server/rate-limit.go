@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ronny/slink/debug"
+	"github.com/ronny/slink/storage"
+)
+
+// DefaultRateLimiterCacheSize is the number of auth keys whose token buckets
+// are remembered, the least recently used ones are forgotten (i.e. refilled).
+const DefaultRateLimiterCacheSize = 10000
+
+// tokenBucket allows rate requests per second on average, and bursts of up
+// to burst requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// take takes a token if there's one. It returns the number of tokens left, and
+// how long until the bucket is full again, or until there's a token when
+// there's none.
+func (b *tokenBucket) take(now time.Time) (bool, int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false, 0, secondsToDuration((1 - b.tokens) / b.rate)
+	}
+	b.tokens--
+	return true, int(b.tokens), secondsToDuration((b.burst - b.tokens) / b.rate)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// rateLimitOf returns the rate limit of the auth key, or a zero rate when it
+// isn't limited.
+func (s *AdminServer) rateLimitOf(authKey *AuthKey) (float64, int) {
+	rate, burst := s.rateLimit, s.rateLimitBurst
+	if authKey.RateLimit > 0 {
+		rate = authKey.RateLimit
+	}
+	if authKey.RateLimitBurst > 0 {
+		burst = authKey.RateLimitBurst
+	}
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return rate, burst
+}
+
+func (s *AdminServer) dailyCreateQuotaOf(authKey *AuthKey) int {
+	if authKey.DailyCreateQuota > 0 {
+		return authKey.DailyCreateQuota
+	}
+	return s.dailyCreateQuota
+}
+
+// tokenBucketOf returns the token bucket of the auth key, which is replaced
+// when the rate limit of the key changes.
+func (s *AdminServer) tokenBucketOf(keyID string, rate float64, burst int, now time.Time) *tokenBucket {
+	cacheKey := fmt.Sprintf("%s\n%g\n%d", keyID, rate, burst)
+	if bucket, ok := s.rateLimiters.Get(cacheKey); ok {
+		return bucket.(*tokenBucket)
+	}
+
+	bucket := newTokenBucket(rate, burst, now)
+	if previous, ok, _ := s.rateLimiters.PeekOrAdd(cacheKey, bucket); ok {
+		// added concurrently
+		return previous.(*tokenBucket)
+	}
+	return bucket
+}
+
+// useCreateQuota counts n short links created by the auth key against its
+// daily create quota (reset at midnight UTC), failing with
+// storage.ErrQuotaExceeded when there aren't enough left.
+func (s *AdminServer) useCreateQuota(ctx context.Context, authKey *AuthKey, n int, now time.Time) error {
+	quota := s.dailyCreateQuotaOf(authKey)
+	if quota <= 0 || s.quotaStore == nil {
+		return nil
+	}
+
+	day := now.UTC().Format("2006-01-02")
+	// kept for a day longer, in case of clock skew between processes
+	expiresAt := startOfNextDay(now).Add(24 * time.Hour)
+
+	_, err := s.quotaStore.IncrementQuotaUsage(ctx, "create:"+authKey.ID+":"+day, n, quota, expiresAt)
+	return err
+}
+
+// AllowCreate uses the daily create quota of the auth key of the request for n
+// short links, see slink.CreateLimiter, so that the quota is only used by
+// short links that are actually created.
+func (s *AdminServer) AllowCreate(ctx context.Context, n int) error {
+	authKey := authKeyFromContext(ctx)
+	if authKey == nil {
+		return nil
+	}
+	return s.useCreateQuota(ctx, authKey, n, time.Now())
+}
+
+// countQuotaExceeded counts a request of the route (or gRPC method) in ctx
+// rejected because its auth key used up its quota.
+func countQuotaExceeded(ctx context.Context) {
+	debug.ThrottledRequests().WithLabelValues(routePathFromContext(ctx), "quota").Inc()
+}
+
+// setQuotaExceededHeaders sets the headers of a response to a request that
+// used up the daily create quota.
+func setQuotaExceededHeaders(w http.ResponseWriter, qerr *storage.ErrQuotaExceeded, now time.Time) {
+	wait := startOfNextDay(now).Sub(now)
+	setRateLimitHeaders(w, qerr.Limit, 0, wait)
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+}
+
+type routePathCtxKey struct{}
+
+// routePathFromContext returns the path of the route (or the gRPC method) of
+// the request, for metrics.
+func routePathFromContext(reqCtx context.Context) string {
+	path, _ := reqCtx.Value(routePathCtxKey{}).(string)
+	return path
+}
+
+// startOfNextDay returns the next midnight UTC after now.
+func startOfNextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// limitRequests rejects the requests of auth keys over their rate limit with
+// 429 and a `Retry-After` header. The `RateLimit-*` headers describe the rate
+// limit. The daily create quota is used when short links are created, see
+// AllowCreate.
+func (s *AdminServer) limitRequests(path string, h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		authKey := authKeyFromContext(ctx)
+		now := time.Now()
+
+		if rate, burst := s.rateLimitOf(authKey); rate > 0 {
+			ok, remaining, wait := s.tokenBucketOf(authKey.ID, rate, burst, now).take(now)
+			setRateLimitHeaders(w, burst, remaining, wait)
+			if !ok {
+				debug.ThrottledRequests().WithLabelValues(path, "rate").Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+				writeJSON(w, http.StatusTooManyRequests, &APIError{
+					Code:      ErrCodeRateLimited,
+					Message:   fmt.Sprintf("the auth key is limited to %g requests per second", rate),
+					Details:   map[string]any{"retryAfterSeconds": ceilSeconds(wait)},
+					RequestID: requestIDFromContext(ctx),
+				})
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(ctx, routePathCtxKey{}, path)))
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining int, reset time.Duration) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+}
+
+// ceilSeconds rounds d up to whole seconds, and to at least one second.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// withRequestID identifies each request by the X-Request-Id header of the
+// request (e.g. set by a proxy) if it's sensible, or by a random ID otherwise.
+// The ID is echoed in the response header and included in error responses.
+func withRequestID(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDRe.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		h(w, r.WithContext(context.WithValue(r.Context(), requestIDCtxKey, requestID)))
+	}
+}
+
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+type requestIDCtxKeyType struct{}
+
+var requestIDCtxKey = requestIDCtxKeyType{}
+
+func requestIDFromContext(reqCtx context.Context) string {
+	val := reqCtx.Value(requestIDCtxKey)
+	if id, ok := val.(string); ok {
+		return id
+	}
+	return ""
+}
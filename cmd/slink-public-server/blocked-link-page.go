@@ -0,0 +1,27 @@
+package main
+
+import "net/http"
+
+// blockedLinkPage is shown instead of redirecting to a LinkURL that matches
+// the blocklist, without revealing the LinkURL.
+const blockedLinkPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link disabled</title>
+</head>
+<body>
+<h1>This link has been disabled</h1>
+<p>The website it pointed to has been reported as unsafe, so you haven't been redirected to it.</p>
+</body>
+</html>
+`
+
+func writeBlockedLinkPage(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(blockedLinkPage))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/ronny/slink"
+	"github.com/ronny/slink/blocklist"
 	"github.com/ronny/slink/debug"
 	"github.com/ronny/slink/storage"
 	"github.com/ronny/slink/tracking"
@@ -36,8 +37,15 @@ func main() {
 		fallbackRedirectURL = fs.String("fallback-redirect-url", "", "when specified, and a lookup can't find a ShortLink, then it redirects to this URL as a fallback (optional)")
 		prettyLog           = fs.Bool("pretty-log", false, "whether to enable logs pretty-printing (inefficient), otherwise json")
 		logLevel            = fs.String("log-level", "info", "set the minimum log level")
+		cacheTTL            = fs.Duration("cache-ttl", slink.DefaultCacheTTL, "how long a looked up short link is cached in memory before it's looked up again, so that changes made via the admin server are picked up (0 caches until evicted)")
 		trackingMethod      = fs.String("tracking", "", "when specified, enables tracking and also specifies the tracking method (only 'sns' is supported at the moment)")
 		snsTopicARN         = fs.String("sns-topic-arn", "", "when tracking=sns, this is the required ARN of the SNS Topic to send tracking information to")
+		tlsCertFile         = fs.String("tls-cert-file", "", "the PEM certificate (chain) file to serve TLS with, reloaded when it changes (optional, requires -tls-key-file)")
+		tlsKeyFile          = fs.String("tls-key-file", "", "the PEM private key file of -tls-cert-file, reloaded when it changes")
+		qrBaseURL           = fs.String("qr-base-url", "", "when specified, serves the QR codes of short links at /:id/qr, encoding their URLs under this base URL, e.g. `https://sho.rt` (optional)")
+		blockedDomainsFile  = fs.String("blocklist-domains-file", "", "a file of blocked domains (one per line, also blocking their subdomains), short links to them aren't redirected to, reloaded when it changes (optional)")
+		blockedPrefixesFile = fs.String("blocklist-url-prefixes-file", "", "a file of blocked URL prefixes (one per line), short links to URLs starting with them aren't redirected to, reloaded when it changes (optional)")
+		blocklistReload     = fs.Duration("blocklist-reload-interval", blocklist.DefaultReloadInterval, "how often the blocklist files are checked for changes")
 		_                   = fs.String("config", "", "config file (optional)")
 	)
 	err := ff.Parse(fs, os.Args[1:],
@@ -106,15 +114,19 @@ func main() {
 		if err != nil {
 			log.Fatal().Err(err).Msg("storage.NewDynamoDBStorage")
 		}
-		slinkOptions = append(slinkOptions, slink.WithStorage(ddblocal))
+		slinkOptions = append(slinkOptions,
+			slink.WithStorage(ddblocal),
+			slink.WithCacheTTL(*cacheTTL),
+		)
 	}
 
 	log.Info().
 		Str("dynamodbEndpoint", *dynamodbEndpoint).
-		Str("awsAccessKeyID", *awsAccessKeyID).
 		Str("debugListenAddr", *debugListenAddr).
 		Str("fallback-redirect-url", *fallbackRedirectURL).
 		Str("trackingMethod", *trackingMethod).
+		Str("tlsCertFile", *tlsCertFile).
+		Str("qrBaseURL", *qrBaseURL).
 		Msg("slink-public-server flags")
 
 	publicServerOpts := []func(*PublicServer){
@@ -122,10 +134,32 @@ func main() {
 		WithSlinkOptions(slinkOptions...),
 	}
 
+	if *tlsCertFile != "" || *tlsKeyFile != "" {
+		publicServerOpts = append(publicServerOpts, WithTLSCertFiles(*tlsCertFile, *tlsKeyFile))
+	}
+
 	if *fallbackRedirectURL != "" {
 		publicServerOpts = append(publicServerOpts, WithFallbackRedirectURL(*fallbackRedirectURL))
 	}
 
+	if *blockedDomainsFile != "" || *blockedPrefixesFile != "" {
+		bl, err := blocklist.NewBlocklist(
+			blocklist.WithDomainsFile(*blockedDomainsFile),
+			blocklist.WithURLPrefixesFile(*blockedPrefixesFile),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("blocklist.NewBlocklist")
+		}
+		stopWatchingBlocklist := make(chan struct{})
+		defer close(stopWatchingBlocklist)
+		go bl.Watch(*blocklistReload, stopWatchingBlocklist)
+		publicServerOpts = append(publicServerOpts, WithBlocklist(bl))
+	}
+
+	if *qrBaseURL != "" {
+		publicServerOpts = append(publicServerOpts, WithQRCodes(*qrBaseURL))
+	}
+
 	if *trackingMethod != "" {
 		if *trackingMethod != "sns" {
 			log.Fatal().Str("trackingMethod", *trackingMethod).Msg("only 'sns' tracking is supported")
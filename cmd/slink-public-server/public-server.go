@@ -11,6 +11,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ronny/slink"
 	"github.com/ronny/slink/debug"
+	"github.com/ronny/slink/qr"
+	"github.com/ronny/slink/tlsconfig"
 	"github.com/ronny/slink/tracking"
 )
 
@@ -22,6 +24,13 @@ type PublicServer struct {
 	tracker             tracking.Tracker
 	payloadBuilder      *tracking.PayloadBuilder
 	slinkOptions        []func(*slink.Slink)
+	qrBaseURL           string
+	blocklist           slink.Blocklist
+
+	tlsCertFile       string
+	tlsKeyFile        string
+	certReloader      *tlsconfig.CertReloader
+	stopWatchingFiles chan struct{}
 }
 
 const (
@@ -41,6 +50,22 @@ func NewPublicServer(ctx context.Context, options ...func(*PublicServer)) (*Publ
 		option(s)
 	}
 
+	if s.tlsCertFile != "" || s.tlsKeyFile != "" {
+		var err error
+		s.certReloader, err = tlsconfig.NewCertReloader(s.tlsCertFile, s.tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tlsconfig.NewCertReloader: %w", err)
+		}
+		s.TLSConfig = tlsconfig.NewServerConfig(s.certReloader)
+	}
+
+	if s.qrBaseURL != "" {
+		_, err := qr.ShortLinkURL(s.qrBaseURL, "")
+		if err != nil {
+			return nil, fmt.Errorf("qr.ShortLinkURL: %w", err)
+		}
+	}
+
 	var err error
 	s.svc, err = slink.NewSlink(ctx, s.slinkOptions...)
 	if err != nil {
@@ -50,11 +75,37 @@ func NewPublicServer(ctx context.Context, options ...func(*PublicServer)) (*Publ
 	s.router = httprouter.New()
 	s.router.GET("/", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) { w.WriteHeader(http.StatusOK) })
 	s.apiRoute(http.MethodGet, "/:id", s.handleShortLinkLookup())
+	if s.qrBaseURL != "" {
+		s.apiRoute(http.MethodGet, "/:id/qr", s.handleShortLinkQRCode())
+	}
 	s.Handler = s.router
 
+	s.stopWatchingFiles = make(chan struct{})
+	if s.certReloader != nil {
+		go s.certReloader.Watch(tlsconfig.DefaultReloadInterval, s.stopWatchingFiles)
+	}
+
 	return s, nil
 }
 
+// ListenAndServe listens with TLS when a certificate is specified, see
+// WithTLSCertFiles.
+func (s *PublicServer) ListenAndServe() error {
+	if s.TLSConfig != nil {
+		// the certificate comes from TLSConfig.GetCertificate
+		return s.Server.ListenAndServeTLS("", "")
+	}
+	return s.Server.ListenAndServe()
+}
+
+func (s *PublicServer) Shutdown(ctx context.Context) error {
+	s.SetKeepAlivesEnabled(false)
+	if s.stopWatchingFiles != nil {
+		close(s.stopWatchingFiles)
+	}
+	return s.Server.Shutdown(ctx)
+}
+
 func (s *PublicServer) apiRoute(method, path string, handler http.Handler) {
 	labelsWithPath := prometheus.Labels{"path": path}
 
@@ -99,3 +150,30 @@ func WithTracker(tracker tracking.Tracker, trustedHeaders []string) func(*Public
 		ps.payloadBuilder = tracking.NewPayloadBuilder(trustedHeaders)
 	}
 }
+
+// WithQRCodes serves the QR codes of short links at `/:id/qr`, encoding their
+// URLs under baseURL, which is the URL this server is reached at, e.g.
+// `https://sho.rt`.
+func WithQRCodes(baseURL string) func(*PublicServer) {
+	return func(ps *PublicServer) {
+		ps.qrBaseURL = baseURL
+	}
+}
+
+// WithBlocklist refuses to redirect to LinkURLs matching the blocklist, e.g.
+// when their domain was taken over after the ShortLink was created, redirecting
+// to the fallback URL or showing a warning page instead.
+func WithBlocklist(blocklist slink.Blocklist) func(*PublicServer) {
+	return func(ps *PublicServer) {
+		ps.blocklist = blocklist
+	}
+}
+
+// WithTLSCertFiles specifies the PEM certificate (chain) and key files to
+// serve TLS with, which are reloaded when they change.
+func WithTLSCertFiles(certFile, keyFile string) func(*PublicServer) {
+	return func(ps *PublicServer) {
+		ps.tlsCertFile = certFile
+		ps.tlsKeyFile = keyFile
+	}
+}
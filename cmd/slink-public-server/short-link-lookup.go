@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/ronny/slink/debug"
 	"github.com/ronny/slink/models"
 	"github.com/rs/zerolog/log"
 )
@@ -52,6 +53,50 @@ func (s *PublicServer) handleShortLinkLookup() http.HandlerFunc {
 			return
 		}
 
+		if shortLink.NotYetActive() {
+			// 302 rather than 307, so the coming soon redirect can be told apart
+			// from the redirect to LinkURL once the ShortLink is active.
+			location := shortLink.ComingSoonURL
+			if location != "" && s.blocklist != nil {
+				if entry, blocked := s.blocklist.Blocked(location); blocked {
+					debug.BlockedLinkURLs().WithLabelValues("redirect").Inc()
+					log.Warn().Str("shortLinkID", shortLinkID).Str("blocklistEntry", entry).Msg("coming soon URL matches the blocklist, not redirecting")
+					location = ""
+				}
+			}
+			if location == "" {
+				location = s.fallbackRedirectURL
+			}
+			if location != "" {
+				w.Header().Add("Location", location)
+				w.WriteHeader(http.StatusFound)
+				go s.trackShortLinkLookup(shortLinkID, shortLink, r, http.StatusFound, location)
+				return
+			}
+
+			w.WriteHeader(http.StatusNotFound)
+			go s.trackShortLinkLookup(shortLinkID, shortLink, r, http.StatusNotFound, "")
+			return
+		}
+
+		if s.blocklist != nil {
+			if entry, blocked := s.blocklist.Blocked(shortLink.LinkURL); blocked {
+				debug.BlockedLinkURLs().WithLabelValues("redirect").Inc()
+				log.Warn().Str("shortLinkID", shortLinkID).Str("blocklistEntry", entry).Msg("short link matches the blocklist, not redirecting")
+
+				if s.fallbackRedirectURL != "" {
+					w.Header().Add("Location", s.fallbackRedirectURL)
+					w.WriteHeader(http.StatusTemporaryRedirect)
+					go s.trackShortLinkLookup(shortLinkID, shortLink, r, http.StatusTemporaryRedirect, s.fallbackRedirectURL)
+					return
+				}
+
+				writeBlockedLinkPage(w)
+				go s.trackShortLinkLookup(shortLinkID, shortLink, r, http.StatusForbidden, "")
+				return
+			}
+		}
+
 		w.Header().Add("Location", shortLink.LinkURL)
 		w.WriteHeader(http.StatusTemporaryRedirect)
 		go s.trackShortLinkLookup(shortLinkID, shortLink, r, http.StatusTemporaryRedirect, shortLink.LinkURL)
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+)
+
+// testBlocklist blocks the link URLs starting with one of its entries.
+type testBlocklist []string
+
+func (b testBlocklist) Blocked(linkURL string) (string, bool) {
+	for _, entry := range b {
+		if strings.HasPrefix(linkURL, entry) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+func TestShortLinkLookup(t *testing.T) {
+	const fallbackURL = "https://example.net/fallback"
+
+	tests := []struct {
+		name         string
+		shortLink    *models.ShortLink
+		blocklist    testBlocklist
+		fallback     string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "redirected",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a"},
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/a",
+		},
+		{
+			name:       "not found",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "not found, with a fallback",
+			fallback:     fallbackURL,
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: fallbackURL,
+		},
+		{
+			name:       "expired",
+			shortLink:  &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ExpiresAt: "2020-01-01T00:00:00Z"},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name:         "another URL blocked",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a"},
+			blocklist:    testBlocklist{"https://example.org/"},
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/a",
+		},
+		{
+			name:       "blocked",
+			shortLink:  &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a"},
+			blocklist:  testBlocklist{"https://example.com/"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "blocked, with a fallback",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a"},
+			blocklist:    testBlocklist{"https://example.com/"},
+			fallback:     fallbackURL,
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: fallbackURL,
+		},
+
+		{
+			name:         "not yet active",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2099-01-01T00:00:00Z", ComingSoonURL: "https://example.com/soon"},
+			fallback:     fallbackURL,
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://example.com/soon",
+		},
+		{
+			name:         "not yet active, without a coming soon URL",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2099-01-01T00:00:00Z"},
+			fallback:     fallbackURL,
+			wantStatus:   http.StatusFound,
+			wantLocation: fallbackURL,
+		},
+		{
+			name:       "not yet active, without a coming soon URL or a fallback",
+			shortLink:  &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2099-01-01T00:00:00Z"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "not yet active, coming soon URL blocked",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2099-01-01T00:00:00Z", ComingSoonURL: "https://example.org/soon"},
+			blocklist:    testBlocklist{"https://example.org/"},
+			fallback:     fallbackURL,
+			wantStatus:   http.StatusFound,
+			wantLocation: fallbackURL,
+		},
+		{
+			name:       "not yet active, coming soon URL blocked, without a fallback",
+			shortLink:  &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2099-01-01T00:00:00Z", ComingSoonURL: "https://example.org/soon"},
+			blocklist:  testBlocklist{"https://example.org/"},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "activated",
+			shortLink:    &models.ShortLink{ID: "abc", LinkURL: "https://example.com/a", ActivatesAt: "2020-01-01T00:00:00Z", ComingSoonURL: "https://example.com/soon"},
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStorage()
+			if tt.shortLink != nil {
+				err := store.Create(ctx, tt.shortLink)
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+			}
+
+			options := []func(*PublicServer){WithSlinkOptions(slink.WithStorage(store))}
+			if tt.blocklist != nil {
+				options = append(options, WithBlocklist(tt.blocklist))
+			}
+			if tt.fallback != "" {
+				options = append(options, WithFallbackRedirectURL(tt.fallback))
+			}
+			s, err := NewPublicServer(ctx, options...)
+			if err != nil {
+				t.Fatalf("NewPublicServer: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+			if rec.Code != tt.wantStatus || rec.Header().Get("Location") != tt.wantLocation {
+				t.Fatalf("got status %d, location %q, want %d, %q", rec.Code, rec.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+			if rec.Code == http.StatusForbidden {
+				if rec.Body.String() != blockedLinkPage || rec.Header().Get("Cache-Control") != "no-store" {
+					t.Errorf("got body %q, Cache-Control %q, want the blocked link page, not stored", rec.Body, rec.Header().Get("Cache-Control"))
+				}
+				if strings.Contains(rec.Body.String(), tt.shortLink.LinkURL) {
+					t.Errorf("got the link URL in the blocked link page")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/ronny/slink/qr"
+	"github.com/rs/zerolog/log"
+)
+
+// handleShortLinkQRCode renders the QR code of an active short link's URL
+// under qrBaseURL, as described by the qr.OptionsFromQuery query parameters.
+// Unlike lookups, QR code requests aren't tracked or redirected to the
+// fallback URL.
+func (s *PublicServer) handleShortLinkQRCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		params := httprouter.ParamsFromContext(ctx)
+
+		shortLinkID := params.ByName("id")
+
+		opts, err := qr.OptionsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		shortLink, err := s.svc.GetShortLinkByIDWithCache(ctx, shortLinkID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Error().Err(err).Msg("svc.GetShortLinkByID error, returning 500")
+
+			return
+		}
+
+		if shortLink == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if shortLink.Expired() {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+
+		// qrBaseURL is validated by NewPublicServer
+		shortLinkURL, _ := qr.ShortLinkURL(s.qrBaseURL, shortLinkID)
+
+		img, err := qr.Encode(shortLinkURL, opts)
+		if err != nil {
+			var opterr *qr.ErrInvalidOptions
+			if errors.As(err, &opterr) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+
+			log.Error().Err(err).Msg("qr.Encode error, returning 500")
+
+			return
+		}
+
+		w.Header().Set("Content-Type", opts.ContentType())
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		w.Write(img)
+	}
+}
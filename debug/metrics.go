@@ -14,6 +14,9 @@ type Metrics struct {
 	shortLinkCreations       *prometheus.CounterVec
 	deniedShortLinkIDs       *prometheus.CounterVec
 	redirects                *prometheus.CounterVec
+	throttledRequests        *prometheus.CounterVec
+	webhookDeliveries        *prometheus.CounterVec
+	blockedLinkURLs          *prometheus.CounterVec
 }
 
 var globalMetrics *Metrics
@@ -44,6 +47,21 @@ func init() {
 			Namespace: Namespace,
 			Name:      "redirects_total",
 		}, []string{}),
+		throttledRequests: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "throttled_requests_total",
+			Help:      "total number of admin API requests rejected because the auth key exceeded its rate limit or quota",
+		}, []string{"path", "limit"}),
+		webhookDeliveries: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "total number of webhook delivery attempts and outcomes, by endpoint",
+		}, []string{"endpoint", "outcome"}),
+		blockedLinkURLs: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "blocked_link_urls_total",
+			Help:      "total number of link URLs refused because they matched the blocklist, when creating or updating short links, or redirecting to them",
+		}, []string{"action"}),
 	}
 }
 
@@ -54,3 +72,15 @@ func IncomingRequests() *prometheus.CounterVec {
 func IncomingRequestDurations() *prometheus.HistogramVec {
 	return globalMetrics.incomingRequestDurations
 }
+
+func ThrottledRequests() *prometheus.CounterVec {
+	return globalMetrics.throttledRequests
+}
+
+func WebhookDeliveries() *prometheus.CounterVec {
+	return globalMetrics.webhookDeliveries
+}
+
+func BlockedLinkURLs() *prometheus.CounterVec {
+	return globalMetrics.blockedLinkURLs
+}
@@ -1,9 +1,15 @@
 package slink
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ErrInvalidLinkURL struct {
-	msg string
+	// Reason is why the LinkURL is invalid, one of the LinkURLReason
+	// constants.
+	Reason string
+	msg    string
 }
 
 func (e *ErrInvalidLinkURL) Error() string {
@@ -25,3 +31,65 @@ type ErrCreateAttemptsExhausted struct {
 func (e *ErrCreateAttemptsExhausted) Error() string {
 	return fmt.Sprintf("ErrCreateAttemptsExhausted: failed to create after %d attempts", e.attempts)
 }
+
+type ErrShortLinkNotFound struct {
+	shortLinkID string
+}
+
+func (e *ErrShortLinkNotFound) Error() string {
+	return fmt.Sprintf("ErrShortLinkNotFound: short link with ID %s not found", e.shortLinkID)
+}
+
+type ErrInvalidListQuery struct {
+	msg string
+}
+
+func (e *ErrInvalidListQuery) Error() string {
+	return fmt.Sprintf("ErrInvalidListQuery: %s", e.msg)
+}
+
+type ErrInvalidTag struct {
+	msg string
+}
+
+func (e *ErrInvalidTag) Error() string {
+	return fmt.Sprintf("ErrInvalidTag: %s", e.msg)
+}
+
+type ErrInvalidMetadata struct {
+	msg string
+}
+
+func (e *ErrInvalidMetadata) Error() string {
+	return fmt.Sprintf("ErrInvalidMetadata: %s", e.msg)
+}
+
+type ErrInvalidActivatesAt struct {
+	msg string
+}
+
+func (e *ErrInvalidActivatesAt) Error() string {
+	return fmt.Sprintf("ErrInvalidActivatesAt: %s", e.msg)
+}
+
+// ErrRedirectLoop is returned when a LinkURL points to one of the own hosts
+// (see WithOwnHosts), and would redirect in a loop through ShortLinks, or
+// through more of them than the maximum depth.
+type ErrRedirectLoop struct {
+	// Chain is the IDs of the ShortLinks the LinkURL redirects through, in
+	// order, starting with the ShortLink being created or updated when its ID
+	// is known.
+	Chain []string
+	// TooDeep is whether the chain goes through too many ShortLinks, rather
+	// than looping back to one of them.
+	TooDeep  bool
+	maxDepth int
+}
+
+func (e *ErrRedirectLoop) Error() string {
+	chain := strings.Join(e.Chain, " -> ")
+	if !e.TooDeep {
+		return fmt.Sprintf("ErrRedirectLoop: link URL redirects in a loop: %s", chain)
+	}
+	return fmt.Sprintf("ErrRedirectLoop: link URL redirects through more than %d short links: %s", e.maxDepth, chain)
+}
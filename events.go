@@ -0,0 +1,49 @@
+package slink
+
+import (
+	"context"
+	"time"
+
+	"github.com/ronny/slink/models"
+)
+
+// The types of Events.
+const (
+	EventShortLinkCreated = "shortLink.created"
+	EventShortLinkUpdated = "shortLink.updated"
+	EventShortLinkExpired = "shortLink.expired"
+)
+
+// Event is a ShortLink being created or changed through Slink.
+type Event struct {
+	Type string `json:"type"`
+	Time string `json:"time"`
+	// ShortLink is the ShortLink as stored after the change.
+	ShortLink *models.ShortLink `json:"shortLink"`
+	// Change is the change as recorded in the ShortLink history, for updated
+	// and expired ShortLinks.
+	Change *models.ShortLinkChange `json:"change,omitempty"`
+}
+
+// EventListener is notified of every Event, synchronously once the change has
+// been stored, so it should return quickly (e.g. by queueing the Event). The
+// context is the one of the change, which may be cancelled soon after.
+type EventListener interface {
+	HandleEvent(ctx context.Context, event *Event)
+}
+
+func (s *Slink) emitEvent(ctx context.Context, eventType string, shortLink *models.ShortLink, change *models.ShortLinkChange) {
+	if len(s.eventListeners) == 0 {
+		return
+	}
+
+	event := &Event{
+		Type:      eventType,
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		ShortLink: shortLink,
+		Change:    change,
+	}
+	for _, listener := range s.eventListeners {
+		listener.HandleEvent(ctx, event)
+	}
+}
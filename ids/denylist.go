@@ -15,6 +15,12 @@ type Denylist = []string
 var defaultDenylistStr string
 var defaultDenylist Denylist = strings.Split(defaultDenylistStr, "\n")
 
+// DefaultDenylist returns the built-in denylist, used when no other denylist is
+// supplied.
+func DefaultDenylist() Denylist {
+	return defaultDenylist
+}
+
 func LoadDenylist(filename string) (Denylist, error) {
 	f, err := os.Open(filename)
 	if err != nil {
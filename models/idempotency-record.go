@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the response to a request made with an idempotency
+// key, kept so that retries of the same request get the same response instead
+// of repeating the request's side effects.
+type IdempotencyRecord struct {
+	// Key identifies the client and its idempotency key.
+	Key string `json:"key" dynamodbav:"key"`
+	// RequestHash identifies the request, so that a key can't be reused for a
+	// different request.
+	RequestHash string `json:"requestHash" dynamodbav:"requestHash"`
+	CreatedAt   string `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt   string `json:"expiresAt" dynamodbav:"expiresAt"`
+	// LockedUntil is when the request is given up on if its response hasn't
+	// been recorded by then, e.g. because the process handling it crashed, so
+	// that a retry can take over.
+	LockedUntil string `json:"lockedUntil,omitempty" dynamodbav:"lockedUntil,omitempty"`
+	// StatusCode and ResponseBody are empty while the request is in progress.
+	StatusCode   int    `json:"statusCode,omitempty" dynamodbav:"statusCode,omitempty"`
+	ResponseBody []byte `json:"responseBody,omitempty" dynamodbav:"responseBody,omitempty"`
+}
+
+// Completed returns true if the response to the request has been recorded.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Abandoned returns true if the response to the request hasn't been recorded
+// before LockedUntil.
+func (r *IdempotencyRecord) Abandoned() bool {
+	if r.Completed() || r.LockedUntil == "" {
+		return false
+	}
+	lockExpiry, err := time.Parse(time.RFC3339, r.LockedUntil)
+	if err != nil {
+		return true
+	}
+	return !lockExpiry.After(time.Now().UTC())
+}
+
+// Expired returns true if the record should no longer be used, or if its
+// ExpiresAt is invalid.
+func (r *IdempotencyRecord) Expired() bool {
+	expiry, err := time.Parse(time.RFC3339, r.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return !expiry.After(time.Now().UTC())
+}
@@ -0,0 +1,14 @@
+package models
+
+// ShortLinkChange records a single modification of an existing ShortLink, e.g.
+// its LinkURL being replaced to fix a typo, or it being expired early.
+type ShortLinkChange struct {
+	ShortLinkID       string `json:"shortLinkId" dynamodbav:"shortLinkId"`
+	ChangedAt         string `json:"changedAt" dynamodbav:"changedAt"`
+	ChangedBy         string `json:"changedBy,omitempty" dynamodbav:"changedBy,omitempty"`
+	Reason            string `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+	PreviousLinkURL   string `json:"previousLinkUrl" dynamodbav:"previousLinkUrl"`
+	LinkURL           string `json:"linkUrl" dynamodbav:"linkUrl"`
+	PreviousExpiresAt string `json:"previousExpiresAt,omitempty" dynamodbav:"previousExpiresAt,omitempty"`
+	ExpiresAt         string `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
+}
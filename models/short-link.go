@@ -7,10 +7,63 @@ import (
 )
 
 type ShortLink struct {
-	ID        string `json:"id" dynamodbav:"id"`
-	LinkURL   string `json:"linkUrl" dynamodbav:"linkUrl"`
-	CreatedAt string `json:"createdAt" dynamodbav:"createdAt"`
+	ID      string `json:"id" dynamodbav:"id"`
+	LinkURL string `json:"linkUrl" dynamodbav:"linkUrl"`
+	// NormalisedLinkURL is the normalised form of LinkURL, which ShortLinks
+	// are looked up by, while LinkURL is kept as is for redirects. It's empty
+	// for ShortLinks created before LinkURLs were normalised.
+	NormalisedLinkURL string `json:"normalisedLinkUrl,omitempty" dynamodbav:"normalisedLinkUrl,omitempty"`
+	CreatedAt         string `json:"createdAt" dynamodbav:"createdAt"`
+	// CreatedBy identifies who created the ShortLink (e.g. an auth key ID),
+	// empty for ShortLinks created before it was recorded.
+	CreatedBy string `json:"createdBy,omitempty" dynamodbav:"createdBy,omitempty"`
 	ExpiresAt string `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
+	// ActivatesAt is when the ShortLink starts redirecting to LinkURL, e.g.
+	// when the target page goes live. Until then, it redirects to
+	// ComingSoonURL (or the fallback URL).
+	ActivatesAt   string `json:"activatesAt,omitempty" dynamodbav:"activatesAt,omitempty"`
+	ComingSoonURL string `json:"comingSoonUrl,omitempty" dynamodbav:"comingSoonUrl,omitempty"`
+	// Tags (e.g. a campaign) that the ShortLink can be listed by.
+	Tags []string `json:"tags,omitempty" dynamodbav:"tags,omitempty"`
+	// Metadata is free-form, e.g. the owner team or a note.
+	Metadata map[string]string `json:"metadata,omitempty" dynamodbav:"metadata,omitempty"`
+}
+
+// HasTag returns true if tag is one of the ShortLink's Tags.
+func (sl *ShortLink) HasTag(tag string) bool {
+	for _, t := range sl.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexedLinkURL returns the URL that the ShortLink is looked up by, its
+// NormalisedLinkURL, or its LinkURL when it has none.
+func (sl *ShortLink) IndexedLinkURL() string {
+	if sl.NormalisedLinkURL != "" {
+		return sl.NormalisedLinkURL
+	}
+	return sl.LinkURL
+}
+
+// NotYetActive returns true if the ShortLink has an ActivatesAt in the future.
+func (sl *ShortLink) NotYetActive() bool {
+	if sl.ActivatesAt == "" {
+		return false
+	}
+
+	activation, err := time.Parse(time.RFC3339, sl.ActivatesAt)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("ActivatesAt", sl.ActivatesAt).
+			Msg("time.Parse ActivatesAt failed, assuming it's active")
+		return false
+	}
+
+	return activation.After(time.Now().UTC())
 }
 
 func (sl *ShortLink) Expired() bool {
@@ -27,5 +80,5 @@ func (sl *ShortLink) Expired() bool {
 		return false
 	}
 
-	return expiry.After(time.Now().UTC())
+	return !expiry.After(time.Now().UTC())
 }
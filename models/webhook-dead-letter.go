@@ -0,0 +1,17 @@
+package models
+
+// WebhookDeadLetter is a webhook delivery that was given up on, e.g. after the
+// endpoint kept failing, kept so that it can be looked into and redelivered.
+type WebhookDeadLetter struct {
+	EndpointID string `json:"endpointId" dynamodbav:"endpointId"`
+	URL        string `json:"url" dynamodbav:"url"`
+	EventID    string `json:"eventId" dynamodbav:"eventId"`
+	EventType  string `json:"eventType" dynamodbav:"eventType"`
+	// Payload is the JSON body of the delivery, as signed.
+	Payload  string `json:"payload" dynamodbav:"payload"`
+	Attempts int    `json:"attempts" dynamodbav:"attempts"`
+	// LastError describes why the last attempt failed, or why the delivery
+	// wasn't attempted (again).
+	LastError string `json:"lastError" dynamodbav:"lastError"`
+	CreatedAt string `json:"createdAt" dynamodbav:"createdAt"`
+}
@@ -0,0 +1,3 @@
+// Package qr renders QR codes of short link URLs as PNG or SVG images, with a
+// pure Go encoder so that it works without cgo (e.g. in a scratch image).
+package qr
@@ -0,0 +1,211 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// The image formats of QR codes.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+const (
+	DefaultFormat = FormatPNG
+	// DefaultSize is the width (and height) of QR codes in pixels.
+	DefaultSize = 256
+	MinSize     = 32
+	MaxSize     = 2048
+	// DefaultLevel is the error correction level of QR codes, which can
+	// recover from about 15% of the code being damaged.
+	DefaultLevel = "M"
+	// DefaultMargin is the width of the blank border around QR codes in
+	// modules (dots), as recommended by the QR code specification.
+	DefaultMargin = 4
+	MaxMargin     = 16
+)
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,     // ~7%
+	"M": qrcode.Medium,  // ~15%
+	"Q": qrcode.High,    // ~25%
+	"H": qrcode.Highest, // ~30%
+}
+
+// Options describe the image of a QR code.
+type Options struct {
+	// Format is FormatPNG or FormatSVG.
+	Format string
+	// Size is the width (and height) of the image in pixels. The modules of
+	// PNG images are a whole number of pixels wide, with any leftover pixels
+	// added to the margin.
+	Size int
+	// Level is the error correction level, one of L, M, Q or H.
+	Level string
+	// Margin is the width of the blank border in modules.
+	Margin int
+}
+
+// DefaultOptions returns the options used for anything not specified.
+func DefaultOptions() *Options {
+	return &Options{
+		Format: DefaultFormat,
+		Size:   DefaultSize,
+		Level:  DefaultLevel,
+		Margin: DefaultMargin,
+	}
+}
+
+// OptionsFromQuery returns the options in the `format`, `size`, `level` and
+// `margin` query parameters, with the default for any that's missing.
+func OptionsFromQuery(query url.Values) (*Options, error) {
+	opts := DefaultOptions()
+
+	if format := query.Get("format"); format != "" {
+		opts.Format = strings.ToLower(format)
+	}
+	if level := query.Get("level"); level != "" {
+		opts.Level = strings.ToUpper(level)
+	}
+
+	var err error
+	if size := query.Get("size"); size != "" {
+		opts.Size, err = strconv.Atoi(size)
+		if err != nil {
+			return nil, &ErrInvalidOptions{msg: "size must be an integer"}
+		}
+	}
+	if margin := query.Get("margin"); margin != "" {
+		opts.Margin, err = strconv.Atoi(margin)
+		if err != nil {
+			return nil, &ErrInvalidOptions{msg: "margin must be an integer"}
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+func (opts *Options) Validate() error {
+	if opts.Format != FormatPNG && opts.Format != FormatSVG {
+		return &ErrInvalidOptions{msg: fmt.Sprintf("format must be %s or %s", FormatPNG, FormatSVG)}
+	}
+	if opts.Size < MinSize || opts.Size > MaxSize {
+		return &ErrInvalidOptions{msg: fmt.Sprintf("size must be between %d and %d pixels", MinSize, MaxSize)}
+	}
+	if _, ok := levels[opts.Level]; !ok {
+		return &ErrInvalidOptions{msg: "level must be L, M, Q or H"}
+	}
+	if opts.Margin < 0 || opts.Margin > MaxMargin {
+		return &ErrInvalidOptions{msg: fmt.Sprintf("margin must be between 0 and %d modules", MaxMargin)}
+	}
+	return nil
+}
+
+// ContentType returns the MIME type of the image format.
+func (opts *Options) ContentType() string {
+	if opts.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Encode returns the image of the QR code of content, e.g. a short link URL.
+func Encode(content string, opts *Options) ([]byte, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.New(content, levels[opts.Level])
+	if err != nil {
+		return nil, &ErrInvalidOptions{msg: fmt.Sprintf("the content can't be encoded: %s", err)}
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	if opts.Format == FormatSVG {
+		return encodeSVG(modules, opts), nil
+	}
+	return encodePNG(modules, opts)
+}
+
+func encodePNG(modules [][]bool, opts *Options) ([]byte, error) {
+	width := len(modules) + 2*opts.Margin
+	scale := opts.Size / width
+	if scale < 1 {
+		return nil, &ErrInvalidOptions{msg: fmt.Sprintf("size must be at least %d pixels for this QR code and margin", width)}
+	}
+	offset := (opts.Size-scale*width)/2 + scale*opts.Margin
+
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{color.White, color.Black})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("png.Encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeSVG draws the dark modules as a single path, with a horizontal
+// rectangle for each run of them, in a view box with a unit per module.
+func encodeSVG(modules [][]bool, opts *Options) []byte {
+	width := len(modules) + 2*opts.Margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, width, width)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// ShortLinkURL returns the URL of a short link served under baseURL, e.g.
+// `https://sho.rt/abc123` for `https://sho.rt`.
+func ShortLinkURL(baseURL, shortLinkID string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", &ErrInvalidOptions{msg: fmt.Sprintf("the base URL %q must be an absolute http or https URL", baseURL)}
+	}
+	return strings.TrimSuffix(u.String(), "/") + "/" + url.PathEscape(shortLinkID), nil
+}
+
+type ErrInvalidOptions struct {
+	msg string
+}
+
+func (e *ErrInvalidOptions) Error() string {
+	return fmt.Sprintf("ErrInvalidOptions: %s", e.msg)
+}
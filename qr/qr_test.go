@@ -0,0 +1,135 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptionsFromQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    *Options
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: DefaultOptions()},
+		{name: "SVG in another case", query: "format=SVG", want: &Options{Format: FormatSVG, Size: DefaultSize, Level: DefaultLevel, Margin: DefaultMargin}},
+		{name: "unknown format", query: "format=gif", wantErr: true},
+		{name: "min size", query: fmt.Sprintf("size=%d", MinSize), want: &Options{Format: DefaultFormat, Size: MinSize, Level: DefaultLevel, Margin: DefaultMargin}},
+		{name: "one short of the min size", query: fmt.Sprintf("size=%d", MinSize-1), wantErr: true},
+		{name: "max size", query: fmt.Sprintf("size=%d", MaxSize), want: &Options{Format: DefaultFormat, Size: MaxSize, Level: DefaultLevel, Margin: DefaultMargin}},
+		{name: "one past the max size", query: fmt.Sprintf("size=%d", MaxSize+1), wantErr: true},
+		{name: "size not an integer", query: "size=big", wantErr: true},
+		{name: "level in another case", query: "level=h", want: &Options{Format: DefaultFormat, Size: DefaultSize, Level: "H", Margin: DefaultMargin}},
+		{name: "unknown level", query: "level=X", wantErr: true},
+		{name: "no margin", query: "margin=0", want: &Options{Format: DefaultFormat, Size: DefaultSize, Level: DefaultLevel, Margin: 0}},
+		{name: "negative margin", query: "margin=-1", wantErr: true},
+		{name: "max margin", query: fmt.Sprintf("margin=%d", MaxMargin), want: &Options{Format: DefaultFormat, Size: DefaultSize, Level: DefaultLevel, Margin: MaxMargin}},
+		{name: "one past the max margin", query: fmt.Sprintf("margin=%d", MaxMargin+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery: %v", err)
+			}
+
+			got, err := OptionsFromQuery(query)
+			if tt.wantErr {
+				var optsErr *ErrInvalidOptions
+				if !errors.As(err, &optsErr) {
+					t.Fatalf("got %+v, %v, want an ErrInvalidOptions", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OptionsFromQuery: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	const content = "https://sho.rt/abc123"
+
+	tests := []struct {
+		name            string
+		opts            *Options
+		wantContentType string
+		wantErr         bool
+	}{
+		{name: "PNG", opts: DefaultOptions(), wantContentType: "image/png"},
+		{name: "min size PNG without margin", opts: &Options{Format: FormatPNG, Size: MinSize, Level: "L", Margin: 0}, wantContentType: "image/png"},
+		{name: "max size PNG", opts: &Options{Format: FormatPNG, Size: MaxSize, Level: "H", Margin: MaxMargin}, wantContentType: "image/png"},
+		{name: "PNG too small for the margin", opts: &Options{Format: FormatPNG, Size: MinSize, Level: DefaultLevel, Margin: MaxMargin}, wantErr: true},
+		{name: "SVG", opts: &Options{Format: FormatSVG, Size: DefaultSize, Level: DefaultLevel, Margin: DefaultMargin}, wantContentType: "image/svg+xml"},
+		{name: "min size SVG with the max margin", opts: &Options{Format: FormatSVG, Size: MinSize, Level: DefaultLevel, Margin: MaxMargin}, wantContentType: "image/svg+xml"},
+		{name: "invalid options", opts: &Options{Format: FormatPNG, Size: MaxSize + 1, Level: DefaultLevel}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Encode(content, tt.opts)
+			if tt.wantErr {
+				var optsErr *ErrInvalidOptions
+				if !errors.As(err, &optsErr) {
+					t.Fatalf("got error %v, want an ErrInvalidOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if got := tt.opts.ContentType(); got != tt.wantContentType {
+				t.Errorf("got content type %q, want %q", got, tt.wantContentType)
+			}
+
+			if tt.opts.Format == FormatSVG {
+				size := fmt.Sprintf(`width="%d" height="%d"`, tt.opts.Size, tt.opts.Size)
+				if !bytes.HasPrefix(img, []byte("<svg ")) || !strings.Contains(string(img), size) || !bytes.HasSuffix(img, []byte("</svg>")) {
+					t.Errorf("got %.100s..., want an SVG image with %s", img, size)
+				}
+				return
+			}
+
+			decoded, err := png.Decode(bytes.NewReader(img))
+			if err != nil {
+				t.Fatalf("png.Decode: %v", err)
+			}
+			if bounds := decoded.Bounds(); bounds.Dx() != tt.opts.Size || bounds.Dy() != tt.opts.Size {
+				t.Errorf("got a %dx%d image, want %dx%d", bounds.Dx(), bounds.Dy(), tt.opts.Size, tt.opts.Size)
+			}
+		})
+	}
+}
+
+func TestShortLinkURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "https://sho.rt", id: "abc", want: "https://sho.rt/abc"},
+		{baseURL: "https://sho.rt/", id: "abc", want: "https://sho.rt/abc"},
+		{baseURL: "https://example.com/s", id: "a b", want: "https://example.com/s/a%20b"},
+		{baseURL: "sho.rt", id: "abc", wantErr: true},
+		{baseURL: "ftp://sho.rt", id: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.baseURL+" "+tt.id, func(t *testing.T) {
+			got, err := ShortLinkURL(tt.baseURL, tt.id)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ShortLinkURL(%q, %q): got %q, %v, want %q (error: %t)", tt.baseURL, tt.id, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
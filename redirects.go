@@ -0,0 +1,97 @@
+package slink
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// checkRedirectChain follows linkURL through the ShortLinks it points to on
+// the own hosts (see WithOwnHosts), failing with ErrRedirectLoop when it gets
+// back to a ShortLink already in the chain, including shortLinkID (the
+// ShortLink being created or updated, if its ID is known), or when it goes
+// through more than maxRedirectDepth of them.
+//
+// The chain ends at a URL on another host, or at a ShortLink that doesn't
+// exist or has expired, as the public server doesn't redirect any further. It
+// branches at ShortLinks that aren't active yet and have a ComingSoonURL, which
+// are followed to both URLs, as they redirect to one and then the other.
+func (s *Slink) checkRedirectChain(ctx context.Context, shortLinkID, linkURL string) error {
+	if len(s.ownHosts) == 0 {
+		return nil
+	}
+
+	var chain []string
+	if shortLinkID != "" {
+		chain = append(chain, shortLinkID)
+	}
+	return s.followRedirectChain(ctx, chain, 1, linkURL)
+}
+
+func (s *Slink) followRedirectChain(ctx context.Context, chain []string, depth int, linkURL string) error {
+	id, ok := s.ownShortLinkID(linkURL)
+	if !ok {
+		return nil
+	}
+
+	for _, seen := range chain {
+		if seen == id {
+			return &ErrRedirectLoop{Chain: append(chain, id)}
+		}
+	}
+	// copied, so that the branches of the chain don't share it
+	chain = append(chain[:len(chain):len(chain)], id)
+
+	if depth > s.maxRedirectDepth {
+		return &ErrRedirectLoop{Chain: chain, TooDeep: true, maxDepth: s.maxRedirectDepth}
+	}
+
+	shortLink, err := s.GetShortLinkByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if shortLink == nil || shortLink.Expired() {
+		return nil
+	}
+	if shortLink.NotYetActive() && shortLink.ComingSoonURL != "" {
+		err = s.followRedirectChain(ctx, chain, depth+1, shortLink.ComingSoonURL)
+		if err != nil {
+			return err
+		}
+	}
+	return s.followRedirectChain(ctx, chain, depth+1, shortLink.LinkURL)
+}
+
+// checkCreateRedirectChains checks the redirect chains of the LinkURL and the
+// ComingSoonURL of a ShortLink about to be created, see checkRedirectChain.
+func (s *Slink) checkCreateRedirectChains(ctx context.Context, input *CreateInput) error {
+	err := s.checkRedirectChain(ctx, input.ID, input.LinkURL)
+	if err != nil || input.ComingSoonURL == "" {
+		return err
+	}
+	return s.checkRedirectChain(ctx, input.ID, input.ComingSoonURL)
+}
+
+// ownShortLinkID returns the ID of the ShortLink that linkURL points to, when
+// it's a short link URL on one of the own hosts.
+func (s *Slink) ownShortLinkID(linkURL string) (string, bool) {
+	u, err := url.Parse(linkURL)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	if !s.ownHosts[normaliseHost(u.Host)] && !s.ownHosts[normaliseHost(u.Hostname())] {
+		return "", false
+	}
+
+	// the public server only redirects `/:id`
+	id := strings.TrimPrefix(u.Path, "/")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+func normaliseHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,315 @@
+package slink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ronny/slink/storage"
+)
+
+const testOwnHost = "sho.rt"
+
+func newTestSlink(t *testing.T, options ...func(*Slink)) *Slink {
+	t.Helper()
+
+	options = append([]func(*Slink){WithStorage(storage.NewMemoryStorage())}, options...)
+	s, err := NewSlink(context.Background(), options...)
+	if err != nil {
+		t.Fatalf("NewSlink: %v", err)
+	}
+	return s
+}
+
+func ownURL(id string) string {
+	return "https://" + testOwnHost + "/" + id
+}
+
+// createChain creates the ShortLinks `<prefix>1` to `<prefix><n>`, each
+// redirecting to the next one, and the last one to another host.
+func createChain(t *testing.T, s *Slink, prefix string, n int) {
+	t.Helper()
+
+	for i := n; i >= 1; i-- {
+		linkURL := "https://example.com/" + prefix
+		if i < n {
+			linkURL = ownURL(fmt.Sprintf("%s%d", prefix, i+1))
+		}
+		_, err := s.CreateShortLink(context.Background(), &CreateInput{ID: fmt.Sprintf("%s%d", prefix, i), LinkURL: linkURL})
+		if err != nil {
+			t.Fatalf("CreateShortLink(%s%d): %v", prefix, i, err)
+		}
+	}
+}
+
+func TestCreateShortLinkRedirectChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []func(*Slink)
+		chains   map[string]int
+		input    *CreateInput
+		wantLoop *ErrRedirectLoop
+	}{
+		{
+			name:   "another host",
+			chains: map[string]int{"abc": 1},
+			input:  &CreateInput{LinkURL: "https://example.com/abc1"},
+		},
+		{
+			name:  "own host, not a short link",
+			input: &CreateInput{LinkURL: ownURL("a/b")},
+		},
+		{
+			name:  "short link that doesn't exist",
+			input: &CreateInput{LinkURL: ownURL("nope")},
+		},
+		{
+			name:   "own host with another case and port",
+			chains: map[string]int{"abc": DefaultMaxRedirectDepth + 1},
+			input:  &CreateInput{LinkURL: "https://SHO.RT:8443/abc1"},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"abc1", "abc2", "abc3", "abc4"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:   "exactly the default max depth",
+			chains: map[string]int{"abc": DefaultMaxRedirectDepth},
+			input:  &CreateInput{LinkURL: ownURL("abc1")},
+		},
+		{
+			name:   "one past the default max depth",
+			chains: map[string]int{"abc": DefaultMaxRedirectDepth + 1},
+			input:  &CreateInput{LinkURL: ownURL("abc1")},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"abc1", "abc2", "abc3", "abc4"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:   "one past the default max depth, with the ID",
+			chains: map[string]int{"abc": DefaultMaxRedirectDepth + 1},
+			input:  &CreateInput{ID: "new", LinkURL: ownURL("abc1")},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"new", "abc1", "abc2", "abc3", "abc4"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:    "exactly a custom max depth",
+			options: []func(*Slink){WithMaxRedirectDepth(1)},
+			chains:  map[string]int{"abc": 1},
+			input:   &CreateInput{LinkURL: ownURL("abc1")},
+		},
+		{
+			name:    "one past a custom max depth",
+			options: []func(*Slink){WithMaxRedirectDepth(1)},
+			chains:  map[string]int{"abc": 2},
+			input:   &CreateInput{LinkURL: ownURL("abc1")},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"abc1", "abc2"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:    "max depth 0",
+			options: []func(*Slink){WithMaxRedirectDepth(0)},
+			input:   &CreateInput{LinkURL: ownURL("nope")},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"nope"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:  "to itself",
+			input: &CreateInput{ID: "self", LinkURL: ownURL("self")},
+			wantLoop: &ErrRedirectLoop{
+				Chain: []string{"self", "self"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t, append([]func(*Slink){WithOwnHosts(testOwnHost)}, tt.options...)...)
+			for prefix, n := range tt.chains {
+				createChain(t, s, prefix, n)
+			}
+
+			_, err := s.CreateShortLink(context.Background(), tt.input)
+			checkRedirectLoop(t, err, tt.wantLoop)
+		})
+	}
+}
+
+func TestCreateShortLinkRedirectLoop(t *testing.T) {
+	s := newTestSlink(t, WithOwnHosts(testOwnHost))
+	ctx := context.Background()
+
+	// b -> a, which doesn't exist yet
+	_, err := s.CreateShortLink(ctx, &CreateInput{ID: "bbb", LinkURL: ownURL("aaa")})
+	if err != nil {
+		t.Fatalf("CreateShortLink(bbb): %v", err)
+	}
+
+	_, err = s.CreateShortLink(ctx, &CreateInput{ID: "aaa", LinkURL: ownURL("bbb")})
+	checkRedirectLoop(t, err, &ErrRedirectLoop{Chain: []string{"aaa", "bbb", "aaa"}})
+}
+
+func TestUpdateShortLinkRedirectLoop(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		linkURL  string
+		expired  string
+		wantLoop *ErrRedirectLoop
+	}{
+		{name: "A to B to A", id: "aaa", linkURL: ownURL("bbb"), wantLoop: &ErrRedirectLoop{Chain: []string{"aaa", "bbb", "aaa"}}},
+		{name: "A to B to C to A", id: "aaa", linkURL: ownURL("ccc"), wantLoop: &ErrRedirectLoop{Chain: []string{"aaa", "ccc", "bbb", "aaa"}}},
+		{name: "to itself", id: "aaa", linkURL: ownURL("aaa"), wantLoop: &ErrRedirectLoop{Chain: []string{"aaa", "aaa"}}},
+		{name: "through an expired short link", id: "aaa", linkURL: ownURL("bbb"), expired: "bbb"},
+		{name: "B to itself", id: "bbb", linkURL: ownURL("bbb"), wantLoop: &ErrRedirectLoop{Chain: []string{"bbb", "bbb"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t, WithOwnHosts(testOwnHost))
+			ctx := context.Background()
+
+			// c -> b -> a -> another host
+			for _, input := range []*CreateInput{
+				{ID: "aaa", LinkURL: "https://example.com/"},
+				{ID: "bbb", LinkURL: ownURL("aaa")},
+				{ID: "ccc", LinkURL: ownURL("bbb")},
+			} {
+				_, err := s.CreateShortLink(ctx, input)
+				if err != nil {
+					t.Fatalf("CreateShortLink(%s): %v", input.ID, err)
+				}
+			}
+			if tt.expired != "" {
+				_, err := s.ExpireShortLink(ctx, tt.expired, &ExpireInput{})
+				if err != nil {
+					t.Fatalf("ExpireShortLink(%s): %v", tt.expired, err)
+				}
+			}
+
+			_, err := s.UpdateShortLink(ctx, tt.id, &UpdateInput{LinkURL: tt.linkURL})
+			checkRedirectLoop(t, err, tt.wantLoop)
+		})
+	}
+}
+
+func TestComingSoonRedirectChain(t *testing.T) {
+	const future, past = "2099-01-01T00:00:00Z", "2020-01-01T00:00:00Z"
+
+	tests := []struct {
+		name     string
+		existing []*CreateInput
+		chains   map[string]int
+		input    *CreateInput
+		wantLoop *ErrRedirectLoop
+	}{
+		{
+			name:  "coming soon URL on another host",
+			input: &CreateInput{ID: "new", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: "https://example.com/soon"},
+		},
+		{
+			name:     "coming soon URL to itself",
+			input:    &CreateInput{ID: "new", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("new")},
+			wantLoop: &ErrRedirectLoop{Chain: []string{"new", "new"}},
+		},
+		{
+			name:   "coming soon URL one past the default max depth",
+			chains: map[string]int{"abc": DefaultMaxRedirectDepth + 1},
+			input:  &CreateInput{ID: "new", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("abc1")},
+			wantLoop: &ErrRedirectLoop{
+				Chain:   []string{"new", "abc1", "abc2", "abc3", "abc4"},
+				TooDeep: true,
+			},
+		},
+		{
+			name:     "through the coming soon URL of a short link not active yet",
+			existing: []*CreateInput{{ID: "soon", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("new")}},
+			input:    &CreateInput{ID: "new", LinkURL: ownURL("soon")},
+			wantLoop: &ErrRedirectLoop{Chain: []string{"new", "soon", "new"}},
+		},
+		{
+			name:     "through the link URL of a short link not active yet",
+			existing: []*CreateInput{{ID: "soon", LinkURL: ownURL("new"), ActivatesAt: future, ComingSoonURL: "https://example.com/soon"}},
+			input:    &CreateInput{ID: "new", LinkURL: ownURL("soon")},
+			wantLoop: &ErrRedirectLoop{Chain: []string{"new", "soon", "new"}},
+		},
+		{
+			name:     "through the coming soon URL of an active short link",
+			existing: []*CreateInput{{ID: "active", LinkURL: "https://example.com/", ActivatesAt: past, ComingSoonURL: ownURL("new")}},
+			input:    &CreateInput{ID: "new", LinkURL: ownURL("active")},
+		},
+		{
+			name: "through the coming soon URLs of two short links not active yet",
+			existing: []*CreateInput{
+				{ID: "soon2", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("new")},
+				{ID: "soon1", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("soon2")},
+			},
+			input:    &CreateInput{ID: "new", LinkURL: "https://example.com/", ActivatesAt: future, ComingSoonURL: ownURL("soon1")},
+			wantLoop: &ErrRedirectLoop{Chain: []string{"new", "soon1", "soon2", "new"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, batch := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s, batch %t", tt.name, batch), func(t *testing.T) {
+				options := []func(*Slink){WithOwnHosts(testOwnHost)}
+				if batch {
+					options = append(options, WithStorage(&batchMemoryStorage{storage.NewMemoryStorage()}))
+				}
+				s := newTestSlink(t, options...)
+				ctx := context.Background()
+				for prefix, n := range tt.chains {
+					createChain(t, s, prefix, n)
+				}
+				for _, input := range tt.existing {
+					_, err := s.CreateShortLink(ctx, input)
+					if err != nil {
+						t.Fatalf("CreateShortLink(%s): %v", input.ID, err)
+					}
+				}
+
+				var err error
+				if batch {
+					err = s.CreateShortLinks(ctx, []*CreateInput{tt.input})[0].Err
+				} else {
+					_, err = s.CreateShortLink(ctx, tt.input)
+				}
+				checkRedirectLoop(t, err, tt.wantLoop)
+			})
+		}
+	}
+}
+
+func TestRedirectChainWithoutOwnHosts(t *testing.T) {
+	s := newTestSlink(t)
+
+	_, err := s.CreateShortLink(context.Background(), &CreateInput{ID: "self", LinkURL: ownURL("self")})
+	if err != nil {
+		t.Fatalf("CreateShortLink: %v", err)
+	}
+}
+
+func checkRedirectLoop(t *testing.T, err error, want *ErrRedirectLoop) {
+	t.Helper()
+
+	if want == nil {
+		if err != nil {
+			t.Fatalf("got error %v, want none", err)
+		}
+		return
+	}
+
+	var loopErr *ErrRedirectLoop
+	if !errors.As(err, &loopErr) {
+		t.Fatalf("got error %v, want an ErrRedirectLoop", err)
+	}
+	if !reflect.DeepEqual(loopErr.Chain, want.Chain) || loopErr.TooDeep != want.TooDeep {
+		t.Errorf("got chain %v (too deep: %t), want %v (too deep: %t)", loopErr.Chain, loopErr.TooDeep, want.Chain, want.TooDeep)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/ronny/slink/debug"
 	"github.com/ronny/slink/ids"
 	"github.com/ronny/slink/models"
 	"github.com/ronny/slink/storage"
+	"github.com/ronny/slink/urls"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,21 +21,86 @@ type Slink struct {
 	idgen             ids.Generator
 	storage           storage.Storage
 	lruCache          *lru.Cache
+	cacheTTL          time.Duration
 	maxCreateAttempts int
+	aliasChars        string
+	aliasMinLength    int
+	aliasMaxLength    int
+	aliasDenylist     ids.Denylist
+	eventListeners    []EventListener
+	ownHosts          map[string]bool
+	maxRedirectDepth  int
+	urlNormaliser     urls.Normaliser
+	urlPolicy         *URLPolicy
+	blocklist         Blocklist
+	createLimiter     CreateLimiter
 }
 
 type CreateInput struct {
 	LinkURL   string `json:"linkUrl"`
 	ExpiresAt string `json:"expiresAt,omitempty"`
+	// ActivatesAt is an optional time (RFC3339) before which the ShortLink
+	// redirects to ComingSoonURL (or the fallback URL) instead of LinkURL.
+	ActivatesAt   string `json:"activatesAt,omitempty"`
+	ComingSoonURL string `json:"comingSoonUrl,omitempty"`
+	// ID is an optional custom alias (e.g. `summer-sale`) to use as the
+	// ShortLink ID instead of a generated one.
+	ID       string            `json:"id,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// CreatedBy identifies who requested the creation (e.g. an auth key ID),
+	// it's recorded in the ShortLink.
+	CreatedBy string `json:"-"`
 }
 
-// GetOrCreateShortLink returns an existing ShortLink if both LinkURL and ExpiresAt match the input,
+type UpdateInput struct {
+	LinkURL string `json:"linkUrl"`
+	// ChangedBy identifies who requested the change (e.g. an auth key ID), it's
+	// recorded in the ShortLink history.
+	ChangedBy string `json:"-"`
+}
+
+type ExpireInput struct {
+	Reason string `json:"reason"`
+	// ExpiredBy identifies who requested the expiry (e.g. an auth key ID), it's
+	// recorded in the ShortLink history.
+	ExpiredBy string `json:"-"`
+}
+
+// GetOrCreateShortLink returns an existing ShortLink if its LinkURL, ExpiresAt, ActivatesAt and ComingSoonURL match the input,
 // or creates a new ShortLink if no matching ShortLink can be found.
 //
-// No normalisation is done on LinkURL. `https://example.com?a=1&b=2` is considered different to `https://example.com?b=2&a1`.
-// Only strict exact string match is used for lookups (whatever's supported by the storage backend).
+// LinkURLs are compared normalised, see WithURLNormaliser, so e.g.
+// `https://example.com?a=1&b=2` matches a ShortLink to `https://Example.com/?b=2&a=1`,
+// which is returned with its own LinkURL.
+//
+// When input.ID is specified, only the ShortLink with that ID is considered a
+// match.
+//
+// The input is validated as in CreateShortLink before looking for a match, so
+// e.g. a LinkURL that's now blocked fails even when a ShortLink to it exists.
 func (s *Slink) GetOrCreateShortLink(ctx context.Context, input *CreateInput) (*models.ShortLink, error) {
-	// TODO: pagination
+	err := s.validateCreateInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	normalisedLinkURL, err := s.normaliseLinkURL(input.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.ID != "" {
+		shortLink, err := s.GetShortLinkByID(ctx, input.ID)
+		if err != nil {
+			return nil, err
+		}
+		if shortLink != nil && shortLink.IndexedLinkURL() == normalisedLinkURL && sameSchedule(shortLink, input) {
+			return shortLink, nil
+		}
+		return s.CreateShortLink(ctx, input)
+	}
+
 	shortLinks, err := s.GetShortLinksByURL(ctx, input.LinkURL)
 	if err != nil {
 		return nil, err
@@ -39,7 +108,7 @@ func (s *Slink) GetOrCreateShortLink(ctx context.Context, input *CreateInput) (*
 
 	var matchingShortLink *models.ShortLink
 	for _, shortLink := range shortLinks {
-		if shortLink.LinkURL == input.LinkURL && shortLink.ExpiresAt == input.ExpiresAt {
+		if sameSchedule(shortLink, input) {
 			matchingShortLink = shortLink
 			break
 		}
@@ -53,13 +122,46 @@ func (s *Slink) GetOrCreateShortLink(ctx context.Context, input *CreateInput) (*
 }
 
 // CreateShortLink unconditionally creates a new ShortLink, even when one with the exact same LinkURL already exists
+//
+// When input.ID is specified, it's validated as a custom alias and used as is,
+// failing with `storage.ErrShortLinkAlreadyExists` if it's already taken.
 func (s *Slink) CreateShortLink(ctx context.Context, input *CreateInput) (*models.ShortLink, error) {
-	if input == nil {
-		return nil, errors.New("input is nil (BUG?)")
+	err := s.validateCreateInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkCreateRedirectChains(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	normalisedLinkURL, err := s.normaliseLinkURL(input.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.createLimiter != nil {
+		err = s.createLimiter.AllowCreate(ctx, 1)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if input.LinkURL == "" {
-		return nil, &ErrInvalidLinkURL{msg: "link URL must not be empty"}
+	return s.createShortLink(ctx, input, normalisedLinkURL)
+}
+
+// createShortLink creates a ShortLink from a valid input, which the
+// CreateLimiter has already allowed.
+func (s *Slink) createShortLink(ctx context.Context, input *CreateInput, normalisedLinkURL string) (*models.ShortLink, error) {
+	if input.ID != "" {
+		shortLink := newShortLink(input, input.ID, normalisedLinkURL)
+		err := s.storage.Create(ctx, shortLink)
+		if err != nil {
+			return nil, fmt.Errorf("storage.Create: %w", err)
+		}
+		s.emitEvent(ctx, EventShortLinkCreated, shortLink, nil)
+		return shortLink, nil
 	}
 
 	for attempt := 1; attempt <= s.maxCreateAttempts; attempt++ {
@@ -68,12 +170,7 @@ func (s *Slink) CreateShortLink(ctx context.Context, input *CreateInput) (*model
 			return nil, err
 		}
 
-		shortLink := &models.ShortLink{
-			ID:        id,
-			LinkURL:   input.LinkURL,
-			CreatedAt: time.Now().UTC().Format(time.RFC3339),
-			ExpiresAt: input.ExpiresAt,
-		}
+		shortLink := newShortLink(input, id, normalisedLinkURL)
 		err = s.storage.Create(ctx, shortLink)
 		if err != nil {
 			var ex *storage.ErrShortLinkAlreadyExists
@@ -83,6 +180,7 @@ func (s *Slink) CreateShortLink(ctx context.Context, input *CreateInput) (*model
 			}
 			return nil, fmt.Errorf("storage.Create: %w", err)
 		} else {
+			s.emitEvent(ctx, EventShortLinkCreated, shortLink, nil)
 			return shortLink, nil
 		}
 	}
@@ -90,6 +188,412 @@ func (s *Slink) CreateShortLink(ctx context.Context, input *CreateInput) (*model
 	return nil, &ErrCreateAttemptsExhausted{attempts: s.maxCreateAttempts}
 }
 
+type CreateResult struct {
+	ShortLink *models.ShortLink
+	Err       error
+}
+
+// CreateShortLinks creates a new ShortLink for each of the inputs, like
+// CreateShortLink, returning a result for each input in the same order.
+//
+// When the storage backend implements `storage.BatchCreator`, the ShortLinks
+// are created in batches, only falling back to creating them one by one (with
+// a newly generated ID) when a generated ID collides.
+func (s *Slink) CreateShortLinks(ctx context.Context, inputs []*CreateInput) []*CreateResult {
+	results := make([]*CreateResult, len(inputs))
+
+	batchCreator, ok := s.storage.(storage.BatchCreator)
+	if !ok {
+		for i, input := range inputs {
+			shortLink, err := s.CreateShortLink(ctx, input)
+			results[i] = &CreateResult{ShortLink: shortLink, Err: err}
+		}
+		return results
+	}
+
+	batch := make([]*models.ShortLink, 0, len(inputs))
+	batchIndexes := make([]int, 0, len(inputs))
+	for i, input := range inputs {
+		err := s.validateCreateInput(input)
+		if err == nil {
+			err = s.checkCreateRedirectChains(ctx, input)
+		}
+		var normalisedLinkURL string
+		if err == nil {
+			normalisedLinkURL, err = s.normaliseLinkURL(input.LinkURL)
+		}
+		if err != nil {
+			results[i] = &CreateResult{Err: err}
+			continue
+		}
+
+		id := input.ID
+		if id == "" {
+			id, err = s.idgen.GenerateID()
+			if err != nil {
+				results[i] = &CreateResult{Err: err}
+				continue
+			}
+		}
+
+		batch = append(batch, newShortLink(input, id, normalisedLinkURL))
+		batchIndexes = append(batchIndexes, i)
+	}
+
+	batch, batchIndexes = s.allowCreateBatch(ctx, batch, batchIndexes, results)
+	if len(batch) == 0 {
+		return results
+	}
+
+	errs := batchCreator.CreateBatch(ctx, batch)
+	for j, err := range errs {
+		i := batchIndexes[j]
+		if err == nil {
+			results[i] = &CreateResult{ShortLink: batch[j]}
+			s.emitEvent(ctx, EventShortLinkCreated, batch[j], nil)
+			continue
+		}
+
+		var ex *storage.ErrShortLinkAlreadyExists
+		if errors.As(err, &ex) && inputs[i].ID == "" {
+			log.Info().Err(ex).Str("id", batch[j].ID).Msg("short link ID collision in batch, retrying individually...")
+			shortLink, err := s.createShortLink(ctx, inputs[i], batch[j].NormalisedLinkURL)
+			results[i] = &CreateResult{ShortLink: shortLink, Err: err}
+			continue
+		}
+
+		results[i] = &CreateResult{Err: fmt.Errorf("storage.CreateBatch: %w", err)}
+	}
+
+	return results
+}
+
+// allowCreateBatch asks the CreateLimiter to allow the whole batch at once, or
+// each ShortLink of the batch in turn when it doesn't allow them all, until it
+// doesn't allow one, failing the rest of the batch with the same error. The
+// ShortLinks allowed are returned with their indexes, the results of the others
+// are set.
+func (s *Slink) allowCreateBatch(ctx context.Context, batch []*models.ShortLink, batchIndexes []int, results []*CreateResult) ([]*models.ShortLink, []int) {
+	if s.createLimiter == nil || len(batch) == 0 {
+		return batch, batchIndexes
+	}
+
+	err := s.createLimiter.AllowCreate(ctx, len(batch))
+	if err == nil {
+		return batch, batchIndexes
+	}
+
+	allowed := make([]*models.ShortLink, 0, len(batch))
+	allowedIndexes := make([]int, 0, len(batch))
+	if len(batch) > 1 {
+		for j, shortLink := range batch {
+			err = s.createLimiter.AllowCreate(ctx, 1)
+			if err != nil {
+				break
+			}
+			allowed = append(allowed, shortLink)
+			allowedIndexes = append(allowedIndexes, batchIndexes[j])
+		}
+	}
+
+	for _, i := range batchIndexes[len(allowed):] {
+		results[i] = &CreateResult{Err: err}
+	}
+	return allowed, allowedIndexes
+}
+
+func (s *Slink) validateCreateInput(input *CreateInput) error {
+	if input == nil {
+		return errors.New("input is nil (BUG?)")
+	}
+
+	err := s.validateLinkURL(input.LinkURL, "create")
+	if err != nil {
+		return err
+	}
+
+	if input.ActivatesAt != "" {
+		_, err = time.Parse(time.RFC3339, input.ActivatesAt)
+		if err != nil {
+			return &ErrInvalidActivatesAt{msg: fmt.Sprintf("activatesAt must be in RFC3339 format, e.g. %s", time.RFC3339)}
+		}
+	}
+	if input.ComingSoonURL != "" {
+		if input.ActivatesAt == "" {
+			return &ErrInvalidActivatesAt{msg: "comingSoonUrl requires activatesAt"}
+		}
+		err = s.validateLinkURL(input.ComingSoonURL, "create")
+		if err != nil {
+			return err
+		}
+	}
+
+	if input.ID != "" {
+		err = s.validateAlias(input.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return validateTagsAndMetadata(input.Tags, input.Metadata)
+}
+
+// validateLinkURL checks linkURL against the URLPolicy and the Blocklist, with
+// action (create or update) as a metric label of blocked LinkURLs.
+func (s *Slink) validateLinkURL(linkURL, action string) error {
+	err := s.urlPolicy.Validate(linkURL)
+	if err != nil {
+		return err
+	}
+
+	if s.blocklist != nil {
+		if entry, blocked := s.blocklist.Blocked(linkURL); blocked {
+			debug.BlockedLinkURLs().WithLabelValues(action).Inc()
+			return &ErrInvalidLinkURL{Reason: LinkURLReasonBlocked, msg: fmt.Sprintf("link URL is blocked, it matches %s", entry)}
+		}
+	}
+
+	return nil
+}
+
+func validateTagsAndMetadata(tags []string, metadata map[string]string) error {
+	if len(tags) > MaxTags {
+		return &ErrInvalidTag{msg: fmt.Sprintf("a short link can have at most %d tags", MaxTags)}
+	}
+
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag == "" || len(tag) > MaxTagLength {
+			return &ErrInvalidTag{msg: fmt.Sprintf("tags must be between 1 and %d characters long", MaxTagLength)}
+		}
+		if strings.ContainsAny(tag, ",\r\n") {
+			return &ErrInvalidTag{msg: "tags must not contain commas or line breaks"}
+		}
+		if seen[tag] {
+			return &ErrInvalidTag{msg: fmt.Sprintf("duplicate tag %q", tag)}
+		}
+		seen[tag] = true
+	}
+
+	if len(metadata) > MaxMetadataEntries {
+		return &ErrInvalidMetadata{msg: fmt.Sprintf("a short link can have at most %d metadata entries", MaxMetadataEntries)}
+	}
+
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return &ErrInvalidMetadata{msg: fmt.Sprintf("metadata keys must be between 1 and %d characters long", MaxMetadataKeyLength)}
+		}
+		if len(value) > MaxMetadataValueLength {
+			return &ErrInvalidMetadata{msg: fmt.Sprintf("metadata values must be at most %d characters long", MaxMetadataValueLength)}
+		}
+	}
+
+	return nil
+}
+
+func newShortLink(input *CreateInput, id, normalisedLinkURL string) *models.ShortLink {
+	return &models.ShortLink{
+		ID:                id,
+		LinkURL:           input.LinkURL,
+		NormalisedLinkURL: normalisedLinkURL,
+		CreatedAt:         time.Now().UTC().Format(time.RFC3339),
+		CreatedBy:         input.CreatedBy,
+		ExpiresAt:         input.ExpiresAt,
+		ActivatesAt:       input.ActivatesAt,
+		ComingSoonURL:     input.ComingSoonURL,
+		Tags:              input.Tags,
+		Metadata:          input.Metadata,
+	}
+}
+
+// sameSchedule returns true if shortLink expires and activates as requested by
+// input, with the same ComingSoonURL until then.
+func sameSchedule(shortLink *models.ShortLink, input *CreateInput) bool {
+	return shortLink.ExpiresAt == input.ExpiresAt &&
+		shortLink.ActivatesAt == input.ActivatesAt &&
+		shortLink.ComingSoonURL == input.ComingSoonURL
+}
+
+// normaliseLinkURL returns the normalised form of linkURL, which ShortLinks are
+// stored and looked up by.
+func (s *Slink) normaliseLinkURL(linkURL string) (string, error) {
+	normalised, err := s.urlNormaliser.Normalise(linkURL)
+	if err != nil {
+		return "", &ErrInvalidLinkURL{Reason: LinkURLReasonMalformed, msg: fmt.Sprintf("link URL can't be normalised: %s", err)}
+	}
+	return normalised, nil
+}
+
+func (s *Slink) validateAlias(alias string) error {
+	if len(alias) < s.aliasMinLength || len(alias) > s.aliasMaxLength {
+		return &ErrInvalidShortLinkID{msg: fmt.Sprintf("custom alias must be between %d and %d characters long", s.aliasMinLength, s.aliasMaxLength)}
+	}
+
+	for _, c := range alias {
+		if !strings.ContainsRune(s.aliasChars, c) {
+			return &ErrInvalidShortLinkID{msg: fmt.Sprintf("custom alias must only contain these characters: %s", s.aliasChars)}
+		}
+	}
+
+	if !ids.IsAllowed(alias, s.aliasDenylist) {
+		return &ErrInvalidShortLinkID{msg: "custom alias is not allowed"}
+	}
+
+	return nil
+}
+
+// UpdateShortLink replaces the LinkURL of an existing ShortLink, recording the
+// previous LinkURL in the ShortLink history.
+//
+// Other processes (e.g. `slink-public-server`) may keep redirecting to the
+// previous LinkURL until their cached copy of the ShortLink expires, see
+// `WithCacheTTL`.
+func (s *Slink) UpdateShortLink(ctx context.Context, shortLinkID string, input *UpdateInput) (*models.ShortLink, error) {
+	if input == nil {
+		return nil, errors.New("input is nil (BUG?)")
+	}
+
+	err := s.validateLinkURL(input.LinkURL, "update")
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.GetShortLinkByID(ctx, shortLinkID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, &ErrShortLinkNotFound{shortLinkID: shortLinkID}
+	}
+
+	if current.LinkURL == input.LinkURL {
+		return current, nil
+	}
+
+	err = s.checkRedirectChain(ctx, shortLinkID, input.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	normalisedLinkURL, err := s.normaliseLinkURL(input.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *current
+	updated.LinkURL = input.LinkURL
+	updated.NormalisedLinkURL = normalisedLinkURL
+
+	return s.modifyShortLink(ctx, EventShortLinkUpdated, current, &updated, input.ChangedBy, "")
+}
+
+// ExpireShortLink expires an existing ShortLink immediately by setting its
+// ExpiresAt to now, recording the reason in the ShortLink history. Expiring an
+// already expired ShortLink is a no-op.
+//
+// Like with UpdateShortLink, other processes may keep redirecting until their
+// cached copy of the ShortLink expires.
+func (s *Slink) ExpireShortLink(ctx context.Context, shortLinkID string, input *ExpireInput) (*models.ShortLink, error) {
+	if input == nil {
+		return nil, errors.New("input is nil (BUG?)")
+	}
+
+	current, err := s.GetShortLinkByID(ctx, shortLinkID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, &ErrShortLinkNotFound{shortLinkID: shortLinkID}
+	}
+
+	if current.Expired() {
+		return current, nil
+	}
+
+	return s.expireShortLink(ctx, current, input)
+}
+
+// ExpireShortLinksByURL expires all (not yet expired) ShortLinks with the given
+// LinkURL (compared normalised), see ExpireShortLink. It returns the ShortLinks that were expired.
+func (s *Slink) ExpireShortLinksByURL(ctx context.Context, linkURL string, input *ExpireInput) ([]*models.ShortLink, error) {
+	if input == nil {
+		return nil, errors.New("input is nil (BUG?)")
+	}
+
+	shortLinks, err := s.GetShortLinksByURL(ctx, linkURL)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]*models.ShortLink, 0)
+	for _, shortLink := range shortLinks {
+		if shortLink.Expired() {
+			continue
+		}
+
+		updated, err := s.expireShortLink(ctx, shortLink, input)
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, updated)
+	}
+
+	return expired, nil
+}
+
+func (s *Slink) expireShortLink(ctx context.Context, current *models.ShortLink, input *ExpireInput) (*models.ShortLink, error) {
+	updated := *current
+	updated.ExpiresAt = time.Now().UTC().Format(time.RFC3339)
+
+	return s.modifyShortLink(ctx, EventShortLinkExpired, current, &updated, input.ExpiredBy, input.Reason)
+}
+
+// modifyShortLink replaces current with updated in the storage, recording the
+// change in the ShortLink history, and emits an Event of eventType.
+func (s *Slink) modifyShortLink(ctx context.Context, eventType string, current, updated *models.ShortLink, changedBy, reason string) (*models.ShortLink, error) {
+	change := &models.ShortLinkChange{
+		ShortLinkID:       current.ID,
+		ChangedAt:         time.Now().UTC().Format(changedAtLayout),
+		ChangedBy:         changedBy,
+		Reason:            reason,
+		PreviousLinkURL:   current.LinkURL,
+		LinkURL:           updated.LinkURL,
+		PreviousExpiresAt: current.ExpiresAt,
+		ExpiresAt:         updated.ExpiresAt,
+	}
+
+	err := s.storage.Update(ctx, updated, change)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Update: %w", err)
+	}
+
+	if s.lruCache != nil {
+		s.lruCache.Remove(current.ID)
+	}
+
+	s.emitEvent(ctx, eventType, updated, change)
+	return updated, nil
+}
+
+// like time.RFC3339Nano but with a fixed width, so that changes sort
+// chronologically as strings
+const changedAtLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// GetShortLinkHistory returns the changes made to the ShortLink with the given
+// ID, oldest first.
+func (s *Slink) GetShortLinkHistory(ctx context.Context, shortLinkID string) ([]*models.ShortLinkChange, error) {
+	if shortLinkID == "" {
+		return nil, &ErrInvalidShortLinkID{msg: "short link ID must not be empty"}
+	}
+
+	history, err := s.storage.GetHistory(ctx, shortLinkID)
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetHistory: %w", err)
+	}
+
+	return history, nil
+}
+
 // GetShortLinkByIDWithCache looks up ShortLink by the given ID from the LRU
 // cache first, if found it returns it, otherwise it looks the ShortLink up in
 // the storage, and returns it if it's found in the storage, adding it to the
@@ -101,10 +605,13 @@ func (s *Slink) GetShortLinkByIDWithCache(ctx context.Context, shortLinkID strin
 
 	if s.lruCache != nil {
 		if item, found := s.lruCache.Get(shortLinkID); found {
-			if shortLink, ok := item.(*models.ShortLink); ok {
-				return shortLink, nil
+			if cached, ok := item.(*cachedShortLink); ok {
+				if s.cacheTTL <= 0 || time.Since(cached.cachedAt) < s.cacheTTL {
+					return cached.shortLink, nil
+				}
+			} else {
+				log.Warn().Msgf("found item in LRU cache but failed to assert as *cachedShortLink, ignoring: %+v", item)
 			}
-			log.Warn().Msgf("found item in LRU cache but failed to assert as *models.ShortLink, ignoring: %+v", item)
 		}
 	}
 
@@ -114,13 +621,18 @@ func (s *Slink) GetShortLinkByIDWithCache(ctx context.Context, shortLinkID strin
 	}
 
 	if s.lruCache != nil {
-		evicted := s.lruCache.Add(shortLinkID, shortLink)
+		evicted := s.lruCache.Add(shortLinkID, &cachedShortLink{shortLink: shortLink, cachedAt: time.Now()})
 		log.Debug().Bool("evicted", evicted).Msg("added shortLink to LRU cache")
 	}
 
 	return shortLink, nil
 }
 
+type cachedShortLink struct {
+	shortLink *models.ShortLink
+	cachedAt  time.Time
+}
+
 // GetShortLinkByID looks up a ShortLink by its ID, returing it if found, or nil otherwise.
 func (s *Slink) GetShortLinkByID(ctx context.Context, shortLinkID string) (*models.ShortLink, error) {
 	if shortLinkID == "" {
@@ -135,20 +647,133 @@ func (s *Slink) GetShortLinkByID(ctx context.Context, shortLinkID string) (*mode
 	return shortLink, nil
 }
 
+// GetShortLinksByURL returns the ShortLinks to linkURL, or to an equivalent URL
+// (compared normalised, see WithURLNormaliser), most recently created first.
 func (s *Slink) GetShortLinksByURL(ctx context.Context, linkURL string) ([]*models.ShortLink, error) {
 	if linkURL == "" {
-		return nil, &ErrInvalidLinkURL{msg: "link URL must not be empty"}
+		return nil, &ErrInvalidLinkURL{Reason: LinkURLReasonEmpty, msg: "link URL must not be empty"}
+	}
+
+	normalisedLinkURL, err := s.normaliseLinkURL(linkURL)
+	if err != nil {
+		return nil, err
 	}
 
-	shortLinks, err := s.storage.GetByURL(ctx, linkURL)
+	shortLinks, err := s.storage.GetByURL(ctx, normalisedLinkURL)
 	if err != nil {
 		return nil, fmt.Errorf("storage.Get: %w", err)
 	}
 
+	// ShortLinks created before LinkURLs were normalised are indexed by their
+	// LinkURL as is.
+	if linkURL != normalisedLinkURL {
+		unnormalised, err := s.storage.GetByURL(ctx, linkURL)
+		if err != nil {
+			return nil, fmt.Errorf("storage.Get: %w", err)
+		}
+		for _, shortLink := range unnormalised {
+			if shortLink.NormalisedLinkURL == "" {
+				shortLinks = append(shortLinks, shortLink)
+			}
+		}
+		sort.SliceStable(shortLinks, func(i, j int) bool {
+			return shortLinks[i].CreatedAt > shortLinks[j].CreatedAt
+		})
+	}
+
 	return shortLinks, nil
 }
 
-const DefaultMaxCreateAttempts = 3
+// ListShortLinks returns a single page of ShortLinks matching the query, see
+// `storage.Storage.List`. CreatedAfter and CreatedBefore may be in any RFC3339
+// format, they're converted to UTC before querying the storage.
+func (s *Slink) ListShortLinks(ctx context.Context, query *storage.ListQuery) (*storage.ListPage, error) {
+	if query == nil {
+		return nil, errors.New("query is nil (BUG?)")
+	}
+
+	q := *query
+
+	if q.Limit == 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		return nil, &ErrInvalidListQuery{msg: fmt.Sprintf("limit must be between 1 and %d", MaxListLimit)}
+	}
+
+	switch q.Status {
+	case storage.ListStatusAny, storage.ListStatusActive, storage.ListStatusExpired:
+	default:
+		return nil, &ErrInvalidListQuery{msg: fmt.Sprintf("unknown status %q", q.Status)}
+	}
+
+	if q.LinkURL != "" {
+		var err error
+		q.LinkURL, err = s.normaliseLinkURL(q.LinkURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range []*string{&q.CreatedAfter, &q.CreatedBefore} {
+		if *t == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, *t)
+		if err != nil {
+			return nil, &ErrInvalidListQuery{msg: fmt.Sprintf("invalid RFC3339 time %q", *t)}
+		}
+		*t = parsed.UTC().Format(time.RFC3339)
+	}
+
+	page, err := s.storage.List(ctx, &q)
+	if err != nil {
+		return nil, fmt.Errorf("storage.List: %w", err)
+	}
+
+	return page, nil
+}
+
+// ExportShortLinks calls fn for every ShortLink in the storage, in no particular
+// order, stopping at the first error. fn is never called concurrently.
+func (s *Slink) ExportShortLinks(ctx context.Context, fn func(*models.ShortLink) error) error {
+	err := s.storage.ForEach(ctx, fn)
+	if err != nil {
+		return fmt.Errorf("storage.ForEach: %w", err)
+	}
+	return nil
+}
+
+// Storage returns the storage backend, e.g. to check whether it implements
+// optional interfaces like `storage.IdempotencyStore`.
+func (s *Slink) Storage() storage.Storage {
+	return s.storage
+}
+
+const (
+	DefaultMaxCreateAttempts = 3
+	DefaultListLimit         = 100
+	MaxListLimit             = 1000
+	// The default characters allowed in custom aliases, every char should be
+	// safe for use in URLs without extra encoding
+	DefaultAliasCharacters = ids.NanoIDDefaultCharacters + "-_"
+	DefaultAliasMinLength  = 3
+	DefaultAliasMaxLength  = 64
+	// Each tag is stored as a separate item in DynamoDB, so there shouldn't be
+	// too many of them.
+	MaxTags                = 10
+	MaxTagLength           = 64
+	MaxMetadataEntries     = 20
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 1024
+	// DefaultCacheTTL is how long a ShortLink stays in the LRU cache before
+	// it's looked up again, so that changes made by other processes are
+	// eventually picked up.
+	DefaultCacheTTL = 1 * time.Minute
+	// DefaultMaxRedirectDepth is how many ShortLinks on the own hosts a
+	// LinkURL can redirect through, see WithOwnHosts.
+	DefaultMaxRedirectDepth = 3
+)
 
 func NewSlink(ctx context.Context, options ...func(*Slink)) (*Slink, error) {
 	lruCache, err := lru.New(1000)
@@ -158,7 +783,12 @@ func NewSlink(ctx context.Context, options ...func(*Slink)) (*Slink, error) {
 
 	s := &Slink{
 		lruCache:          lruCache,
+		cacheTTL:          DefaultCacheTTL,
 		maxCreateAttempts: DefaultMaxCreateAttempts,
+		aliasChars:        DefaultAliasCharacters,
+		aliasMinLength:    DefaultAliasMinLength,
+		aliasMaxLength:    DefaultAliasMaxLength,
+		maxRedirectDepth:  DefaultMaxRedirectDepth,
 	}
 
 	for _, option := range options {
@@ -169,6 +799,20 @@ func NewSlink(ctx context.Context, options ...func(*Slink)) (*Slink, error) {
 		return nil, errors.New("maxCreateAttempts must be at least 1")
 	}
 
+	if s.aliasMinLength < 1 || s.aliasMaxLength < s.aliasMinLength {
+		return nil, errors.New("aliasMinLength must be at least 1 and not more than aliasMaxLength")
+	}
+
+	if s.maxRedirectDepth < 0 {
+		return nil, errors.New("maxRedirectDepth must not be negative")
+	}
+
+	// An empty, non-nil denylist indicates no denylist is wanted, like in
+	// `ids.NewNanoIDGenerator`.
+	if s.aliasDenylist == nil {
+		s.aliasDenylist = ids.DefaultDenylist()
+	}
+
 	if s.idgen == nil {
 		var err error
 		s.idgen, err = ids.NewNanoIDGenerator()
@@ -177,6 +821,14 @@ func NewSlink(ctx context.Context, options ...func(*Slink)) (*Slink, error) {
 		}
 	}
 
+	if s.urlPolicy == nil {
+		s.urlPolicy = DefaultURLPolicy()
+	}
+
+	if s.urlNormaliser == nil {
+		s.urlNormaliser = urls.NewStandardNormaliser()
+	}
+
 	if s.storage == nil {
 		var err error
 		s.storage, err = storage.NewDynamoDBStorage(ctx)
@@ -205,3 +857,117 @@ func WithMaxCreateAttempts(maxCreateAttempts int) func(*Slink) {
 		s.maxCreateAttempts = maxCreateAttempts
 	}
 }
+
+// WithCacheTTL specifies how long a ShortLink may be served from the LRU cache.
+// A TTL of 0 or less caches ShortLinks until they're evicted.
+func WithCacheTTL(cacheTTL time.Duration) func(*Slink) {
+	return func(s *Slink) {
+		s.cacheTTL = cacheTTL
+	}
+}
+
+// WithAliasCharacters specifies the characters allowed in custom aliases
+// (`CreateInput.ID`).
+func WithAliasCharacters(chars string) func(*Slink) {
+	return func(s *Slink) {
+		s.aliasChars = chars
+	}
+}
+
+// WithAliasLength specifies the minimum and maximum length of custom aliases
+// (`CreateInput.ID`).
+func WithAliasLength(minLength, maxLength int) func(*Slink) {
+	return func(s *Slink) {
+		s.aliasMinLength = minLength
+		s.aliasMaxLength = maxLength
+	}
+}
+
+// WithAliasDenylist specifies the denylist custom aliases (`CreateInput.ID`)
+// are checked against with `ids.IsAllowed`.
+func WithAliasDenylist(denylist ids.Denylist) func(*Slink) {
+	return func(s *Slink) {
+		s.aliasDenylist = denylist
+	}
+}
+
+// WithEventListener adds a listener notified of every ShortLink created,
+// updated or expired, see EventListener.
+func WithEventListener(listener EventListener) func(*Slink) {
+	return func(s *Slink) {
+		s.eventListeners = append(s.eventListeners, listener)
+	}
+}
+
+// WithOwnHosts specifies the hosts ShortLinks are served on (e.g. `sho.rt`),
+// so that a LinkURL pointing to a ShortLink on them is followed, and rejected
+// with ErrRedirectLoop if it redirects in a loop or too deep, see
+// WithMaxRedirectDepth. A host without a port matches any port.
+func WithOwnHosts(hosts ...string) func(*Slink) {
+	return func(s *Slink) {
+		if s.ownHosts == nil {
+			s.ownHosts = make(map[string]bool, len(hosts))
+		}
+		for _, host := range hosts {
+			s.ownHosts[normaliseHost(host)] = true
+		}
+	}
+}
+
+// WithMaxRedirectDepth specifies how many ShortLinks on the own hosts a LinkURL
+// can redirect through, 0 rejecting any LinkURL to them.
+func WithMaxRedirectDepth(maxRedirectDepth int) func(*Slink) {
+	return func(s *Slink) {
+		s.maxRedirectDepth = maxRedirectDepth
+	}
+}
+
+// WithURLNormaliser specifies how LinkURLs are normalised, so that ShortLinks
+// to equivalent URLs are looked up as the same URL (e.g. by
+// GetOrCreateShortLink), instead of `urls.NewStandardNormaliser()`.
+// ShortLinks keep their LinkURL as is for redirects.
+func WithURLNormaliser(normaliser urls.Normaliser) func(*Slink) {
+	return func(s *Slink) {
+		s.urlNormaliser = normaliser
+	}
+}
+
+// WithURLPolicy specifies the LinkURLs that ShortLinks can be created with or
+// updated to, instead of DefaultURLPolicy(). An empty URLPolicy only rejects
+// empty LinkURLs.
+func WithURLPolicy(policy *URLPolicy) func(*Slink) {
+	return func(s *Slink) {
+		s.urlPolicy = policy
+	}
+}
+
+// Blocklist refuses LinkURLs, e.g. to known malicious domains, see
+// `blocklist.Blocklist`.
+type Blocklist interface {
+	// Blocked returns the entry of the Blocklist that linkURL matches, if any.
+	Blocked(linkURL string) (string, bool)
+}
+
+// CreateLimiter limits the ShortLinks that can be created, e.g. by a quota.
+type CreateLimiter interface {
+	// AllowCreate is called with the number of ShortLinks about to be
+	// created, once their inputs are valid, and fails their creation with
+	// its error. It's not called when GetOrCreateShortLink gets an existing
+	// ShortLink.
+	AllowCreate(ctx context.Context, n int) error
+}
+
+// WithCreateLimiter asks the limiter before creating ShortLinks.
+func WithCreateLimiter(limiter CreateLimiter) func(*Slink) {
+	return func(s *Slink) {
+		s.createLimiter = limiter
+	}
+}
+
+// WithBlocklist rejects LinkURLs matching the blocklist when creating or
+// updating ShortLinks, with an ErrInvalidLinkURL.
+func WithBlocklist(blocklist Blocklist) func(*Slink) {
+	return func(s *Slink) {
+		s.blocklist = blocklist
+	}
+}
@@ -0,0 +1,478 @@
+package slink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+)
+
+// batchMemoryStorage is a MemoryStorage that implements storage.BatchCreator.
+type batchMemoryStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s *batchMemoryStorage) CreateBatch(ctx context.Context, shortLinks []*models.ShortLink) []error {
+	errs := make([]error, len(shortLinks))
+	for i, shortLink := range shortLinks {
+		errs[i] = s.Create(ctx, shortLink)
+	}
+	return errs
+}
+
+// testCreateLimiter allows creating up to remaining ShortLinks.
+type testCreateLimiter struct {
+	remaining int
+	calls     int
+}
+
+var errTestQuotaExceeded = errors.New("quota exceeded")
+
+func (l *testCreateLimiter) AllowCreate(ctx context.Context, n int) error {
+	l.calls++
+	if n > l.remaining {
+		return errTestQuotaExceeded
+	}
+	l.remaining -= n
+	return nil
+}
+
+func TestCreateShortLinksLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		remaining   int
+		inputs      int
+		wantCreated int
+		wantCalls   int
+	}{
+		{name: "all allowed", remaining: 5, inputs: 5, wantCreated: 5, wantCalls: 1},
+		{name: "some allowed", remaining: 2, inputs: 5, wantCreated: 2, wantCalls: 1 + 3},
+		{name: "none allowed", remaining: 0, inputs: 5, wantCreated: 0, wantCalls: 1 + 1},
+		{name: "one not allowed", remaining: 0, inputs: 1, wantCreated: 0, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &testCreateLimiter{remaining: tt.remaining}
+			s := newTestSlink(t, WithStorage(&batchMemoryStorage{storage.NewMemoryStorage()}), WithCreateLimiter(limiter))
+
+			var inputs []*CreateInput
+			for i := 0; i < tt.inputs; i++ {
+				inputs = append(inputs, &CreateInput{LinkURL: fmt.Sprintf("https://example.com/%d", i)})
+			}
+
+			var created int
+			for i, result := range s.CreateShortLinks(context.Background(), inputs) {
+				switch {
+				case result.Err == nil:
+					if i >= tt.wantCreated {
+						t.Errorf("input %d: created, want the first %d inputs created", i, tt.wantCreated)
+					}
+					created++
+				case !errors.Is(result.Err, errTestQuotaExceeded):
+					t.Errorf("input %d: got error %v, want %v", i, result.Err, errTestQuotaExceeded)
+				}
+			}
+			if created != tt.wantCreated || limiter.calls != tt.wantCalls {
+				t.Errorf("got %d created with %d AllowCreate calls, want %d with %d", created, limiter.calls, tt.wantCreated, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// testBlocklist blocks the link URLs starting with one of its entries.
+type testBlocklist []string
+
+func (b testBlocklist) Blocked(linkURL string) (string, bool) {
+	for _, entry := range b {
+		if strings.HasPrefix(linkURL, entry) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+func TestBlockedLinkURL(t *testing.T) {
+	blocklist := testBlocklist{}
+	s := newTestSlink(t, WithBlocklist(&blocklist))
+	ctx := context.Background()
+
+	shortLink, err := s.CreateShortLink(ctx, &CreateInput{LinkURL: "https://example.com/a"})
+	if err != nil {
+		t.Fatalf("CreateShortLink: %v", err)
+	}
+	// blocked once the ShortLink exists
+	blocklist = append(blocklist, "https://example.com/")
+
+	_, err = s.CreateShortLink(ctx, &CreateInput{LinkURL: "https://example.com/b"})
+	checkLinkURLReason(t, err, LinkURLReasonBlocked)
+
+	_, err = s.CreateShortLink(ctx, &CreateInput{LinkURL: "https://example.org/", ActivatesAt: "2030-01-01T00:00:00Z", ComingSoonURL: "https://example.com/soon"})
+	checkLinkURLReason(t, err, LinkURLReasonBlocked)
+
+	_, err = s.GetOrCreateShortLink(ctx, &CreateInput{LinkURL: "https://example.com/a"})
+	checkLinkURLReason(t, err, LinkURLReasonBlocked)
+
+	_, err = s.UpdateShortLink(ctx, shortLink.ID, &UpdateInput{LinkURL: "https://example.com/c"})
+	checkLinkURLReason(t, err, LinkURLReasonBlocked)
+
+	_, err = s.CreateShortLink(ctx, &CreateInput{LinkURL: "https://example.org/"})
+	checkLinkURLReason(t, err, "")
+}
+
+func TestCreateShortLinkActivatesAt(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            *CreateInput
+		wantNotYetActive bool
+		// wantErr is a part of the message of an ErrInvalidActivatesAt
+		wantErr    string
+		wantReason string
+	}{
+		{name: "without activatesAt", input: &CreateInput{LinkURL: "https://example.com/"}},
+		{name: "activatesAt in the past", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2020-01-01T00:00:00Z"}},
+		{name: "activatesAt in the future", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2099-01-01T00:00:00Z"}, wantNotYetActive: true},
+		{name: "activatesAt in the future with an offset", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2099-01-01T00:00:00+10:00"}, wantNotYetActive: true},
+		{name: "coming soon URL", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2099-01-01T00:00:00Z", ComingSoonURL: "https://example.com/soon"}, wantNotYetActive: true},
+
+		{name: "activatesAt not RFC3339", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "tomorrow"}, wantErr: "RFC3339"},
+		{name: "activatesAt without time", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2099-01-01"}, wantErr: "RFC3339"},
+		{name: "coming soon URL without activatesAt", input: &CreateInput{LinkURL: "https://example.com/", ComingSoonURL: "https://example.com/soon"}, wantErr: "comingSoonUrl requires activatesAt"},
+		{name: "invalid coming soon URL", input: &CreateInput{LinkURL: "https://example.com/", ActivatesAt: "2099-01-01T00:00:00Z", ComingSoonURL: "soon"}, wantReason: LinkURLReasonNotAbsolute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t)
+			ctx := context.Background()
+
+			shortLink, err := s.CreateShortLink(ctx, tt.input)
+			switch {
+			case tt.wantErr != "":
+				var activatesAtErr *ErrInvalidActivatesAt
+				if !errors.As(err, &activatesAtErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want an ErrInvalidActivatesAt containing %q", err, tt.wantErr)
+				}
+				return
+			case tt.wantReason != "":
+				checkLinkURLReason(t, err, tt.wantReason)
+				return
+			case err != nil:
+				t.Fatalf("CreateShortLink: %v", err)
+			}
+
+			stored, err := s.GetShortLinkByID(ctx, shortLink.ID)
+			if err != nil {
+				t.Fatalf("GetShortLinkByID: %v", err)
+			}
+			if stored.NotYetActive() != tt.wantNotYetActive || stored.ActivatesAt != tt.input.ActivatesAt || stored.ComingSoonURL != tt.input.ComingSoonURL {
+				t.Errorf("got not yet active %t, activatesAt %q, comingSoonUrl %q, want %t, %q, %q", stored.NotYetActive(), stored.ActivatesAt, stored.ComingSoonURL, tt.wantNotYetActive, tt.input.ActivatesAt, tt.input.ComingSoonURL)
+			}
+		})
+	}
+}
+
+func TestGetOrCreateShortLinkSchedule(t *testing.T) {
+	const linkURL, future = "https://example.com/", "2099-01-01T00:00:00Z"
+	existing := CreateInput{ID: "existing", LinkURL: linkURL, ActivatesAt: future, ComingSoonURL: "https://example.com/soon"}
+
+	tests := []struct {
+		name         string
+		change       func(*CreateInput)
+		wantExisting bool
+		wantErr      bool
+	}{
+		{name: "same schedule", change: func(input *CreateInput) {}, wantExisting: true},
+		{name: "same schedule, normalised link URL", change: func(input *CreateInput) { input.LinkURL = "HTTPS://EXAMPLE.com" }, wantExisting: true},
+		{name: "another activatesAt", change: func(input *CreateInput) { input.ActivatesAt = "2098-01-01T00:00:00Z" }},
+		{name: "another coming soon URL", change: func(input *CreateInput) { input.ComingSoonURL = "https://example.com/later" }},
+		{name: "without coming soon URL", change: func(input *CreateInput) { input.ComingSoonURL = "" }},
+		{name: "without activatesAt", change: func(input *CreateInput) { input.ActivatesAt, input.ComingSoonURL = "", "" }},
+		{name: "with expiresAt", change: func(input *CreateInput) { input.ExpiresAt = "2099-02-01T00:00:00Z" }},
+		{name: "same schedule and ID", change: func(input *CreateInput) { input.ID = existing.ID }, wantExisting: true},
+		{name: "another coming soon URL, same ID", change: func(input *CreateInput) { input.ID, input.ComingSoonURL = existing.ID, "https://example.com/later" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t)
+			ctx := context.Background()
+
+			input := existing
+			_, err := s.CreateShortLink(ctx, &input)
+			if err != nil {
+				t.Fatalf("CreateShortLink: %v", err)
+			}
+
+			input = existing
+			input.ID = ""
+			tt.change(&input)
+			shortLink, err := s.GetOrCreateShortLink(ctx, &input)
+			if tt.wantErr {
+				var existsErr *storage.ErrShortLinkAlreadyExists
+				if !errors.As(err, &existsErr) {
+					t.Fatalf("got error %v, want an ErrShortLinkAlreadyExists", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetOrCreateShortLink: %v", err)
+			}
+			if (shortLink.ID == existing.ID) != tt.wantExisting {
+				t.Errorf("got short link %s, want the existing one: %t", shortLink.ID, tt.wantExisting)
+			}
+			if shortLink.ActivatesAt != input.ActivatesAt || shortLink.ComingSoonURL != input.ComingSoonURL || shortLink.ExpiresAt != input.ExpiresAt {
+				t.Errorf("got short link scheduled %q, %q, %q, want %q, %q, %q", shortLink.ActivatesAt, shortLink.ComingSoonURL, shortLink.ExpiresAt, input.ActivatesAt, input.ComingSoonURL, input.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestExpireShortLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           string
+		expiredFirst bool
+		wantErr      bool
+		wantChanges  int
+	}{
+		{name: "active", id: "abc", wantChanges: 1},
+		{name: "already expired", id: "abc", expiredFirst: true, wantChanges: 1},
+		{name: "not found", id: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t)
+			ctx := context.Background()
+
+			_, err := s.CreateShortLink(ctx, &CreateInput{ID: "abc", LinkURL: "https://example.com/"})
+			if err != nil {
+				t.Fatalf("CreateShortLink: %v", err)
+			}
+			if tt.expiredFirst {
+				_, err = s.ExpireShortLink(ctx, "abc", &ExpireInput{Reason: "first", ExpiredBy: "test"})
+				if err != nil {
+					t.Fatalf("ExpireShortLink: %v", err)
+				}
+			}
+
+			shortLink, err := s.ExpireShortLink(ctx, tt.id, &ExpireInput{Reason: "spam", ExpiredBy: "test"})
+			if tt.wantErr {
+				var notFoundErr *ErrShortLinkNotFound
+				if !errors.As(err, &notFoundErr) {
+					t.Fatalf("got error %v, want an ErrShortLinkNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpireShortLink: %v", err)
+			}
+			if !shortLink.Expired() {
+				t.Errorf("got short link expiring at %q, want it expired", shortLink.ExpiresAt)
+			}
+
+			history, err := s.GetShortLinkHistory(ctx, tt.id)
+			if err != nil {
+				t.Fatalf("GetShortLinkHistory: %v", err)
+			}
+			if len(history) != tt.wantChanges {
+				t.Fatalf("got %d changes, want %d", len(history), tt.wantChanges)
+			}
+			// expiring again doesn't record another change
+			change := history[0]
+			wantReason := "spam"
+			if tt.expiredFirst {
+				wantReason = "first"
+			}
+			if change.Reason != wantReason || change.ChangedBy != "test" || change.PreviousExpiresAt != "" || change.ExpiresAt != shortLink.ExpiresAt {
+				t.Errorf("got change %+v, want the expiry for %q", change, wantReason)
+			}
+		})
+	}
+}
+
+func TestExpireShortLinksByURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		linkURL string
+		want    []string
+	}{
+		{name: "same URL", linkURL: "https://example.com/a", want: []string{"aaa"}},
+		{name: "URL to normalise", linkURL: "HTTPS://Example.com:443/a?utm_source=x", want: []string{"aaa"}},
+		{name: "already expired", linkURL: "https://example.com/c", want: []string{}},
+		{name: "without short links", linkURL: "https://example.com/d", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t)
+			ctx := context.Background()
+
+			for _, input := range []*CreateInput{
+				{ID: "aaa", LinkURL: "https://example.com/a"},
+				{ID: "bbb", LinkURL: "https://example.com/b"},
+				{ID: "ccc", LinkURL: "https://example.com/c"},
+			} {
+				_, err := s.CreateShortLink(ctx, input)
+				if err != nil {
+					t.Fatalf("CreateShortLink(%s): %v", input.ID, err)
+				}
+			}
+			_, err := s.ExpireShortLink(ctx, "ccc", &ExpireInput{})
+			if err != nil {
+				t.Fatalf("ExpireShortLink: %v", err)
+			}
+
+			expired, err := s.ExpireShortLinksByURL(ctx, tt.linkURL, &ExpireInput{Reason: "spam"})
+			if err != nil {
+				t.Fatalf("ExpireShortLinksByURL: %v", err)
+			}
+			got := []string{}
+			for _, shortLink := range expired {
+				got = append(got, shortLink.ID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v expired, want %v", got, tt.want)
+			}
+
+			for _, id := range []string{"aaa", "bbb"} {
+				shortLink, err := s.GetShortLinkByID(ctx, id)
+				if err != nil {
+					t.Fatalf("GetShortLinkByID(%s): %v", id, err)
+				}
+				wantExpired := false
+				for _, expiredID := range tt.want {
+					wantExpired = wantExpired || expiredID == id
+				}
+				if shortLink.Expired() != wantExpired {
+					t.Errorf("%s: got expired %t, want %t", id, shortLink.Expired(), wantExpired)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateShortLinkAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*Slink)
+		alias   string
+		// wantErr is a part of the message of an ErrInvalidShortLinkID
+		wantErr    string
+		wantExists bool
+	}{
+		{name: "valid", alias: "summer-sale_2024"},
+		{name: "exactly the min length", alias: "abc"},
+		{name: "one short of the min length", alias: "ab", wantErr: "between 3 and 64 characters"},
+		{name: "exactly the max length", alias: strings.Repeat("a", DefaultAliasMaxLength)},
+		{name: "one past the max length", alias: strings.Repeat("a", DefaultAliasMaxLength+1), wantErr: "between 3 and 64 characters"},
+		{name: "slash", alias: "summer/sale", wantErr: "must only contain"},
+		{name: "space", alias: "summer sale", wantErr: "must only contain"},
+		{name: "non-ASCII", alias: "été-sale", wantErr: "must only contain"},
+		{name: "in the default denylist", alias: "xx-455-xx", wantErr: "not allowed"},
+		{name: "in a denylist, in another case", options: []func(*Slink){WithAliasDenylist([]string{"sale"})}, alias: "Summer-SALE", wantErr: "not allowed"},
+		{name: "custom characters", options: []func(*Slink){WithAliasCharacters("abc")}, alias: "cab"},
+		{name: "not a custom character", options: []func(*Slink){WithAliasCharacters("abc")}, alias: "abcd", wantErr: "must only contain"},
+		{name: "custom length", options: []func(*Slink){WithAliasLength(1, 2)}, alias: "a"},
+		{name: "past a custom max length", options: []func(*Slink){WithAliasLength(1, 2)}, alias: "abc", wantErr: "between 1 and 2 characters"},
+		{name: "already taken", alias: "taken", wantExists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t, tt.options...)
+			ctx := context.Background()
+
+			if tt.wantExists {
+				_, err := s.CreateShortLink(ctx, &CreateInput{ID: tt.alias, LinkURL: "https://example.com/taken"})
+				if err != nil {
+					t.Fatalf("CreateShortLink(%s): %v", tt.alias, err)
+				}
+			}
+
+			shortLink, err := s.CreateShortLink(ctx, &CreateInput{ID: tt.alias, LinkURL: "https://example.com/"})
+			switch {
+			case tt.wantErr != "":
+				var idErr *ErrInvalidShortLinkID
+				if !errors.As(err, &idErr) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want an ErrInvalidShortLinkID containing %q", err, tt.wantErr)
+				}
+			case tt.wantExists:
+				var existsErr *storage.ErrShortLinkAlreadyExists
+				if !errors.As(err, &existsErr) || existsErr.ShortLinkID != tt.alias {
+					t.Fatalf("got error %v, want an ErrShortLinkAlreadyExists for %s", err, tt.alias)
+				}
+			case err != nil:
+				t.Fatalf("CreateShortLink: %v", err)
+			case shortLink.ID != tt.alias:
+				t.Errorf("got ID %q, want %q", shortLink.ID, tt.alias)
+			}
+		})
+	}
+}
+
+func TestCreateShortLinkTagsAndMetadata(t *testing.T) {
+	tooManyTags := make([]string, MaxTags+1)
+	for i := range tooManyTags {
+		tooManyTags[i] = fmt.Sprintf("tag%d", i)
+	}
+	tooManyEntries := make(map[string]string, MaxMetadataEntries+1)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooManyEntries[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name            string
+		tags            []string
+		metadata        map[string]string
+		wantTagErr      bool
+		wantMetadataErr bool
+	}{
+		{name: "neither"},
+		{name: "valid", tags: []string{"campaign", "Summer Sale"}, metadata: map[string]string{"team": "growth", "note": ""}},
+		{name: "max tags", tags: tooManyTags[:MaxTags]},
+		{name: "too many tags", tags: tooManyTags, wantTagErr: true},
+		{name: "empty tag", tags: []string{""}, wantTagErr: true},
+		{name: "max tag length", tags: []string{strings.Repeat("a", MaxTagLength)}},
+		{name: "tag too long", tags: []string{strings.Repeat("a", MaxTagLength+1)}, wantTagErr: true},
+		{name: "tag with a comma", tags: []string{"a,b"}, wantTagErr: true},
+		{name: "tag with a line break", tags: []string{"a\nb"}, wantTagErr: true},
+		{name: "duplicate tag", tags: []string{"a", "b", "a"}, wantTagErr: true},
+		{name: "tags differing in case", tags: []string{"a", "A"}},
+
+		{name: "too many metadata entries", metadata: tooManyEntries, wantMetadataErr: true},
+		{name: "empty metadata key", metadata: map[string]string{"": "value"}, wantMetadataErr: true},
+		{name: "metadata key too long", metadata: map[string]string{strings.Repeat("k", MaxMetadataKeyLength+1): "value"}, wantMetadataErr: true},
+		{name: "max metadata value length", metadata: map[string]string{"key": strings.Repeat("v", MaxMetadataValueLength)}},
+		{name: "metadata value too long", metadata: map[string]string{"key": strings.Repeat("v", MaxMetadataValueLength+1)}, wantMetadataErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSlink(t)
+			ctx := context.Background()
+
+			shortLink, err := s.CreateShortLink(ctx, &CreateInput{LinkURL: "https://example.com/", Tags: tt.tags, Metadata: tt.metadata})
+			var tagErr *ErrInvalidTag
+			var metadataErr *ErrInvalidMetadata
+			if errors.As(err, &tagErr) != tt.wantTagErr || errors.As(err, &metadataErr) != tt.wantMetadataErr {
+				t.Fatalf("got error %v, want a tag error: %t, a metadata error: %t", err, tt.wantTagErr, tt.wantMetadataErr)
+			}
+			if tt.wantTagErr || tt.wantMetadataErr {
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateShortLink: %v", err)
+			}
+
+			stored, err := s.GetShortLinkByID(ctx, shortLink.ID)
+			if err != nil {
+				t.Fatalf("GetShortLinkByID: %v", err)
+			}
+			if !reflect.DeepEqual(stored.Tags, tt.tags) || !reflect.DeepEqual(stored.Metadata, tt.metadata) {
+				t.Errorf("got tags %v, metadata %v, want %v, %v", stored.Tags, stored.Metadata, tt.tags, tt.metadata)
+			}
+		})
+	}
+}
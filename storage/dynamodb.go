@@ -2,8 +2,13 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -19,6 +24,9 @@ const (
 	DynamoDBDefaultTableName = "slink"
 	DynamoDBDefaultGSI1Name  = "GSI1"
 	DynamoDBDefaultRegion    = "us-east-1"
+	// DynamoDBDefaultScanSegments is the default number of segments scanned in
+	// parallel when iterating over every ShortLink.
+	DynamoDBDefaultScanSegments = 4
 )
 
 // DynamoDBStorage implements the Storage interface using Amazon DynamoDB as the
@@ -29,6 +37,20 @@ const (
 // table automatically if the table doesn’t exist (requires
 // `dynamodb:CreateTable` IAM permission).
 //
+// The table follows the single table design, the type of each item is in its
+// `_type` attribute:
+// - `ShortLink`: pk = sk = ID, gsi1pk = NormalisedLinkURL (or LinkURL without
+// it, see `ShortLink.IndexedLinkURL`), gsi1sk = CreatedAt
+// - `ShortLinkChange`: pk = ShortLink ID, sk = `change#<ChangedAt>`
+// - `ShortLinkTag`: pk = `tag#<tag>`, sk = ShortLink ID
+// - `IdempotencyRecord`: pk = sk = `idempotency#<Key>`
+// - `QuotaUsage`: pk = sk = `quota#<key>`, with the usage in `used`
+// - `WebhookDeadLetter`: pk = `webhook-dead-letter#<EndpointID>`,
+// sk = `<CreatedAt>#<EventID>`
+//
+// IdempotencyRecords and QuotaUsages have a `ttl` attribute (in Unix epoch
+// seconds), enable Time to Live on it to have expired items deleted.
+//
 // The suggested method to supply AWS credentials to the process is by using a
 // dedicated IAM role.  DynamoDBStorage will use STS by default when available.
 // You can customise this by using `WithDynamoDBConfig` and supplying your own
@@ -37,51 +59,192 @@ const (
 // The minimum required permissions are:
 // - `dynamodb:PutItem`
 // - `dynamodb:GetItem`
+// - `dynamodb:DeleteItem`
+// - `dynamodb:UpdateItem`
+// - `dynamodb:BatchGetItem`
 // - `dynamodb:Query`
+// - `dynamodb:Scan`
 // - `dynamodb:DescribeTable`
 // - `dynamodb:CreateTable` (only if you want the table to be created automatically)
 //
 // (or check `DynamoDBClient` interface if this list is out of date)
 type DynamoDBStorage struct {
-	tableName string
-	gsi1Name  string
-	region    string
-	awsConfig *aws.Config
-	client    DynamoDBClient
+	tableName    string
+	gsi1Name     string
+	region       string
+	scanSegments int
+	awsConfig    *aws.Config
+	client       DynamoDBClient
 }
 
-var _ Storage = (*DynamoDBStorage)(nil)
+var (
+	_ Storage                = (*DynamoDBStorage)(nil)
+	_ BatchCreator           = (*DynamoDBStorage)(nil)
+	_ IdempotencyStore       = (*DynamoDBStorage)(nil)
+	_ QuotaStore             = (*DynamoDBStorage)(nil)
+	_ WebhookDeadLetterStore = (*DynamoDBStorage)(nil)
+)
 
+// Create puts the ShortLink item, together with a tag-index item for each of
+// its tags in the same transaction.
 func (d *DynamoDBStorage) Create(ctx context.Context, shortLink *models.ShortLink) error {
-	item := &ddbShortLinkItem{
-		ShortLink: shortLink,
-		Type:      "ShortLink",
-		PK:        shortLink.ID,
-		SK:        shortLink.ID,
-		GSI1PK:    shortLink.LinkURL,
-		GSI1SK:    shortLink.CreatedAt,
+	transactItems, err := d.createTransactItems(shortLink)
+	if err != nil {
+		return err
 	}
 
-	avItem, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	if len(transactItems) == 1 {
+		put := transactItems[0].Put
+		_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           put.TableName,
+			Item:                put.Item,
+			ConditionExpression: put.ConditionExpression,
+		})
+		if err != nil {
+			var ccfe *types.ConditionalCheckFailedException
+			if errors.As(err, &ccfe) {
+				return &ErrShortLinkAlreadyExists{ShortLinkID: shortLink.ID}
+			}
+			return fmt.Errorf("ddb.PutItem: %w: %v", err, put.Item)
+		}
+		return nil
 	}
 
-	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           aws.String(d.tableName),
-		Item:                avItem,
-		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
 	})
 	if err != nil {
-		var ccfe *types.ConditionalCheckFailedException
-		if errors.As(err, &ccfe) {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 &&
+			aws.ToString(tce.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
 			return &ErrShortLinkAlreadyExists{ShortLinkID: shortLink.ID}
 		}
-		return fmt.Errorf("ddb.PutItem: %w: %v", err, avItem)
+		return fmt.Errorf("ddb.TransactWriteItems: %w", err)
 	}
 	return nil
 }
 
+// createTransactItems returns the conditional put of the ShortLink item,
+// followed by the puts of its tag-index items.
+func (d *DynamoDBStorage) createTransactItems(shortLink *models.ShortLink) ([]types.TransactWriteItem, error) {
+	avItem, err := attributevalue.MarshalMap(newDDBShortLinkItem(shortLink))
+	if err != nil {
+		return nil, fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Put: &types.Put{
+				TableName:           aws.String(d.tableName),
+				Item:                avItem,
+				ConditionExpression: aws.String("attribute_not_exists(pk)"),
+			},
+		},
+	}
+
+	for _, tag := range shortLink.Tags {
+		avTag, err := attributevalue.MarshalMap(&ddbShortLinkTagItem{
+			ShortLinkID: shortLink.ID,
+			CreatedAt:   shortLink.CreatedAt,
+			Type:        "ShortLinkTag",
+			PK:          ddbTagPKPrefix + tag,
+			SK:          shortLink.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ddbAV.MarshalMap: %w", err)
+		}
+
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(d.tableName),
+				Item:      avTag,
+			},
+		})
+	}
+
+	return transactItems, nil
+}
+
+// The maximum number of items in a single TransactWriteItems request.
+const ddbMaxTransactItems = 25
+
+// CreateBatch writes the ShortLinks (and their tag-index items) in chunks
+// using TransactWriteItems rather than BatchWriteItem, because BatchWriteItem
+// doesn't support the condition that prevents overwriting an existing
+// ShortLink. A chunk is cancelled as a whole when any of its ShortLinks already
+// exists, in which case the other ShortLinks in the chunk are created one by
+// one.
+func (d *DynamoDBStorage) CreateBatch(ctx context.Context, shortLinks []*models.ShortLink) []error {
+	errs := make([]error, len(shortLinks))
+	seen := make(map[string]bool, len(shortLinks))
+
+	var (
+		transactItems []types.TransactWriteItem
+		// the index of the ShortLink each transact item belongs to
+		owners []int
+	)
+
+	for i, shortLink := range shortLinks {
+		// a transaction must not contain the same item more than once
+		if seen[shortLink.ID] {
+			errs[i] = &ErrShortLinkAlreadyExists{ShortLinkID: shortLink.ID}
+			continue
+		}
+		seen[shortLink.ID] = true
+
+		items, err := d.createTransactItems(shortLink)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		if len(transactItems)+len(items) > ddbMaxTransactItems {
+			d.createChunk(ctx, shortLinks, transactItems, owners, errs)
+			transactItems, owners = nil, nil
+		}
+
+		transactItems = append(transactItems, items...)
+		for range items {
+			owners = append(owners, i)
+		}
+	}
+
+	if len(transactItems) > 0 {
+		d.createChunk(ctx, shortLinks, transactItems, owners, errs)
+	}
+
+	return errs
+}
+
+func (d *DynamoDBStorage) createChunk(ctx context.Context, shortLinks []*models.ShortLink, transactItems []types.TransactWriteItem, owners []int, errs []error) {
+	_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err == nil {
+		return
+	}
+
+	var tce *types.TransactionCanceledException
+	for j, i := range owners {
+		// only the first item of each ShortLink (the ShortLink item itself) has
+		// a condition
+		if j > 0 && owners[j-1] == i {
+			continue
+		}
+
+		if !errors.As(err, &tce) {
+			errs[i] = fmt.Errorf("ddb.TransactWriteItems: %w", err)
+			continue
+		}
+
+		if j < len(tce.CancellationReasons) && aws.ToString(tce.CancellationReasons[j].Code) == "ConditionalCheckFailed" {
+			errs[i] = &ErrShortLinkAlreadyExists{ShortLinkID: shortLinks[i].ID}
+			continue
+		}
+		errs[i] = d.Create(ctx, shortLinks[i])
+	}
+}
+
 func (d *DynamoDBStorage) GetByID(ctx context.Context, shortLinkID string) (*models.ShortLink, error) {
 	output, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(d.tableName),
@@ -103,7 +266,7 @@ func (d *DynamoDBStorage) GetByID(ctx context.Context, shortLinkID string) (*mod
 }
 
 func (d *DynamoDBStorage) GetByURL(ctx context.Context, linkURL string) ([]*models.ShortLink, error) {
-	output, err := d.client.Query(ctx, &dynamodb.QueryInput{
+	paginator := dynamodb.NewQueryPaginator(d.client, &dynamodb.QueryInput{
 		TableName:              aws.String(d.tableName),
 		IndexName:              aws.String(d.gsi1Name),
 		KeyConditionExpression: aws.String("#gsi1pk = :gsi1pk"),
@@ -114,27 +277,614 @@ func (d *DynamoDBStorage) GetByURL(ctx context.Context, linkURL string) ([]*mode
 			":gsi1pk": &types.AttributeValueMemberS{Value: linkURL},
 		},
 		ScanIndexForward: aws.Bool(false), // most recently created first
-		// TODO: ExclusiveStartKey
-		// TODO: Limit
 	})
+
+	result := make([]*models.ShortLink, 0)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.Query: %w", err)
+		}
+
+		for _, item := range output.Items {
+			var av *ddbShortLinkItem
+			err = attributevalue.UnmarshalMap(item, &av)
+			if err != nil {
+				return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
+			}
+			result = append(result, av.ShortLink)
+		}
+	}
+	return result, nil
+}
+
+func (d *DynamoDBStorage) Update(ctx context.Context, shortLink *models.ShortLink, change *models.ShortLinkChange) error {
+	avItem, err := attributevalue.MarshalMap(newDDBShortLinkItem(shortLink))
 	if err != nil {
-		return nil, fmt.Errorf("ddb.Query: %w", err)
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
 	}
 
-	// TODO: handle pagination
+	avChange, err := attributevalue.MarshalMap(&ddbShortLinkChangeItem{
+		ShortLinkChange: change,
+		Type:            "ShortLinkChange",
+		PK:              shortLink.ID,
+		SK:              ddbShortLinkChangeSKPrefix + change.ChangedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
 
-	result := make([]*models.ShortLink, 0)
-	for _, item := range output.Items {
+	condition := "attribute_exists(pk) AND #linkUrl = :previousLinkUrl"
+	names := map[string]string{
+		"#linkUrl": "linkUrl",
+	}
+	values := map[string]types.AttributeValue{
+		":previousLinkUrl": &types.AttributeValueMemberS{Value: change.PreviousLinkURL},
+	}
+	if change.PreviousExpiresAt == "" {
+		condition += " AND attribute_not_exists(#expiresAt)"
+	} else {
+		condition += " AND #expiresAt = :previousExpiresAt"
+		values[":previousExpiresAt"] = &types.AttributeValueMemberS{Value: change.PreviousExpiresAt}
+	}
+	names["#expiresAt"] = "expiresAt"
+
+	_, err = d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                 aws.String(d.tableName),
+					Item:                      avItem,
+					ConditionExpression:       aws.String(condition),
+					ExpressionAttributeNames:  names,
+					ExpressionAttributeValues: values,
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(d.tableName),
+					Item:                avChange,
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) {
+			return &ErrShortLinkModified{ShortLinkID: shortLink.ID}
+		}
+		return fmt.Errorf("ddb.TransactWriteItems: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStorage) GetHistory(ctx context.Context, shortLinkID string) ([]*models.ShortLinkChange, error) {
+	paginator := dynamodb.NewQueryPaginator(d.client, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk AND begins_with(#sk, :skPrefix)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "pk",
+			"#sk": "sk",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":       &types.AttributeValueMemberS{Value: shortLinkID},
+			":skPrefix": &types.AttributeValueMemberS{Value: ddbShortLinkChangeSKPrefix},
+		},
+		ScanIndexForward: aws.Bool(true), // oldest first
+	})
+
+	result := make([]*models.ShortLinkChange, 0)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.Query: %w", err)
+		}
+
+		for _, item := range output.Items {
+			var av *ddbShortLinkChangeItem
+			err = attributevalue.UnmarshalMap(item, &av)
+			if err != nil {
+				return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
+			}
+			result = append(result, av.ShortLinkChange)
+		}
+	}
+	return result, nil
+}
+
+// List uses a Query of the tag-index items when query.Tag is specified, a GSI1
+// Query when query.LinkURL is specified, otherwise a Scan of the whole table.
+// The status filter is applied after each page is read, so pages are often
+// smaller than query.Limit.
+func (d *DynamoDBStorage) List(ctx context.Context, query *ListQuery) (*ListPage, error) {
+	startKey, err := decodeDynamoDBCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Tag != "" {
+		return d.listByTag(ctx, query, startKey)
+	}
+
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	var createdAtConditions []string
+
+	createdAtAttr := "createdAt"
+	if query.LinkURL != "" {
+		// GSI1 is sorted by CreatedAt already, so it's part of the key condition
+		createdAtAttr = "gsi1sk"
+	}
+	if query.CreatedAfter != "" || query.CreatedBefore != "" {
+		names["#createdAt"] = createdAtAttr
+	}
+	if query.CreatedAfter != "" {
+		createdAtConditions = append(createdAtConditions, "#createdAt >= :createdAfter")
+		values[":createdAfter"] = &types.AttributeValueMemberS{Value: query.CreatedAfter}
+	}
+	if query.CreatedBefore != "" {
+		createdAtConditions = append(createdAtConditions, "#createdAt <= :createdBefore")
+		values[":createdBefore"] = &types.AttributeValueMemberS{Value: query.CreatedBefore}
+	}
+
+	var items []map[string]types.AttributeValue
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	if query.LinkURL != "" {
+		names["#gsi1pk"] = "gsi1pk"
+		values[":gsi1pk"] = &types.AttributeValueMemberS{Value: query.LinkURL}
+		keyCondition := "#gsi1pk = :gsi1pk"
+		if len(createdAtConditions) == 2 {
+			keyCondition += " AND #createdAt BETWEEN :createdAfter AND :createdBefore"
+		} else if len(createdAtConditions) == 1 {
+			keyCondition += " AND " + createdAtConditions[0]
+		}
+
+		output, err := d.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(d.tableName),
+			IndexName:                 aws.String(d.gsi1Name),
+			KeyConditionExpression:    aws.String(keyCondition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ScanIndexForward:          aws.Bool(false), // most recently created first
+			ExclusiveStartKey:         startKey,
+			Limit:                     aws.Int32(int32(query.Limit)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ddb.Query: %w", err)
+		}
+		items, lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+	} else {
+		names["#type"] = "_type"
+		values[":type"] = &types.AttributeValueMemberS{Value: "ShortLink"}
+		filter := strings.Join(append([]string{"#type = :type"}, createdAtConditions...), " AND ")
+
+		output, err := d.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(d.tableName),
+			FilterExpression:          aws.String(filter),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+			Limit:                     aws.Int32(int32(query.Limit)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ddb.Scan: %w", err)
+		}
+		items, lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+	}
+
+	page := &ListPage{
+		ShortLinks: make([]*models.ShortLink, 0, len(items)),
+	}
+	for _, item := range items {
 		var av *ddbShortLinkItem
 		err = attributevalue.UnmarshalMap(item, &av)
 		if err != nil {
 			return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
 		}
-		result = append(result, av.ShortLink)
+		if query.Matches(av.ShortLink) {
+			page.ShortLinks = append(page.ShortLinks, av.ShortLink)
+		}
+	}
+
+	page.NextCursor, err = encodeDynamoDBCursor(lastEvaluatedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (d *DynamoDBStorage) listByTag(ctx context.Context, query *ListQuery, startKey map[string]types.AttributeValue) (*ListPage, error) {
+	output, err := d.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.tableName),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "pk",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: ddbTagPKPrefix + query.Tag},
+		},
+		ExclusiveStartKey: startKey,
+		Limit:             aws.Int32(int32(query.Limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ddb.Query: %w", err)
+	}
+
+	shortLinkIDs := make([]string, 0, len(output.Items))
+	for _, item := range output.Items {
+		var av *ddbShortLinkTagItem
+		err = attributevalue.UnmarshalMap(item, &av)
+		if err != nil {
+			return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
+		}
+		shortLinkIDs = append(shortLinkIDs, av.ShortLinkID)
+	}
+
+	shortLinks, err := d.batchGetByIDs(ctx, shortLinkIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{
+		ShortLinks: make([]*models.ShortLink, 0, len(shortLinks)),
+	}
+	// in the same order as the tag-index items
+	for _, shortLinkID := range shortLinkIDs {
+		shortLink := shortLinks[shortLinkID]
+		if shortLink != nil && query.Matches(shortLink) {
+			page.ShortLinks = append(page.ShortLinks, shortLink)
+		}
 	}
+
+	page.NextCursor, err = encodeDynamoDBCursor(output.LastEvaluatedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// The maximum number of keys in a single BatchGetItem request.
+const ddbMaxBatchGetKeys = 100
+
+func (d *DynamoDBStorage) batchGetByIDs(ctx context.Context, shortLinkIDs []string) (map[string]*models.ShortLink, error) {
+	result := make(map[string]*models.ShortLink, len(shortLinkIDs))
+
+	for start := 0; start < len(shortLinkIDs); start += ddbMaxBatchGetKeys {
+		end := start + ddbMaxBatchGetKeys
+		if end > len(shortLinkIDs) {
+			end = len(shortLinkIDs)
+		}
+
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, shortLinkID := range shortLinkIDs[start:end] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: shortLinkID},
+				"sk": &types.AttributeValueMemberS{Value: shortLinkID},
+			})
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{
+			d.tableName: {Keys: keys},
+		}
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > 0 {
+				// unprocessed keys are usually due to throttling
+				select {
+				case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+
+			output, err := d.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("ddb.BatchGetItem: %w", err)
+			}
+
+			for _, item := range output.Responses[d.tableName] {
+				var av *ddbShortLinkItem
+				err = attributevalue.UnmarshalMap(item, &av)
+				if err != nil {
+					return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
+				}
+				result[av.ShortLink.ID] = av.ShortLink
+			}
+
+			requestItems = output.UnprocessedKeys
+		}
+	}
+
 	return result, nil
 }
 
+// ForEach scans the whole table, with the segments of a parallel scan each
+// being scanned in their own goroutine.
+func (d *DynamoDBStorage) ForEach(ctx context.Context, fn func(*models.ShortLink) error) error {
+	ctx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
+
+	var (
+		mu       sync.Mutex // serialises calls to fn
+		wg       sync.WaitGroup
+		firstErr error
+		errOnce  sync.Once
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancelCtx()
+		})
+	}
+
+	for segment := 0; segment < d.scanSegments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+
+			paginator := dynamodb.NewScanPaginator(d.client, &dynamodb.ScanInput{
+				TableName:        aws.String(d.tableName),
+				FilterExpression: aws.String("#type = :type"),
+				ExpressionAttributeNames: map[string]string{
+					"#type": "_type",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":type": &types.AttributeValueMemberS{Value: "ShortLink"},
+				},
+				Segment:       aws.Int32(int32(segment)),
+				TotalSegments: aws.Int32(int32(d.scanSegments)),
+			})
+
+			for paginator.HasMorePages() {
+				output, err := paginator.NextPage(ctx)
+				if err != nil {
+					fail(fmt.Errorf("ddb.Scan: %w", err))
+					return
+				}
+
+				for _, item := range output.Items {
+					var av *ddbShortLinkItem
+					err = attributevalue.UnmarshalMap(item, &av)
+					if err != nil {
+						fail(fmt.Errorf("ddbAV.UnmarshalMap: %s", err))
+						return
+					}
+
+					mu.Lock()
+					if ctx.Err() == nil {
+						err = fn(av.ShortLink)
+					}
+					mu.Unlock()
+					if err != nil {
+						fail(err)
+						return
+					}
+				}
+			}
+		}(segment)
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// CreateIdempotencyRecord puts the IdempotencyRecord item unless there's an
+// unexpired one with the same key, which may not have been deleted yet, that
+// isn't an abandoned one for the same request.
+func (d *DynamoDBStorage) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	item, err := newDDBIdempotencyRecordItem(record)
+	if err != nil {
+		return err
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(pk) OR #expiresAt <= :now OR " +
+			"(attribute_not_exists(#statusCode) AND #lockedUntil <= :now AND #requestHash = :requestHash)"),
+		ExpressionAttributeNames: map[string]string{
+			"#expiresAt":   "expiresAt",
+			"#statusCode":  "statusCode",
+			"#lockedUntil": "lockedUntil",
+			"#requestHash": "requestHash",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":         &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+			":requestHash": &types.AttributeValueMemberS{Value: record.RequestHash},
+		},
+	})
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return &ErrIdempotencyRecordExists{Key: record.Key}
+		}
+		return fmt.Errorf("ddb.PutItem: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStorage) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	output, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: ddbIdempotencyRecordPKPrefix + key},
+			"sk": &types.AttributeValueMemberS{Value: ddbIdempotencyRecordPKPrefix + key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ddb.GetItem: %w", err)
+	}
+
+	var av *ddbIdempotencyRecordItem
+	err = attributevalue.UnmarshalMap(output.Item, &av)
+	if err != nil {
+		return nil, fmt.Errorf("ddbAV.UnmarshalMap: %s", err)
+	}
+	if av == nil || av.IdempotencyRecord == nil || av.IdempotencyRecord.Expired() {
+		return nil, nil
+	}
+	return av.IdempotencyRecord, nil
+}
+
+func (d *DynamoDBStorage) PutIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	item, err := newDDBIdempotencyRecordItem(record)
+	if err != nil {
+		return err
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.PutItem: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoDBStorage) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: ddbIdempotencyRecordPKPrefix + key},
+			"sk": &types.AttributeValueMemberS{Value: ddbIdempotencyRecordPKPrefix + key},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.DeleteItem: %w", err)
+	}
+	return nil
+}
+
+// IncrementQuotaUsage adds n to the `used` attribute of the QuotaUsage item,
+// creating it if needed, on the condition that it doesn't go over limit.
+func (d *DynamoDBStorage) IncrementQuotaUsage(ctx context.Context, key string, n, limit int, expiresAt time.Time) (int, error) {
+	if n > limit {
+		// the condition below would let it through for a new item
+		return 0, &ErrQuotaExceeded{Key: key, Limit: limit}
+	}
+
+	output, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: ddbQuotaUsagePKPrefix + key},
+			"sk": &types.AttributeValueMemberS{Value: ddbQuotaUsagePKPrefix + key},
+		},
+		UpdateExpression:    aws.String("ADD #used :n SET #type = :type, #ttl = :ttl"),
+		ConditionExpression: aws.String("attribute_not_exists(#used) OR #used <= :max"),
+		ExpressionAttributeNames: map[string]string{
+			"#used": "used",
+			"#type": "_type",
+			"#ttl":  "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":n":    &types.AttributeValueMemberN{Value: strconv.Itoa(n)},
+			":max":  &types.AttributeValueMemberN{Value: strconv.Itoa(limit - n)},
+			":type": &types.AttributeValueMemberS{Value: "QuotaUsage"},
+			":ttl":  &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return 0, &ErrQuotaExceeded{Key: key, Limit: limit}
+		}
+		return 0, fmt.Errorf("ddb.UpdateItem: %w", err)
+	}
+
+	var used int
+	err = attributevalue.Unmarshal(output.Attributes["used"], &used)
+	if err != nil {
+		return 0, fmt.Errorf("ddbAV.Unmarshal used: %w", err)
+	}
+	return used, nil
+}
+
+// CreateWebhookDeadLetter puts the WebhookDeadLetter item.
+func (d *DynamoDBStorage) CreateWebhookDeadLetter(ctx context.Context, deadLetter *models.WebhookDeadLetter) error {
+	item, err := attributevalue.MarshalMap(&ddbWebhookDeadLetterItem{
+		WebhookDeadLetter: deadLetter,
+		Type:              "WebhookDeadLetter",
+		PK:                ddbWebhookDeadLetterPKPrefix + deadLetter.EndpointID,
+		SK:                deadLetter.CreatedAt + "#" + deadLetter.EventID,
+	})
+	if err != nil {
+		return fmt.Errorf("ddbAV.MarshalMap: %w", err)
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.PutItem: %w", err)
+	}
+	return nil
+}
+
+// encodeDynamoDBCursor turns a LastEvaluatedKey into an opaque cursor. All key
+// attributes in the table (and GSI1) are strings.
+func encodeDynamoDBCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	strKey := make(map[string]string, len(key))
+	for name, value := range key {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("unexpected non-string key attribute %s (BUG?)", name)
+		}
+		strKey[name] = s.Value
+	}
+
+	b, err := json.Marshal(strKey)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeDynamoDBCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, &ErrInvalidCursor{Cursor: cursor}
+	}
+
+	var strKey map[string]string
+	err = json.Unmarshal(b, &strKey)
+	if err != nil || len(strKey) == 0 {
+		return nil, &ErrInvalidCursor{Cursor: cursor}
+	}
+
+	key := make(map[string]types.AttributeValue, len(strKey))
+	for name, value := range strKey {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return key, nil
+}
+
 type ddbShortLinkItem struct {
 	*models.ShortLink
 
@@ -145,6 +895,84 @@ type ddbShortLinkItem struct {
 	GSI1SK string `dynamodbav:"gsi1sk"`
 }
 
+func newDDBShortLinkItem(shortLink *models.ShortLink) *ddbShortLinkItem {
+	return &ddbShortLinkItem{
+		ShortLink: shortLink,
+		Type:      "ShortLink",
+		PK:        shortLink.ID,
+		SK:        shortLink.ID,
+		GSI1PK:    shortLink.IndexedLinkURL(),
+		GSI1SK:    shortLink.CreatedAt,
+	}
+}
+
+// ShortLinkTag items index ShortLinks by tag, with a partition per tag. They
+// only refer to the ShortLink, which is looked up separately.
+const ddbTagPKPrefix = "tag#"
+
+type ddbShortLinkTagItem struct {
+	ShortLinkID string `dynamodbav:"shortLinkId"`
+	CreatedAt   string `dynamodbav:"createdAt"`
+
+	Type string `dynamodbav:"_type"`
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+}
+
+// ShortLinkChange items live in the same partition as the ShortLink they
+// belong to, sorted by the time of the change.
+const ddbShortLinkChangeSKPrefix = "change#"
+
+type ddbShortLinkChangeItem struct {
+	*models.ShortLinkChange
+
+	Type string `dynamodbav:"_type"`
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+}
+
+// IdempotencyRecord items are on their own, expiring after their ExpiresAt.
+const ddbIdempotencyRecordPKPrefix = "idempotency#"
+
+type ddbIdempotencyRecordItem struct {
+	*models.IdempotencyRecord
+
+	Type string `dynamodbav:"_type"`
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+	TTL  int64  `dynamodbav:"ttl"`
+}
+
+func newDDBIdempotencyRecordItem(record *models.IdempotencyRecord) (*ddbIdempotencyRecordItem, error) {
+	expiry, err := time.Parse(time.RFC3339, record.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("time.Parse ExpiresAt: %w", err)
+	}
+
+	return &ddbIdempotencyRecordItem{
+		IdempotencyRecord: record,
+		Type:              "IdempotencyRecord",
+		PK:                ddbIdempotencyRecordPKPrefix + record.Key,
+		SK:                ddbIdempotencyRecordPKPrefix + record.Key,
+		TTL:               expiry.Unix(),
+	}, nil
+}
+
+// QuotaUsage items are on their own, only updated with UpdateItem.
+const ddbQuotaUsagePKPrefix = "quota#"
+
+// WebhookDeadLetter items have a partition per webhook endpoint, sorted by
+// time.
+const ddbWebhookDeadLetterPKPrefix = "webhook-dead-letter#"
+
+type ddbWebhookDeadLetterItem struct {
+	*models.WebhookDeadLetter
+
+	Type string `dynamodbav:"_type"`
+	PK   string `dynamodbav:"pk"`
+	SK   string `dynamodbav:"sk"`
+}
+
 // NewDynamoDBStorage returns an initialised `*DynamoDBStorage`.
 //
 // It checks if the DynamoDB table exists, if not it will create one first. This
@@ -169,6 +997,10 @@ func NewDynamoDBStorage(ctx context.Context, options ...func(*DynamoDBStorage))
 		s.gsi1Name = DynamoDBDefaultGSI1Name
 	}
 
+	if s.scanSegments < 1 {
+		s.scanSegments = DynamoDBDefaultScanSegments
+	}
+
 	if s.awsConfig == nil {
 		var err error
 		cfg, err := config.LoadDefaultConfig(ctx)
@@ -279,6 +1111,14 @@ func WithDynamoDBGSI1Name(gsi1Name string) func(*DynamoDBStorage) {
 	}
 }
 
+// WithDynamoDBScanSegments specifies the number of segments scanned in
+// parallel when iterating over every ShortLink.
+func WithDynamoDBScanSegments(scanSegments int) func(*DynamoDBStorage) {
+	return func(s *DynamoDBStorage) {
+		s.scanSegments = scanSegments
+	}
+}
+
 func WithDynamoDBRegion(region string) func(*DynamoDBStorage) {
 	return func(s *DynamoDBStorage) {
 		s.region = region
@@ -300,7 +1140,12 @@ func WithDynamoDBClient(client DynamoDBClient) func(*DynamoDBStorage) {
 type DynamoDBClient interface {
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, options ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, options ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
 	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
 }
@@ -2,7 +2,12 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ronny/slink/models"
 )
@@ -13,23 +18,46 @@ import (
 // MemoryStorage is intended to be used only in development or testing, NOT in
 // production.
 type MemoryStorage struct {
-	linkByID  *sync.Map
-	linkByURL *sync.Map
+	linkByID    *sync.Map
+	linkByURL   *sync.Map
+	historyByID *sync.Map
+	// expired IdempotencyRecords are only replaced, never removed
+	idempotencyRecordByKey *sync.Map
+	// expired quota usages are removed when incrementing any quota usage
+	quotaUsageByKey    map[string]*memoryQuotaUsage
+	webhookDeadLetters []*models.WebhookDeadLetter
+
+	// serialises modifications, e.g. of a ShortLink and its URL index
+	mu sync.Mutex
 }
 
-var _ Storage = (*DynamoDBStorage)(nil)
+var (
+	_ Storage                = (*MemoryStorage)(nil)
+	_ IdempotencyStore       = (*MemoryStorage)(nil)
+	_ QuotaStore             = (*MemoryStorage)(nil)
+	_ WebhookDeadLetterStore = (*MemoryStorage)(nil)
+)
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		linkByID:  &sync.Map{},
-		linkByURL: &sync.Map{},
+		linkByID:    &sync.Map{},
+		linkByURL:   &sync.Map{},
+		historyByID: &sync.Map{},
+
+		idempotencyRecordByKey: &sync.Map{},
+		quotaUsageByKey:        make(map[string]*memoryQuotaUsage),
 	}
 }
 
 func (s *MemoryStorage) Create(ctx context.Context, shortLink *models.ShortLink) error {
-	// TODO: don't overwrite existing ShortLink with the same ID
-	s.linkByID.Store(shortLink.ID, shortLink)
-	s.linkByURL.Store(shortLink.LinkURL, shortLink)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, loaded := s.linkByID.LoadOrStore(shortLink.ID, shortLink)
+	if loaded {
+		return &ErrShortLinkAlreadyExists{ShortLinkID: shortLink.ID}
+	}
+	s.linkByURL.Store(shortLink.IndexedLinkURL(), shortLink)
 	return nil
 }
 
@@ -59,3 +87,225 @@ func (s *MemoryStorage) GetByURL(ctx context.Context, linkURL string) ([]*models
 
 	return nil, nil
 }
+
+func (s *MemoryStorage) Update(ctx context.Context, shortLink *models.ShortLink, change *models.ShortLinkChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, _ := s.GetByID(ctx, shortLink.ID)
+	if current == nil || current.LinkURL != change.PreviousLinkURL || current.ExpiresAt != change.PreviousExpiresAt {
+		return &ErrShortLinkModified{ShortLinkID: shortLink.ID}
+	}
+
+	// Stored ShortLinks may be shared with callers, so never modify them in place.
+	updated := *shortLink
+	s.linkByID.Store(updated.ID, &updated)
+	if value, found := s.linkByURL.Load(current.IndexedLinkURL()); found && value == current {
+		s.linkByURL.Delete(current.IndexedLinkURL())
+	}
+	s.linkByURL.Store(updated.IndexedLinkURL(), &updated)
+
+	history, _ := s.GetHistory(ctx, shortLink.ID)
+	s.historyByID.Store(shortLink.ID, append(history, change))
+
+	return nil
+}
+
+func (s *MemoryStorage) GetHistory(ctx context.Context, shortLinkID string) ([]*models.ShortLinkChange, error) {
+	value, found := s.historyByID.Load(shortLinkID)
+	if !found {
+		return nil, nil
+	}
+
+	if history, ok := value.([]*models.ShortLinkChange); ok {
+		// copied so that appending to it never affects the stored history
+		return append([]*models.ShortLinkChange(nil), history...), nil
+	}
+
+	return nil, nil
+}
+
+// List sorts all matching ShortLinks (most recently created first) for every
+// page, which is fine for development and testing.
+//
+// The cursor is the position of the last ShortLink of the previous page in
+// that order, so the next page starts after it even when that ShortLink no
+// longer matches the query, e.g. because it has expired since.
+func (s *MemoryStorage) List(ctx context.Context, query *ListQuery) (*ListPage, error) {
+	var after *memoryCursor
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeMemoryCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shortLinks := make([]*models.ShortLink, 0)
+	s.linkByID.Range(func(key, value any) bool {
+		shortLink, ok := value.(*models.ShortLink)
+		if !ok || !query.Matches(shortLink) {
+			return true
+		}
+		if after != nil && !after.before(shortLink.CreatedAt, shortLink.ID) {
+			return true
+		}
+		shortLinks = append(shortLinks, shortLink)
+		return true
+	})
+
+	sort.Slice(shortLinks, func(i, j int) bool {
+		return memoryListOrder(shortLinks[i].CreatedAt, shortLinks[i].ID, shortLinks[j].CreatedAt, shortLinks[j].ID)
+	})
+
+	page := &ListPage{ShortLinks: shortLinks}
+	if query.Limit > 0 && len(shortLinks) > query.Limit {
+		page.ShortLinks = shortLinks[:query.Limit]
+		last := shortLinks[query.Limit-1]
+		var err error
+		page.NextCursor, err = encodeMemoryCursor(&memoryCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// memoryListOrder returns true if the ShortLink created at createdAtA with
+// idA is listed before the one created at createdAtB with idB: most recently
+// created first, then by ID.
+func memoryListOrder(createdAtA, idA, createdAtB, idB string) bool {
+	if createdAtA != createdAtB {
+		return createdAtA > createdAtB
+	}
+	return idA < idB
+}
+
+type memoryCursor struct {
+	CreatedAt string `json:"createdAt"`
+	ID        string `json:"id"`
+}
+
+// before returns true if the cursor is listed before the ShortLink created at
+// createdAt with id.
+func (c *memoryCursor) before(createdAt, id string) bool {
+	return memoryListOrder(c.CreatedAt, c.ID, createdAt, id)
+}
+
+func encodeMemoryCursor(c *memoryCursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeMemoryCursor(cursor string) (*memoryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, &ErrInvalidCursor{Cursor: cursor}
+	}
+
+	var c memoryCursor
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.ID == "" {
+		return nil, &ErrInvalidCursor{Cursor: cursor}
+	}
+	return &c, nil
+}
+
+func (s *MemoryStorage) ForEach(ctx context.Context, fn func(*models.ShortLink) error) error {
+	var err error
+	s.linkByID.Range(func(key, value any) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		if shortLink, ok := value.(*models.ShortLink); ok {
+			err = fn(shortLink)
+		}
+		return err == nil
+	})
+	return err
+}
+
+func (s *MemoryStorage) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, _ := s.GetIdempotencyRecord(ctx, record.Key)
+	if existing != nil && !(existing.Abandoned() && existing.RequestHash == record.RequestHash) {
+		return &ErrIdempotencyRecordExists{Key: record.Key}
+	}
+	s.idempotencyRecordByKey.Store(record.Key, record)
+	return nil
+}
+
+func (s *MemoryStorage) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	value, found := s.idempotencyRecordByKey.Load(key)
+	if !found {
+		return nil, nil
+	}
+
+	if record, ok := value.(*models.IdempotencyRecord); ok && !record.Expired() {
+		return record, nil
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryStorage) PutIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	s.idempotencyRecordByKey.Store(record.Key, record)
+	return nil
+}
+
+func (s *MemoryStorage) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	s.idempotencyRecordByKey.Delete(key)
+	return nil
+}
+
+type memoryQuotaUsage struct {
+	used      int
+	expiresAt time.Time
+}
+
+func (s *MemoryStorage) IncrementQuotaUsage(ctx context.Context, key string, n, limit int, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, usage := range s.quotaUsageByKey {
+		if !now.Before(usage.expiresAt) {
+			delete(s.quotaUsageByKey, k)
+		}
+	}
+
+	usage, ok := s.quotaUsageByKey[key]
+	if !ok {
+		usage = &memoryQuotaUsage{}
+	}
+	used := usage.used + n
+	if used > limit {
+		return 0, &ErrQuotaExceeded{Key: key, Limit: limit}
+	}
+	usage.used, usage.expiresAt = used, expiresAt
+	s.quotaUsageByKey[key] = usage
+	return used, nil
+}
+
+func (s *MemoryStorage) CreateWebhookDeadLetter(ctx context.Context, deadLetter *models.WebhookDeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhookDeadLetters = append(s.webhookDeadLetters, deadLetter)
+	return nil
+}
+
+// WebhookDeadLetters returns the WebhookDeadLetters created so far, oldest
+// first, e.g. for tests.
+func (s *MemoryStorage) WebhookDeadLetters() []*models.WebhookDeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*models.WebhookDeadLetter(nil), s.webhookDeadLetters...)
+}
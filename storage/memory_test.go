@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink/models"
+)
+
+func TestMemoryStorageListPagination(t *testing.T) {
+	const linkURL = "https://example.com/"
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		// changes the last ShortLink of the first page before getting the next
+		change func(*models.ShortLink)
+	}{
+		{name: "unchanged", query: ListQuery{}},
+		{name: "last short link expired", query: ListQuery{Status: ListStatusActive}, change: func(sl *models.ShortLink) { sl.ExpiresAt = "2020-01-01T00:00:00Z" }},
+		{name: "last short link updated to another URL", query: ListQuery{LinkURL: linkURL}, change: func(sl *models.ShortLink) { sl.LinkURL = "https://example.org/" }},
+		{name: "last short link's tag removed", query: ListQuery{Tag: "campaign"}, change: func(sl *models.ShortLink) { sl.Tags = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStorage()
+			ctx := context.Background()
+
+			// link1 is the most recently created, link3 and link4 at the same time
+			for i, createdAt := range []string{"2024-01-05T00:00:00Z", "2024-01-04T00:00:00Z", "2024-01-03T00:00:00Z", "2024-01-03T00:00:00Z", "2024-01-01T00:00:00Z"} {
+				err := s.Create(ctx, &models.ShortLink{ID: fmt.Sprintf("link%d", i+1), LinkURL: linkURL, CreatedAt: createdAt, Tags: []string{"campaign"}})
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+			}
+
+			query := tt.query
+			query.Limit = 2
+			var pages [][]string
+			for {
+				page, err := s.List(ctx, &query)
+				if err != nil {
+					t.Fatalf("List (page %d): %v", len(pages)+1, err)
+				}
+				var ids []string
+				for _, shortLink := range page.ShortLinks {
+					ids = append(ids, shortLink.ID)
+				}
+				pages = append(pages, ids)
+
+				if len(pages) == 1 && tt.change != nil {
+					last := *page.ShortLinks[len(page.ShortLinks)-1]
+					previous := last
+					tt.change(&last)
+					err = s.Update(ctx, &last, &models.ShortLinkChange{ShortLinkID: last.ID, PreviousLinkURL: previous.LinkURL, LinkURL: last.LinkURL})
+					if err != nil {
+						t.Fatalf("Update: %v", err)
+					}
+				}
+
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			want := [][]string{{"link1", "link2"}, {"link3", "link4"}, {"link5"}}
+			if !reflect.DeepEqual(pages, want) {
+				t.Errorf("got pages %v, want %v", pages, want)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageListInvalidCursor(t *testing.T) {
+	s := NewMemoryStorage()
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err := s.List(context.Background(), &ListQuery{Cursor: cursor})
+		var cursorErr *ErrInvalidCursor
+		if !errors.As(err, &cursorErr) {
+			t.Errorf("List with cursor %q: got error %v, want an ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestMemoryStorageIncrementQuotaUsage(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, step := range []struct {
+		key       string
+		n         int
+		want      int
+		wantErr   bool
+		expiresAt time.Time
+	}{
+		{key: "yesterday", n: 1, want: 1, expiresAt: now.Add(time.Millisecond)},
+		{key: "today", n: 2, want: 2, expiresAt: now.Add(time.Hour)},
+		{key: "today", n: 1, want: 3, expiresAt: now.Add(time.Hour)},
+		{key: "today", n: 1, wantErr: true, expiresAt: now.Add(time.Hour)},
+	} {
+		got, err := s.IncrementQuotaUsage(ctx, step.key, step.n, 3, step.expiresAt)
+		var quotaErr *ErrQuotaExceeded
+		if step.wantErr != errors.As(err, &quotaErr) || got != step.want {
+			t.Fatalf("IncrementQuotaUsage(%q, %d): got %d, %v, want %d (error: %t)", step.key, step.n, got, err, step.want, step.wantErr)
+		}
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	_, err := s.IncrementQuotaUsage(ctx, "today", 0, 3, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("IncrementQuotaUsage: %v", err)
+	}
+	if _, ok := s.quotaUsageByKey["yesterday"]; ok {
+		t.Errorf("got the expired quota usage kept, want it removed")
+	}
+}
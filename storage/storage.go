@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ronny/slink/models"
 )
@@ -10,7 +11,120 @@ import (
 type Storage interface {
 	Create(ctx context.Context, shortLink *models.ShortLink) error
 	GetByID(ctx context.Context, shortLinkID string) (*models.ShortLink, error)
-	GetByURL(ctx context.Context, linkURL string) ([]*models.ShortLink, error) // TODO: pagination of output
+	// GetByURL returns all ShortLinks whose IndexedLinkURL is linkURL (i.e.
+	// their NormalisedLinkURL), most recently created first.
+	GetByURL(ctx context.Context, linkURL string) ([]*models.ShortLink, error)
+	// Update replaces an existing ShortLink with shortLink and appends change
+	// to its history. It fails with ErrShortLinkModified when the stored
+	// ShortLink doesn't exist or its LinkURL or ExpiresAt are no longer
+	// change.PreviousLinkURL and change.PreviousExpiresAt.
+	Update(ctx context.Context, shortLink *models.ShortLink, change *models.ShortLinkChange) error
+	// GetHistory returns the changes made to a ShortLink, oldest first.
+	GetHistory(ctx context.Context, shortLinkID string) ([]*models.ShortLinkChange, error)
+	// List returns a single page of the ShortLinks matching query. A page may
+	// contain fewer than query.Limit ShortLinks (even none) while there are
+	// still more pages, only an empty NextCursor indicates the last page.
+	List(ctx context.Context, query *ListQuery) (*ListPage, error)
+	// ForEach calls fn for every ShortLink, in no particular order, stopping
+	// at the first error. fn is never called concurrently.
+	ForEach(ctx context.Context, fn func(*models.ShortLink) error) error
+}
+
+// BatchCreator is implemented by storage backends that can create many
+// ShortLinks more efficiently than one by one.
+type BatchCreator interface {
+	// CreateBatch is like Create for each of the ShortLinks, returning an error
+	// (or nil) for each ShortLink, in the same order.
+	CreateBatch(ctx context.Context, shortLinks []*models.ShortLink) []error
+}
+
+// IdempotencyStore is implemented by storage backends that can keep
+// IdempotencyRecords. Expired records are never returned, and can be replaced.
+type IdempotencyStore interface {
+	// CreateIdempotencyRecord stores a new record, failing with
+	// ErrIdempotencyRecordExists when an unexpired record with the same Key
+	// exists, unless it's abandoned and has the same RequestHash, in which case
+	// it's replaced.
+	CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	// GetIdempotencyRecord returns nil when there is no unexpired record with
+	// the key.
+	GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error)
+	// PutIdempotencyRecord stores a record, replacing any existing record with
+	// the same Key, e.g. to complete it.
+	PutIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+}
+
+// QuotaStore is implemented by storage backends that can count the usage of
+// quotas, e.g. of the short links created by each client per day, shared by
+// every process using the same storage.
+type QuotaStore interface {
+	// IncrementQuotaUsage adds n to the usage of the quota with the key, and
+	// returns the new usage. It fails with ErrQuotaExceeded, without adding
+	// anything, when the usage would go over limit. The usage can be
+	// forgotten after expiresAt, so the key should identify the quota period.
+	IncrementQuotaUsage(ctx context.Context, key string, n, limit int, expiresAt time.Time) (int, error)
+}
+
+// WebhookDeadLetterStore is implemented by storage backends that can keep
+// WebhookDeadLetters.
+type WebhookDeadLetterStore interface {
+	CreateWebhookDeadLetter(ctx context.Context, deadLetter *models.WebhookDeadLetter) error
+}
+
+type ListQuery struct {
+	// Only ShortLinks created within CreatedAfter and CreatedBefore
+	// (inclusive, both optional) are listed. Both must be in RFC3339 format in
+	// UTC, like ShortLink.CreatedAt, as they may be compared as strings.
+	CreatedAfter  string
+	CreatedBefore string
+	Status        ListStatus
+	// Only ShortLinks with exactly this IndexedLinkURL (i.e. normalised
+	// LinkURL) are listed, when not empty.
+	LinkURL string
+	// Only ShortLinks with this tag are listed, when not empty.
+	Tag string
+	// The maximum number of ShortLinks in a page.
+	Limit int
+	// The NextCursor of the previous page, or empty for the first page.
+	Cursor string
+}
+
+type ListStatus string
+
+const (
+	ListStatusAny     ListStatus = ""
+	ListStatusActive  ListStatus = "active"
+	ListStatusExpired ListStatus = "expired"
+)
+
+// Matches returns true if shortLink's CreatedAt and status match the query.
+func (q *ListQuery) Matches(shortLink *models.ShortLink) bool {
+	if q.CreatedAfter != "" && shortLink.CreatedAt < q.CreatedAfter {
+		return false
+	}
+	if q.CreatedBefore != "" && shortLink.CreatedAt > q.CreatedBefore {
+		return false
+	}
+	if q.LinkURL != "" && shortLink.IndexedLinkURL() != q.LinkURL {
+		return false
+	}
+	if q.Tag != "" && !shortLink.HasTag(q.Tag) {
+		return false
+	}
+
+	switch q.Status {
+	case ListStatusActive:
+		return !shortLink.Expired()
+	case ListStatusExpired:
+		return shortLink.Expired()
+	}
+	return true
+}
+
+type ListPage struct {
+	ShortLinks []*models.ShortLink `json:"shortLinks"`
+	NextCursor string              `json:"nextCursor,omitempty"`
 }
 
 type ErrShortLinkAlreadyExists struct {
@@ -20,3 +134,36 @@ type ErrShortLinkAlreadyExists struct {
 func (e *ErrShortLinkAlreadyExists) Error() string {
 	return fmt.Sprintf("ErrShortLinkAlreadyExists: ShortLink with ID %s already exists", e.ShortLinkID)
 }
+
+type ErrShortLinkModified struct {
+	ShortLinkID string
+}
+
+func (e *ErrShortLinkModified) Error() string {
+	return fmt.Sprintf("ErrShortLinkModified: ShortLink with ID %s is missing or has been modified concurrently", e.ShortLinkID)
+}
+
+type ErrInvalidCursor struct {
+	Cursor string
+}
+
+func (e *ErrInvalidCursor) Error() string {
+	return fmt.Sprintf("ErrInvalidCursor: %q is not a valid cursor", e.Cursor)
+}
+
+type ErrIdempotencyRecordExists struct {
+	Key string
+}
+
+func (e *ErrIdempotencyRecordExists) Error() string {
+	return fmt.Sprintf("ErrIdempotencyRecordExists: IdempotencyRecord with key %s already exists", e.Key)
+}
+
+type ErrQuotaExceeded struct {
+	Key   string
+	Limit int
+}
+
+func (e *ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("ErrQuotaExceeded: quota %s of %d has been used up", e.Key, e.Limit)
+}
@@ -0,0 +1,130 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultReloadInterval is how often the certificate and key files are checked
+// for changes.
+const DefaultReloadInterval = 10 * time.Second
+
+// CertReloader holds a certificate and key loaded from PEM files, which are
+// reloaded when they change, e.g. when they're renewed by cert-manager.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both certFile and keyFile are required")
+	}
+
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload replaces the certificate in use with the one in the files. The
+// certificate in use is kept when the files are invalid, e.g. when only one of
+// them has been replaced so far.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls.LoadX509KeyPair %s %s: %w", r.certFile, r.keyFile, err)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate is meant to be used as the GetCertificate of a tls.Config.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Watch reloads the certificate when the modification time or size of either
+// file changes, checking every interval until stop is closed.
+func (r *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	lastStats := r.stats()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		stats := r.stats()
+		if stats == lastStats {
+			continue
+		}
+		lastStats = stats
+
+		err := r.Reload()
+		if err != nil {
+			log.Error().Err(err).Msg("reloading the TLS certificate failed, keeping the current one")
+			continue
+		}
+		log.Info().Str("certFile", r.certFile).Msg("TLS certificate reloaded")
+	}
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func (r *CertReloader) stats() [2]fileStat {
+	var stats [2]fileStat
+	for i, filename := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			// a zero fileStat, so the reload is tried again when it's back
+			continue
+		}
+		stats[i] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stats
+}
+
+// NewServerConfig returns the TLS configuration of a server presenting the
+// certificate of the reloader.
+func NewServerConfig(r *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// LoadCertPool reads a bundle of PEM encoded CA certificates.
+func LoadCertPool(filename string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no PEM certificates in %s", filename)
+	}
+	return pool, nil
+}
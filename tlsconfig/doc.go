@@ -0,0 +1,3 @@
+// Package tlsconfig provides the TLS configuration of the public and admin
+// servers, with certificates that are reloaded when their files change.
+package tlsconfig
@@ -10,15 +10,18 @@ import (
 )
 
 type ShortLinkLookupPayload struct {
-	ShortLinkID        string            `json:"shortLinkId"`
-	ShortLinkFound     bool              `json:"shortLinkFound"`
-	ShortLinkExpired   bool              `json:"shortLinkExpired"`
-	TargetURL          string            `json:"targetUrl"` // not necessarily the same as the actual redirect URL (`ResponseLocation`)
-	RequestHost        string            `json:"requestHost"`
-	RequestHeaders     map[string]string `json:"requestHeaders"`
-	RequestedAt        string            `json:"requestedAt"`
-	ResponseStatusCode int               `json:"responseStatusCode"`
-	ResponseLocation   string            `json:"responseLocation"`
+	ShortLinkID      string `json:"shortLinkId"`
+	ShortLinkFound   bool   `json:"shortLinkFound"`
+	ShortLinkExpired bool   `json:"shortLinkExpired"`
+	// ShortLinkNotYetActive is true when the ShortLink's ActivatesAt is in the
+	// future, so it redirected to its coming soon URL (or the fallback URL).
+	ShortLinkNotYetActive bool              `json:"shortLinkNotYetActive"`
+	TargetURL             string            `json:"targetUrl"` // not necessarily the same as the actual redirect URL (`ResponseLocation`)
+	RequestHost           string            `json:"requestHost"`
+	RequestHeaders        map[string]string `json:"requestHeaders"`
+	RequestedAt           string            `json:"requestedAt"`
+	ResponseStatusCode    int               `json:"responseStatusCode"`
+	ResponseLocation      string            `json:"responseLocation"`
 }
 
 type PayloadBuilder struct {
@@ -45,8 +48,6 @@ func (pb *PayloadBuilder) BuildShortLinkLookupPayload(
 	payload := &ShortLinkLookupPayload{
 		ShortLinkID:        shortLinkID,
 		ShortLinkFound:     shortLink != nil,
-		ShortLinkExpired:   shortLink.Expired(),
-		TargetURL:          shortLink.LinkURL,
 		RequestHost:        r.Host,
 		RequestHeaders:     make(map[string]string),
 		RequestedAt:        time.Now().Format(time.RFC3339),
@@ -54,6 +55,12 @@ func (pb *PayloadBuilder) BuildShortLinkLookupPayload(
 		ResponseLocation:   responseLocation,
 	}
 
+	if shortLink != nil {
+		payload.ShortLinkExpired = shortLink.Expired()
+		payload.ShortLinkNotYetActive = shortLink.NotYetActive()
+		payload.TargetURL = shortLink.LinkURL
+	}
+
 	for _, key := range pb.trustedHeaders {
 		value := r.Header.Get(key)
 		// basically emulating "json:omitempty"
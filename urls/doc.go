@@ -0,0 +1,3 @@
+// Package urls covers the normalisation of link URLs, so that equivalent URLs
+// are looked up as the same URL
+package urls
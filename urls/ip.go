@@ -0,0 +1,74 @@
+package urls
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseIP parses a URL host name as an IP address like `net.ParseIP`, also
+// accepting the IPv4 forms that browsers do (see the IPv4 parser of the WHATWG
+// URL standard), e.g. `2130706433`, `0x7f000001`, `127.1` or `0177.0.0.1` for
+// `127.0.0.1`. It returns nil when the host name isn't an IP address.
+func ParseIP(hostname string) net.IP {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return ip
+	}
+	return parseWHATWGIPv4(hostname)
+}
+
+// parseWHATWGIPv4 parses 1 to 4 numbers separated by `.` (with an optional
+// trailing `.`), each in decimal, in octal with a leading `0`, or in hex with a
+// leading `0x`. The last number fills the remaining bytes of the address.
+func parseWHATWGIPv4(hostname string) net.IP {
+	parts := strings.Split(hostname, ".")
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > 4 {
+		return nil
+	}
+
+	var ipv4 uint64
+	for i, part := range parts {
+		n, ok := parseWHATWGIPv4Number(part)
+		if !ok {
+			return nil
+		}
+
+		if i < len(parts)-1 {
+			if n > 255 {
+				return nil
+			}
+			ipv4 |= n << (8 * (3 - i))
+			continue
+		}
+
+		if n >= 1<<(8*(5-len(parts))) {
+			return nil
+		}
+		ipv4 |= n
+	}
+
+	return net.IPv4(byte(ipv4>>24), byte(ipv4>>16), byte(ipv4>>8), byte(ipv4))
+}
+
+func parseWHATWGIPv4Number(part string) (uint64, bool) {
+	if part == "" {
+		return 0, false
+	}
+
+	base := 10
+	switch {
+	case len(part) >= 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		part, base = part[2:], 16
+		if part == "" {
+			return 0, true
+		}
+	case len(part) >= 2 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+
+	n, err := strconv.ParseUint(part, base, 32)
+	return n, err == nil
+}
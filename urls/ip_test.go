@@ -0,0 +1,51 @@
+package urls
+
+import (
+	"testing"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"127.0.0.1", "127.0.0.1"},
+		{"::1", "::1"},
+		{"2130706433", "127.0.0.1"},
+		{"0x7f000001", "127.0.0.1"},
+		{"0X7F000001", "127.0.0.1"},
+		{"127.1", "127.0.0.1"},
+		{"127.0.1", "127.0.0.1"},
+		{"10.1.65535", "10.1.255.255"},
+		{"0177.0.0.1", "127.0.0.1"},
+		{"0x7f.0.0.0x1", "127.0.0.1"},
+		{"0x.0.0.1", "0.0.0.1"},
+		{"127.0.0.1.", "127.0.0.1"},
+		{"0", "0.0.0.0"},
+		{"4294967295", "255.255.255.255"},
+
+		{"4294967296", ""},
+		{"127.16777216", ""},
+		{"256.0.0.1", ""},
+		{"1.2.3.4.5", ""},
+		{"08.0.0.1", ""},
+		{"0xg.0.0.1", ""},
+		{"127..1", ""},
+		{"+1.0.0.1", ""},
+		{"1_0.0.0.1", ""},
+		{"example.com", ""},
+		{"1.example", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			var got string
+			if ip := ParseIP(tt.hostname); ip != nil {
+				got = ip.String()
+			}
+			if got != tt.want {
+				t.Errorf("ParseIP(%q): got %q, want %q", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
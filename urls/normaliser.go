@@ -0,0 +1,7 @@
+package urls
+
+// Normaliser returns the normalised form of a link URL, which is the same for
+// every equivalent link URL.
+type Normaliser interface {
+	Normalise(linkURL string) (string, error)
+}
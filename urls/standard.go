@@ -0,0 +1,174 @@
+package urls
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultStrippedQueryParams are the query parameters used for tracking,
+// which don't change what a URL points to. A trailing `*` matches any suffix.
+var DefaultStrippedQueryParams = []string{"utm_*", "fbclid", "gclid"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// hostProfile is idna.Lookup without the STD3 rules, which reject e.g. the `_`
+// found in the host names of some (non-public) URLs.
+var hostProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// ASCIIHost converts an internationalised host name to punycode (IDNA) as
+// StandardNormaliser does, e.g. `münchen.de` to `xn--mnchen-3ya.de`.
+func ASCIIHost(hostname string) (string, error) {
+	return hostProfile.ToASCII(hostname)
+}
+
+// StandardNormaliser normalises link URLs by:
+// - lowercasing the scheme and host
+// - converting internationalised host names to punycode (IDNA)
+// - converting the other IPv4 address forms browsers accept (e.g. `127.1`) to
+// dotted decimal
+// - removing the default port of the scheme
+// - adding the `/` path to URLs with a host and no path
+// - removing the stripped query parameters (e.g. `utm_source`)
+// - sorting the query parameters by name, keeping the order of the values of
+// repeated ones
+//
+// The path and fragment are case-sensitive, and kept as they are.
+type StandardNormaliser struct {
+	strippedQueryParams []string
+}
+
+func (n *StandardNormaliser) Normalise(linkURL string) (string, error) {
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	if u.Host != "" {
+		u.Host, err = normaliseHost(u.Scheme, u.Hostname(), u.Port())
+		if err != nil {
+			return "", err
+		}
+		if u.Path == "" && u.Opaque == "" {
+			u.Path = "/"
+		}
+	}
+
+	u.RawQuery = n.normaliseQuery(u.RawQuery)
+	if u.RawQuery == "" {
+		u.ForceQuery = false
+	}
+
+	return u.String(), nil
+}
+
+func normaliseHost(scheme, hostname, port string) (string, error) {
+	hostname = strings.ToLower(hostname)
+	if net.ParseIP(hostname) == nil {
+		if ip := parseWHATWGIPv4(hostname); ip != nil {
+			hostname = ip.String()
+		} else {
+			var err error
+			hostname, err = ASCIIHost(hostname)
+			if err != nil {
+				return "", fmt.Errorf("ASCIIHost: %w", err)
+			}
+		}
+	}
+
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	if port != "" {
+		return net.JoinHostPort(hostname, port), nil
+	}
+	if strings.Contains(hostname, ":") {
+		return "[" + hostname + "]", nil
+	}
+	return hostname, nil
+}
+
+// normaliseQuery sorts (and strips) the raw `name=value` pairs, so that their
+// encoding is kept as it is.
+func (n *StandardNormaliser) normaliseQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct {
+		name string
+		pair string
+	}
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.stripped(name) {
+			continue
+		}
+		params = append(params, param{name: name, pair: pair})
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (n *StandardNormaliser) stripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range n.strippedQueryParams {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func NewStandardNormaliser(options ...func(*StandardNormaliser)) *StandardNormaliser {
+	n := &StandardNormaliser{
+		strippedQueryParams: DefaultStrippedQueryParams,
+	}
+
+	for _, option := range options {
+		option(n)
+	}
+
+	return n
+}
+
+// WithStrippedQueryParams specifies the (case-insensitive) names of the query
+// parameters removed from normalised URLs instead of
+// DefaultStrippedQueryParams, a trailing `*` matching any suffix. No query
+// parameters are removed without any.
+func WithStrippedQueryParams(params ...string) func(*StandardNormaliser) {
+	return func(n *StandardNormaliser) {
+		n.strippedQueryParams = make([]string, len(params))
+		for i, param := range params {
+			n.strippedQueryParams[i] = strings.ToLower(param)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package urls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStandardNormaliserNormalise(t *testing.T) {
+	tests := []struct {
+		name    string
+		linkURL string
+		want    string
+		wantErr string
+	}{
+		{name: "lowercased scheme and host, empty path", linkURL: "HTTPS://Example.COM", want: "https://example.com/"},
+		{name: "path and fragment kept", linkURL: "https://example.com/Path/To#Frag", want: "https://example.com/Path/To#Frag"},
+		{name: "empty query", linkURL: "https://example.com/?", want: "https://example.com/"},
+		{name: "not hierarchical", linkURL: "mailto:someone@example.com", want: "mailto:someone@example.com"},
+
+		{name: "default http port", linkURL: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "default https port", linkURL: "https://example.com:443/a", want: "https://example.com/a"},
+		{name: "http port with https", linkURL: "https://example.com:80/a", want: "https://example.com:80/a"},
+		{name: "other port", linkURL: "http://example.com:8080/a", want: "http://example.com:8080/a"},
+
+		{name: "sorted query", linkURL: "https://example.com/?b=2&a=1&c=3", want: "https://example.com/?a=1&b=2&c=3"},
+		{name: "repeated query parameter keeps its order", linkURL: "https://example.com/?b=2&a=1&a=0", want: "https://example.com/?a=1&a=0&b=2"},
+		{name: "query encoding kept", linkURL: "https://example.com/?q=a%20b&p=%2F", want: "https://example.com/?p=%2F&q=a%20b"},
+		{name: "utm parameters stripped", linkURL: "https://example.com/?utm_source=x&b=1&utm_campaign=y", want: "https://example.com/?b=1"},
+		{name: "uppercase utm parameters stripped", linkURL: "https://example.com/?UTM_Medium=y&b=1", want: "https://example.com/?b=1"},
+		{name: "fbclid and gclid stripped", linkURL: "https://example.com/?fbclid=z&gclid=q&b=1", want: "https://example.com/?b=1"},
+		{name: "only stripped parameters", linkURL: "https://example.com/a?utm_source=x", want: "https://example.com/a"},
+		{name: "utm prefix only", linkURL: "https://example.com/?utm=1&utmsource=2", want: "https://example.com/?utm=1&utmsource=2"},
+
+		{name: "IDN host", linkURL: "https://münchen.de/a", want: "https://xn--mnchen-3ya.de/a"},
+		{name: "uppercase IDN host with default port", linkURL: "https://MÜNCHEN.de:443/", want: "https://xn--mnchen-3ya.de/"},
+		{name: "punycode host", linkURL: "https://xn--mnchen-3ya.de/", want: "https://xn--mnchen-3ya.de/"},
+		{name: "invalid punycode host", linkURL: "https://xn--zz.com/", wantErr: "idna"},
+
+		{name: "IPv6 host with default port", linkURL: "https://[::1]:443/a", want: "https://[::1]/a"},
+		{name: "uppercase IPv6 host with port", linkURL: "http://[2001:DB8::1]:8080/a", want: "http://[2001:db8::1]:8080/a"},
+		{name: "IPv6 host", linkURL: "http://[2001:db8::1]", want: "http://[2001:db8::1]/"},
+		{name: "IPv4 host with default port", linkURL: "https://192.168.1.1:443/", want: "https://192.168.1.1/"},
+		{name: "hex IPv4 host", linkURL: "http://0x7f000001/a", want: "http://127.0.0.1/a"},
+		{name: "short octal IPv4 host with port", linkURL: "http://0177.1:8080/a", want: "http://127.0.0.1:8080/a"},
+		{name: "malformed IPv6 host", linkURL: "http://[::1", wantErr: "missing ']'"},
+
+		{name: "underscore in host", linkURL: "https://my_host.example.com/a", want: "https://my_host.example.com/a"},
+		{name: "underscore in IDN host", linkURL: "https://My_München.example.com/a", want: "https://xn--my_mnchen-t9a.example.com/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStandardNormaliser().Normalise(tt.linkURL)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Normalise(%q): got %q, %v, want an error containing %q", tt.linkURL, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalise(%q): %v", tt.linkURL, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalise(%q): got %q, want %q", tt.linkURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithStrippedQueryParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{"default", nil, "https://example.com/?ref=a&session=b"},
+		{"none", []string{}, "https://example.com/?fbclid=z&ref=a&session=b&utm_source=x"},
+		{"exact and wildcard, case-insensitive", []string{"REF", "Sess*"}, "https://example.com/?fbclid=z&utm_source=x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []func(*StandardNormaliser)
+			if tt.params != nil {
+				options = append(options, WithStrippedQueryParams(tt.params...))
+			}
+
+			linkURL := "https://example.com/?utm_source=x&session=b&ref=a&fbclid=z"
+			got, err := NewStandardNormaliser(options...).Normalise(linkURL)
+			if err != nil {
+				t.Fatalf("Normalise(%q): %v", linkURL, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalise(%q): got %q, want %q", linkURL, got, tt.want)
+			}
+		})
+	}
+}
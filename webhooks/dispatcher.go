@@ -0,0 +1,435 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/debug"
+	"github.com/ronny/slink/models"
+	"github.com/ronny/slink/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultMaxAttempts is how many times a delivery is attempted before
+	// it's given up on, which is about 15 minutes with the default backoff.
+	DefaultMaxAttempts = 10
+	// DefaultInitialBackoff is the wait before the first retry, doubled
+	// before every next one (with some jitter).
+	DefaultInitialBackoff = 1 * time.Second
+	// DefaultMaxBackoff caps the wait between retries.
+	DefaultMaxBackoff = 5 * time.Minute
+	// DefaultTimeout is the time limit of each attempt.
+	DefaultTimeout = 10 * time.Second
+	// DefaultQueueSize is the number of deliveries waiting to be attempted,
+	// beyond which new ones are dead-lettered right away.
+	DefaultQueueSize = 1000
+	// DefaultWorkers is the number of deliveries attempted concurrently.
+	DefaultWorkers = 4
+	// the time limit for creating a dead letter
+	deadLetterTimeout = 5 * time.Second
+	// the maximum size of a response body that's read (and discarded), so
+	// that the connection can be reused
+	maxResponseBodyBytes = 64 << 10
+)
+
+// Dispatcher delivers the Events of a Slink to the Endpoints that want them,
+// see slink.WithEventListener.
+//
+// Deliveries are queued and attempted in the background. An attempt succeeds
+// with any 2xx response, failed ones are retried with exponential backoff up
+// to maxAttempts times. Deliveries that are given up on are recorded in the
+// dead letter store (if any) and logged.
+type Dispatcher struct {
+	endpoints       []*Endpoint
+	client          *http.Client
+	maxAttempts     int
+	initialBackoff  time.Duration
+	maxBackoff      time.Duration
+	queueSize       int
+	workers         int
+	deadLetterStore storage.WebhookDeadLetterStore
+
+	queue chan *delivery
+	// guards closed, queue sends and retries
+	mu      sync.Mutex
+	closed  bool
+	retries map[*delivery]*time.Timer
+	// workers and dead letters being created
+	wg sync.WaitGroup
+}
+
+var _ slink.EventListener = (*Dispatcher)(nil)
+
+// delivery is an Event to be delivered to an Endpoint.
+type delivery struct {
+	endpoint  *Endpoint
+	eventID   string
+	eventType string
+	body      []byte
+	attempts  int
+	lastError string
+}
+
+// payload is the JSON body of deliveries.
+type payload struct {
+	ID string `json:"id"`
+	*slink.Event
+}
+
+// HandleEvent queues the delivery of the event to every Endpoint that wants
+// it.
+func (d *Dispatcher) HandleEvent(ctx context.Context, event *slink.Event) {
+	eventID := newEventID()
+
+	body, err := json.Marshal(&payload{ID: eventID, Event: event})
+	if err != nil {
+		log.Error().Err(err).Str("eventType", event.Type).Msg("webhooks: json.Marshal event failed")
+		return
+	}
+
+	for _, endpoint := range d.endpoints {
+		if !endpoint.wants(event.Type) {
+			continue
+		}
+		d.enqueue(&delivery{
+			endpoint:  endpoint,
+			eventID:   eventID,
+			eventType: event.Type,
+			body:      body,
+		})
+	}
+}
+
+// enqueue queues the delivery without waiting, dead-lettering it when the
+// queue is full or the Dispatcher is closed.
+func (d *Dispatcher) enqueue(dl *delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		d.deadLetterLocked(dl, "the dispatcher was closed before the delivery was attempted")
+		return
+	}
+
+	select {
+	case d.queue <- dl:
+	default:
+		d.deadLetterLocked(dl, "the delivery queue is full")
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for dl := range d.queue {
+		err := d.attempt(dl)
+		if err == nil {
+			debug.WebhookDeliveries().WithLabelValues(dl.endpoint.ID, "delivered").Inc()
+			continue
+		}
+
+		dl.lastError = err.Error()
+		log.Warn().
+			Err(err).
+			Str("endpointID", dl.endpoint.ID).
+			Str("eventID", dl.eventID).
+			Int("attempts", dl.attempts).
+			Msg("webhooks: delivery attempt failed")
+
+		if dl.attempts >= d.maxAttempts {
+			d.mu.Lock()
+			d.deadLetterLocked(dl, dl.lastError)
+			d.mu.Unlock()
+			continue
+		}
+
+		debug.WebhookDeliveries().WithLabelValues(dl.endpoint.ID, "retried").Inc()
+		d.scheduleRetry(dl)
+	}
+}
+
+// attempt POSTs the delivery to its endpoint once.
+func (d *Dispatcher) attempt(dl *delivery) error {
+	dl.attempts++
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), d.client.Timeout)
+	defer cancelCtx()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.endpoint.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "slink-webhooks")
+	req.Header.Set(IDHeader, dl.eventID)
+	req.Header.Set(TypeHeader, dl.eventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(dl.endpoint.Secret, timestamp, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBodyBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// scheduleRetry queues the delivery again after its backoff.
+func (d *Dispatcher) scheduleRetry(dl *delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		d.deadLetterLocked(dl, dl.lastError)
+		return
+	}
+
+	d.retries[dl] = time.AfterFunc(d.backoff(dl.attempts), func() {
+		d.mu.Lock()
+		_, pending := d.retries[dl]
+		delete(d.retries, dl)
+		d.mu.Unlock()
+
+		// not pending when Close has dead-lettered it already
+		if pending {
+			d.enqueue(dl)
+		}
+	})
+}
+
+// backoff returns the wait before the retry after the attempts so far,
+// doubling from initialBackoff up to maxBackoff, plus up to 25% jitter.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.initialBackoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.maxBackoff {
+		backoff = d.maxBackoff
+	}
+	if jitter := int64(backoff / 4); jitter > 0 {
+		backoff += time.Duration(mathrand.Int63n(jitter))
+	}
+	return backoff
+}
+
+// deadLetterLocked gives up on the delivery, creating its dead letter in the
+// background. d.mu must be held.
+func (d *Dispatcher) deadLetterLocked(dl *delivery, reason string) {
+	debug.WebhookDeliveries().WithLabelValues(dl.endpoint.ID, "dead_lettered").Inc()
+
+	deadLetter := &models.WebhookDeadLetter{
+		EndpointID: dl.endpoint.ID,
+		URL:        dl.endpoint.URL,
+		EventID:    dl.eventID,
+		EventType:  dl.eventType,
+		Payload:    string(dl.body),
+		Attempts:   dl.attempts,
+		LastError:  reason,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Error().
+		Str("endpointID", deadLetter.EndpointID).
+		Str("eventID", deadLetter.EventID).
+		Str("eventType", deadLetter.EventType).
+		Int("attempts", deadLetter.Attempts).
+		Str("lastError", deadLetter.LastError).
+		Msg("webhooks: giving up on delivery")
+
+	if d.deadLetterStore == nil {
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ctx, cancelCtx := context.WithTimeout(context.Background(), deadLetterTimeout)
+		defer cancelCtx()
+
+		err := d.deadLetterStore.CreateWebhookDeadLetter(ctx, deadLetter)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("endpointID", deadLetter.EndpointID).
+				Str("eventID", deadLetter.EventID).
+				Str("payload", deadLetter.Payload).
+				Msg("webhooks: CreateWebhookDeadLetter failed")
+		}
+	}()
+}
+
+// Close stops accepting Events, and waits until the queued deliveries have
+// been attempted once more, or until ctx is done. Deliveries waiting to be
+// retried, or failing their last attempt, are dead-lettered.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for dl, timer := range d.retries {
+			timer.Stop()
+			delete(d.retries, dl)
+			d.deadLetterLocked(dl, dl.lastError)
+		}
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("webhooks: deliveries still in progress: %w", ctx.Err())
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewDispatcher validates the endpoints and starts the workers delivering to
+// them, until Close.
+func NewDispatcher(endpoints []Endpoint, options ...func(*Dispatcher)) (*Dispatcher, error) {
+	d := &Dispatcher{
+		client:         &http.Client{Timeout: DefaultTimeout},
+		maxAttempts:    DefaultMaxAttempts,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		queueSize:      DefaultQueueSize,
+		workers:        DefaultWorkers,
+		retries:        make(map[*delivery]*time.Timer),
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	if len(endpoints) == 0 {
+		return nil, errors.New("missing endpoints")
+	}
+	seenIDs := make(map[string]bool, len(endpoints))
+	for i := range endpoints {
+		endpoint := endpoints[i]
+		err := endpoint.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook endpoint %d: %w", i, err)
+		}
+		if seenIDs[endpoint.ID] {
+			return nil, fmt.Errorf("duplicate webhook endpoint id %s", endpoint.ID)
+		}
+		seenIDs[endpoint.ID] = true
+		d.endpoints = append(d.endpoints, &endpoint)
+	}
+
+	if d.maxAttempts < 1 {
+		return nil, errors.New("maxAttempts must be at least 1")
+	}
+	if d.initialBackoff <= 0 || d.maxBackoff < d.initialBackoff {
+		return nil, errors.New("initialBackoff must be positive and not more than maxBackoff")
+	}
+	if d.client.Timeout <= 0 {
+		return nil, errors.New("the HTTP client must have a timeout")
+	}
+	if d.queueSize < 1 || d.workers < 1 {
+		return nil, errors.New("queueSize and workers must be at least 1")
+	}
+
+	d.queue = make(chan *delivery, d.queueSize)
+	d.wg.Add(d.workers)
+	for i := 0; i < d.workers; i++ {
+		go d.work()
+	}
+
+	return d, nil
+}
+
+// WithDispatcherHTTPClient specifies the HTTP client of the deliveries, whose
+// Timeout is the time limit of each attempt.
+func WithDispatcherHTTPClient(client *http.Client) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithDispatcherTimeout specifies the time limit of each attempt.
+func WithDispatcherTimeout(timeout time.Duration) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		client := *d.client
+		client.Timeout = timeout
+		d.client = &client
+	}
+}
+
+// WithDispatcherMaxAttempts specifies how many times a delivery is attempted
+// before it's dead-lettered.
+func WithDispatcherMaxAttempts(maxAttempts int) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+	}
+}
+
+// WithDispatcherBackoff specifies the wait before the first retry, and the
+// maximum wait between retries.
+func WithDispatcherBackoff(initialBackoff, maxBackoff time.Duration) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.initialBackoff = initialBackoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithDispatcherQueueSize specifies the number of deliveries that can wait to
+// be attempted.
+func WithDispatcherQueueSize(queueSize int) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.queueSize = queueSize
+	}
+}
+
+// WithDispatcherWorkers specifies the number of deliveries attempted
+// concurrently.
+func WithDispatcherWorkers(workers int) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.workers = workers
+	}
+}
+
+// WithDispatcherDeadLetterStore specifies where the deliveries that are given
+// up on are recorded, e.g. the slink storage when it implements
+// `storage.WebhookDeadLetterStore`. Without one, they're only logged.
+func WithDispatcherDeadLetterStore(store storage.WebhookDeadLetterStore) func(*Dispatcher) {
+	return func(d *Dispatcher) {
+		d.deadLetterStore = store
+	}
+}
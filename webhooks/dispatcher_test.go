@@ -0,0 +1,213 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ronny/slink"
+	"github.com/ronny/slink/storage"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// receiver is an httptest webhook endpoint, responding with the status codes
+// in order (and then 204), and keeping the verified deliveries.
+type receiver struct {
+	t *testing.T
+
+	mu          sync.Mutex
+	statusCodes []int
+	attempts    int
+	deliveries  []map[string]any
+	delivered   chan struct{}
+}
+
+func newReceiver(t *testing.T, statusCodes ...int) (*receiver, *httptest.Server) {
+	rcv := &receiver{t: t, statusCodes: statusCodes, delivered: make(chan struct{}, 10)}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+	return rcv, srv
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	err := Verify(testSecret, r.Header, body, time.Now())
+	if err != nil {
+		rcv.t.Errorf("Verify: %v", err)
+	}
+
+	rcv.attempts++
+	if len(rcv.statusCodes) > 0 {
+		statusCode := rcv.statusCodes[0]
+		rcv.statusCodes = rcv.statusCodes[1:]
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	var delivery map[string]any
+	err = json.Unmarshal(body, &delivery)
+	if err != nil {
+		rcv.t.Errorf("json.Unmarshal: %v", err)
+	}
+	if delivery["id"] != r.Header.Get(IDHeader) || delivery["type"] != r.Header.Get(TypeHeader) {
+		rcv.t.Errorf("the id and type of %s don't match the headers %v", body, r.Header)
+	}
+	rcv.deliveries = append(rcv.deliveries, delivery)
+	w.WriteHeader(http.StatusNoContent)
+	rcv.delivered <- struct{}{}
+}
+
+func newTestDispatcher(t *testing.T, url string, options ...func(*Dispatcher)) *Dispatcher {
+	t.Helper()
+
+	options = append([]func(*Dispatcher){WithDispatcherBackoff(time.Millisecond, 10*time.Millisecond)}, options...)
+	d, err := NewDispatcher([]Endpoint{{ID: "test", URL: url, Secret: testSecret}}, options...)
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	t.Cleanup(func() { d.Close(context.Background()) })
+	return d
+}
+
+func waitForDelivery(t *testing.T, rcv *receiver) {
+	t.Helper()
+
+	select {
+	case <-rcv.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+	}
+}
+
+func TestDispatcherDeliversSlinkEvents(t *testing.T) {
+	ctx := context.Background()
+	rcv, srv := newReceiver(t)
+	d := newTestDispatcher(t, srv.URL)
+
+	s, err := slink.NewSlink(ctx,
+		slink.WithStorage(storage.NewMemoryStorage()),
+		slink.WithEventListener(d),
+	)
+	if err != nil {
+		t.Fatalf("NewSlink: %v", err)
+	}
+
+	shortLink, err := s.CreateShortLink(ctx, &slink.CreateInput{LinkURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("CreateShortLink: %v", err)
+	}
+	waitForDelivery(t, rcv)
+
+	_, err = s.ExpireShortLink(ctx, shortLink.ID, &slink.ExpireInput{Reason: "test"})
+	if err != nil {
+		t.Fatalf("ExpireShortLink: %v", err)
+	}
+	waitForDelivery(t, rcv)
+
+	err = d.Close(ctx)
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	wantTypes := []string{slink.EventShortLinkCreated, slink.EventShortLinkExpired}
+	for i, delivery := range rcv.deliveries {
+		if delivery["type"] != wantTypes[i] {
+			t.Errorf("delivery %d: got type %v, want %s", i, delivery["type"], wantTypes[i])
+		}
+		if got := delivery["shortLink"].(map[string]any)["id"]; got != shortLink.ID {
+			t.Errorf("delivery %d: got short link %v, want %s", i, got, shortLink.ID)
+		}
+	}
+	if change, _ := rcv.deliveries[1]["change"].(map[string]any); change["reason"] != "test" {
+		t.Errorf("got change %v, want the reason test", rcv.deliveries[1]["change"])
+	}
+}
+
+func TestDispatcherRetriesFailedDeliveries(t *testing.T) {
+	rcv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	d := newTestDispatcher(t, srv.URL)
+
+	d.HandleEvent(context.Background(), &slink.Event{Type: slink.EventShortLinkCreated})
+	waitForDelivery(t, rcv)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	if rcv.attempts != 3 {
+		t.Errorf("got %d attempts, want 3", rcv.attempts)
+	}
+}
+
+func TestDispatcherDeadLettersFailingDeliveries(t *testing.T) {
+	ctx := context.Background()
+	rcv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	store := storage.NewMemoryStorage()
+	d := newTestDispatcher(t, srv.URL,
+		WithDispatcherMaxAttempts(2),
+		WithDispatcherDeadLetterStore(store),
+	)
+
+	d.HandleEvent(ctx, &slink.Event{Type: slink.EventShortLinkUpdated})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.WebhookDeadLetters()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err := d.Close(ctx)
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	deadLetters := store.WebhookDeadLetters()
+	if len(deadLetters) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(deadLetters))
+	}
+	deadLetter := deadLetters[0]
+	if deadLetter.EndpointID != "test" || deadLetter.EventType != slink.EventShortLinkUpdated || deadLetter.Attempts != 2 {
+		t.Errorf("got dead letter %+v, want 2 attempts of a %s event to test", deadLetter, slink.EventShortLinkUpdated)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	if rcv.attempts != 2 {
+		t.Errorf("got %d attempts, want 2", rcv.attempts)
+	}
+}
+
+func TestVerifyRejectsTamperedDeliveries(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"1"}`)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1700000000")
+	header.Set(SignatureHeader, Sign(testSecret, now.Unix(), body))
+
+	if err := Verify(testSecret, header, body, now); err == nil {
+		t.Error("Verify accepted a delivery with another timestamp")
+	}
+
+	header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	if err := Verify(testSecret, header, body, now); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := Verify(testSecret, header, []byte(`{"id":"2"}`), now); err == nil {
+		t.Error("Verify accepted a delivery with another body")
+	}
+	if err := Verify(testSecret, header, body, now.Add(time.Hour)); err == nil {
+		t.Error("Verify accepted a delivery from an hour ago")
+	}
+}
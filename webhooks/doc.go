@@ -0,0 +1,5 @@
+// Package webhooks delivers slink Events (e.g. a short link being created) to
+// registered HTTP endpoints as HMAC-signed JSON, retrying failed deliveries
+// with exponential backoff, and recording the ones given up on as dead
+// letters.
+package webhooks
@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/ronny/slink"
+)
+
+// MinSecretLength is the minimum length of the secret of an Endpoint.
+const MinSecretLength = 32
+
+// Endpoint is a registered receiver of webhook deliveries.
+type Endpoint struct {
+	// ID identifies the endpoint in logs, metrics and dead letters.
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries, see Sign.
+	Secret string `json:"secret"`
+	// Events are the types of the Events delivered to the endpoint (e.g.
+	// `shortLink.created`), or every type when empty.
+	Events []string `json:"events,omitempty"`
+}
+
+var knownEventTypes = map[string]bool{
+	slink.EventShortLinkCreated: true,
+	slink.EventShortLinkUpdated: true,
+	slink.EventShortLinkExpired: true,
+}
+
+func (e *Endpoint) validate() error {
+	if e.ID == "" {
+		return errors.New("missing id")
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("endpoint %s: url must be an absolute http(s) URL", e.ID)
+	}
+
+	if len(e.Secret) < MinSecretLength {
+		return fmt.Errorf("endpoint %s: secret must be at least %d characters", e.ID, MinSecretLength)
+	}
+
+	for _, eventType := range e.Events {
+		if !knownEventTypes[eventType] {
+			return fmt.Errorf("endpoint %s: unknown event type %q", e.ID, eventType)
+		}
+	}
+	return nil
+}
+
+// wants returns true if Events of eventType are delivered to the endpoint.
+func (e *Endpoint) wants(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
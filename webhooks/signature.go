@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers of webhook deliveries, besides `Content-Type: application/json`.
+const (
+	// IDHeader is the ID of the Event, which is the same for every attempt
+	// to deliver it, so that receivers can ignore duplicates.
+	IDHeader = "Slink-Webhook-Id"
+	// TypeHeader is the type of the Event, e.g. `shortLink.created`.
+	TypeHeader = "Slink-Webhook-Type"
+	// TimestampHeader is the time of the attempt in Unix seconds.
+	TimestampHeader = "Slink-Webhook-Timestamp"
+	// SignatureHeader is `sha256=<hex>`, see Sign.
+	SignatureHeader = "Slink-Webhook-Signature"
+)
+
+// DefaultSignatureTolerance is how far the timestamp of a delivery can be from
+// the current time, either way, for Verify to accept it.
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// Sign returns the SignatureHeader value of a delivery: the hex HMAC-SHA256,
+// with the secret of the endpoint, of the timestamp, a `.`, and the body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery received at now, with the headers
+// and body of the request, as receivers should.
+func Verify(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return &ErrInvalidSignature{msg: "missing or invalid " + TimestampHeader}
+	}
+
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew < -DefaultSignatureTolerance || skew > DefaultSignatureTolerance {
+		return &ErrInvalidSignature{msg: "the timestamp is too far from the current time"}
+	}
+
+	signature := header.Get(SignatureHeader)
+	if !strings.HasPrefix(signature, "sha256=") {
+		return &ErrInvalidSignature{msg: "missing or invalid " + SignatureHeader}
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return &ErrInvalidSignature{msg: "the signature doesn't match"}
+	}
+	return nil
+}
+
+type ErrInvalidSignature struct {
+	msg string
+}
+
+func (e *ErrInvalidSignature) Error() string {
+	return fmt.Sprintf("ErrInvalidSignature: %s", e.msg)
+}